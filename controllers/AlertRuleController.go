@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"api/database"
+	"api/dtos"
+	"api/middleware"
+	"api/models"
+	"api/services"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AlertRuleController struct {
+	alerts *services.AlertService
+}
+
+func NewAlertRuleController(alerts *services.AlertService) *AlertRuleController {
+	return &AlertRuleController{alerts: alerts}
+}
+
+func (ac *AlertRuleController) RegisterRoutes(app fiber.Router) {
+	log.Println("Setting up alert rule logs...")
+	group := app.Group("/alert_rules")
+	group.Get("/", middleware.RequireScope("alerts:read"), ac.GetAlertRules)
+	group.Post("/", middleware.RequireScope("alerts:write"), ac.CreateAlertRule)
+	group.Put("/:id", middleware.RequireScope("alerts:write"), ac.UpdateAlertRule)
+	group.Delete("/:id", middleware.RequireScope("alerts:write"), ac.DeleteAlertRule)
+	group.Post("/:id/test", middleware.RequireScope("alerts:write"), ac.TestAlertRule)
+}
+
+// loadAlertRule finds the rule in the id param that the caller manages.
+// When the returned rule is nil the error response has already been written.
+func (ac *AlertRuleController) loadAlertRule(c *fiber.Ctx) (*models.AlertRule, error) {
+	var rule models.AlertRule
+	if err := database.DB.First(&rule, c.Params("id")).Error; err != nil || !canModifyRecord(c, rule.CreatedById) {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Alert rule not found",
+		})
+	}
+	return &rule, nil
+}
+
+// applyAlertRuleRequest copies the request onto rule and checks that the caller may watch the readings of rule.UserId.
+// When ok is false the error response has already been written.
+func (ac *AlertRuleController) applyAlertRuleRequest(c *fiber.Ctx, rule *models.AlertRule) (ok bool, err error) {
+	var request dtos.CreateAlertRuleRequest
+	if err := c.BodyParser(&request); err != nil {
+		return false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	rule.Name = request.Name
+	rule.Type = request.Type
+	rule.Field = request.Field
+	rule.Operator = request.Operator
+	rule.Threshold = request.Threshold
+	rule.Count = request.Count
+	rule.WindowHours = request.WindowHours
+	rule.Notifier = request.Notifier
+	rule.Target = request.Target
+	rule.Enabled = request.Enabled == nil || *request.Enabled
+	if fields := services.ValidateAlertRule(*rule); fields != nil {
+		return false, validationFailed(c, fields)
+	}
+
+	// Caregivers may set up alerts on the readings of their patients
+	user := middleware.CurrentUser(c)
+	rule.UserId = request.UserId
+	if rule.UserId == 0 && user != nil {
+		rule.UserId = uint(user.ID)
+	}
+	if user != nil && !middleware.IsAdmin(c) {
+		allowed, err := services.CanReadUserRecords(uint(user.ID), rule.UserId)
+		if err != nil || !allowed {
+			return false, c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Not allowed to watch the readings of this user",
+			})
+		}
+	}
+	return true, nil
+}
+
+// @Summary Get alert rules
+// @Description Get the alert rules managed by the current user
+// @Produce json
+// @Tags AlertRule
+// @Param includeDeleted query bool false "Include records in the trash, admins only"
+// @Success 200 {array} models.AlertRule
+// @Failure 403 {object} map[string]string
+// @Router /api/alert_rules [get]
+func (ac *AlertRuleController) GetAlertRules(c *fiber.Ctx) error {
+	var rules []models.AlertRule
+
+	query, err := includeDeleted(c, database.DB.Order("id"))
+	if query == nil {
+		return err
+	}
+	if user := middleware.CurrentUser(c); user != nil && !middleware.IsAdmin(c) {
+		query = query.Where("created_by_id = ?", user.ID)
+	}
+	if err := query.Find(&rules).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get alert rules",
+		})
+	}
+	return c.JSON(rules)
+}
+
+// @Summary Create an alert rule
+// @Description Create a rule that is evaluated on every new blood pressure reading (threshold, count) or on a schedule (missing).
+// @Description Firings are written to the log book and sent to the notifier: webhook (URL), smtp (e-mail address) or ntfy (topic URL).
+// @Accept json
+// @Produce json
+// @Tags AlertRule
+// @Param rule body dtos.CreateAlertRuleRequest true "AlertRule object"
+// @Success 201 {object} models.AlertRule
+// @Failure 422 {object} dtos.ValidationErrorResponse
+// @Router /api/alert_rules [post]
+func (ac *AlertRuleController) CreateAlertRule(c *fiber.Ctx) error {
+	var rule models.AlertRule
+	if ok, err := ac.applyAlertRuleRequest(c, &rule); !ok {
+		return err
+	}
+	if user := middleware.CurrentUser(c); user != nil {
+		rule.CreatedById = uint(user.ID)
+	}
+
+	if err := database.DB.WithContext(c.UserContext()).Create(&rule).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create alert rule",
+		})
+	}
+	return c.Status(fiber.StatusCreated).JSON(rule)
+}
+
+// @Summary Update an alert rule
+// @Description Replace an alert rule
+// @Accept json
+// @Produce json
+// @Tags AlertRule
+// @Param id path int true "AlertRule ID"
+// @Param rule body dtos.CreateAlertRuleRequest true "AlertRule object"
+// @Success 200 {object} models.AlertRule
+// @Failure 422 {object} dtos.ValidationErrorResponse
+// @Router /api/alert_rules/{id} [put]
+func (ac *AlertRuleController) UpdateAlertRule(c *fiber.Ctx) error {
+	rule, err := ac.loadAlertRule(c)
+	if rule == nil {
+		return err
+	}
+	if ok, err := ac.applyAlertRuleRequest(c, rule); !ok {
+		return err
+	}
+
+	if err := database.DB.WithContext(c.UserContext()).Save(rule).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update alert rule",
+		})
+	}
+	return c.JSON(rule)
+}
+
+// @Summary Delete an alert rule
+// @Produce json
+// @Tags AlertRule
+// @Param id path int true "AlertRule ID"
+// @Success 200 {object} models.AlertRule
+// @Router /api/alert_rules/{id} [delete]
+func (ac *AlertRuleController) DeleteAlertRule(c *fiber.Ctx) error {
+	rule, err := ac.loadAlertRule(c)
+	if rule == nil {
+		return err
+	}
+	if err := database.DB.WithContext(c.UserContext()).Delete(rule).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete alert rule",
+		})
+	}
+	return c.JSON(rule)
+}
+
+// @Summary Test an alert rule
+// @Description Send a test alert through the notifier of the rule, without touching the log book
+// @Produce json
+// @Tags AlertRule
+// @Param id path int true "AlertRule ID"
+// @Success 200 {object} map[string]string
+// @Failure 502 {object} map[string]string
+// @Router /api/alert_rules/{id}/test [post]
+func (ac *AlertRuleController) TestAlertRule(c *fiber.Ctx) error {
+	rule, err := ac.loadAlertRule(c)
+	if rule == nil {
+		return err
+	}
+	alert := services.Alert{
+		RuleId:   rule.ID,
+		RuleName: rule.Name,
+		UserId:   rule.UserId,
+		Level:    "info",
+		Message:  "Test alert for rule " + rule.Name,
+		FiredAt:  time.Now(),
+	}
+	if err := ac.alerts.Notify(*rule, alert); err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": "Notifier failed: " + err.Error(),
+		})
+	}
+	return c.JSON(fiber.Map{"status": "sent"})
+}
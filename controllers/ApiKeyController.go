@@ -2,8 +2,12 @@ package controllers
 
 import (
 	"api/database"
+	"api/dtos"
+	"api/middleware"
 	"api/models"
+	"api/services"
 	"log"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -11,74 +15,281 @@ import (
 type ApiKeyController struct{}
 
 func (uc *ApiKeyController) RegisterRoutes(app fiber.Router) {
-	log.Println("Setting up user logs...")
+	log.Println("Setting up api key logs...")
 	group := app.Group("/api_keys")
-	group.Post("/", uc.CreateApiKey)
-	group.Get("/", uc.GetApiKeys)
+	group.Post("/", middleware.RequireScope("api_keys:write"), uc.CreateApiKey)
+	group.Get("/", middleware.RequireScope("api_keys:read"), uc.GetApiKeys)
+	group.Get("/:id", middleware.RequireScope("api_keys:read"), uc.GetApiKey)
+	group.Put("/:id", middleware.RequireScope("api_keys:write"), uc.UpdateApiKey)
+	group.Post("/:id/rotate", middleware.RequireScope("api_keys:write"), uc.RotateApiKey)
+	group.Post("/:id/revoke", middleware.RequireScope("api_keys:write"), uc.RevokeApiKey)
+}
+
+func toApiKeyResponse(apiKey models.ApiKey) dtos.ApiKeyResponse {
+	return dtos.ApiKeyResponse{
+		ID:         apiKey.ID,
+		Name:       apiKey.Name,
+		Prefix:     apiKey.Prefix,
+		Type:       apiKey.Type,
+		Scopes:     services.ParseScopes(apiKey.Scopes),
+		UserId:     apiKey.UserId,
+		CreatedAt:  apiKey.CreatedAt,
+		ExpiresAt:  apiKey.ExpiresAt,
+		LastUsedAt: apiKey.LastUsedAt,
+		RevokedAt:  apiKey.RevokedAt,
+	}
+}
+
+// validateScopes checks that every requested scope exists and that the caller holds it,
+// so a key can never be used to mint a more powerful one.
+func validateScopes(c *fiber.Ctx, scopes []string) string {
+	if len(scopes) == 0 {
+		return "at least one scope is required"
+	}
+	for _, scope := range scopes {
+		if !middleware.IsKnownScope(scope) {
+			return "unknown scope " + scope
+		}
+		if !middleware.HasScope(c, scope) {
+			return "cannot grant scope " + scope + " that the current key does not hold"
+		}
+	}
+	return ""
+}
+
+// loadApiKey finds the key in the id param. Non admins may only see their own keys.
+// When the returned key is nil the error response has already been written.
+func (uc *ApiKeyController) loadApiKey(c *fiber.Ctx) (*models.ApiKey, error) {
+	var apiKey models.ApiKey
+	if err := database.DB.First(&apiKey, c.Params("id")).Error; err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Api key not found",
+		})
+	}
+	if user := middleware.CurrentUser(c); user != nil && !middleware.IsAdmin(c) && apiKey.UserId != uint(user.ID) {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Api key not found",
+		})
+	}
+	return &apiKey, nil
 }
 
 // @Summary Get a list of ApiKeys
-// @Description Get a list of all ApiKeys
+// @Description Get a list of ApiKeys. Secrets are never returned; admins see the keys of all users.
 // @Produce json
 // @Tags ApiKey
-// @Success 200 {array} models.ApiKey
-// @Router /api/api_key [get]
+// @Param includeDeleted query bool false "Include records in the trash, admins only"
+// @Success 200 {array} dtos.ApiKeyResponse
+// @Failure 403 {object} map[string]string
+// @Router /api/api_keys [get]
 func (uc *ApiKeyController) GetApiKeys(c *fiber.Ctx) error {
 	var apiKeys []models.ApiKey
 
-	database.DB.Find(&apiKeys)
-	return c.JSON(apiKeys)
+	query, err := includeDeleted(c, database.DB.Order("id"))
+	if query == nil {
+		return err
+	}
+	if user := middleware.CurrentUser(c); user != nil && !middleware.IsAdmin(c) {
+		query = query.Where("user_id = ?", user.ID)
+	}
+	if err := query.Find(&apiKeys).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get api keys",
+		})
+	}
+
+	response := make([]dtos.ApiKeyResponse, 0, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		response = append(response, toApiKeyResponse(apiKey))
+	}
+	return c.JSON(response)
+}
+
+// @Summary Get an ApiKey
+// @Description Get a single ApiKey by ID, without its secret
+// @Produce json
+// @Tags ApiKey
+// @Param id path int true "API Key ID"
+// @Success 200 {object} dtos.ApiKeyResponse
+// @Router /api/api_keys/{id} [get]
+func (uc *ApiKeyController) GetApiKey(c *fiber.Ctx) error {
+	apiKey, err := uc.loadApiKey(c)
+	if apiKey == nil {
+		return err
+	}
+	return c.JSON(toApiKeyResponse(*apiKey))
 }
 
 // @Summary Create a new API KEY
-// @Description Create a new API key
+// @Description Generate a new API key. The full key is only included in this response, store it safely.
 // @Accept json
 // @Produce json
 // @Tags ApiKey
 // @Param api_key body dtos.CreateApiKeyRequest true "ApiKey object"
-// @Success 200 {object} models.ApiKey
-// @Router /api/api_key [post]
+// @Success 201 {object} dtos.IssuedApiKeyResponse
+// @Router /api/api_keys [post]
 func (uc *ApiKeyController) CreateApiKey(c *fiber.Ctx) error {
-	var apiKey models.ApiKey
-	// Parse the request body into the User struct
-	if err := c.BodyParser(&apiKey); err != nil {
+	var request dtos.CreateApiKeyRequest
+	if err := c.BodyParser(&request); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Cannot parse JSON",
 		})
 	}
+	if message := validateScopes(c, request.Scopes); message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+	if request.ExpiresAt != nil && request.ExpiresAt.Before(time.Now()) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "expiresAt must be in the future",
+		})
+	}
+
+	// Keys are issued to the caller unless an admin issues one for someone else
+	userId := request.UserId
+	if user := middleware.CurrentUser(c); user != nil && (userId == 0 || !middleware.IsAdmin(c)) {
+		userId = uint(user.ID)
+	}
+	var user models.User
+	if err := database.DB.First(&user, userId).Error; err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
 
-	database.DB.Create(&apiKey)
+	apiKey := models.ApiKey{
+		Name:      request.Name,
+		Type:      request.Type,
+		Scopes:    services.JoinScopes(request.Scopes),
+		ExpiresAt: request.ExpiresAt,
+		UserId:    userId,
+	}
+	key, err := services.IssueApiKey(&apiKey)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate api key",
+		})
+	}
+	if err := database.DB.WithContext(c.UserContext()).Create(&apiKey).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create api key",
+		})
+	}
 
-	return c.JSON(apiKey)
+	return c.Status(fiber.StatusCreated).JSON(dtos.IssuedApiKeyResponse{
+		ApiKeyResponse: toApiKeyResponse(apiKey),
+		Key:            key,
+	})
 }
 
 // @Summary Update an API key
-// @Description Update an existing APi key by ID
+// @Description Update the name, scopes or expiry of an existing API key by ID
 // @Accept json
 // @Produce json
 // @Tags ApiKey
 // @Param id path int true "API Key ID"
-// @Param user body dtos.CreateApiKeyRequest true "Updated user object"
-// @Success 200 {object} models.ApiKey
-// @Router /api/api_key/{id} [put]
+// @Param api_key body dtos.UpdateApiKeyRequest true "Updated api key object"
+// @Success 200 {object} dtos.ApiKeyResponse
+// @Router /api/api_keys/{id} [put]
 func (uc *ApiKeyController) UpdateApiKey(c *fiber.Ctx) error {
-	id := c.Params("id")
-	var apiKey models.ApiKey
-
-	if err := database.DB.First(&apiKey, id).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Api key not found",
-		})
+	apiKey, err := uc.loadApiKey(c)
+	if apiKey == nil {
+		return err
 	}
 
-	var updateData models.User
-	if err := c.BodyParser(&updateData); err != nil {
+	var request dtos.UpdateApiKeyRequest
+	if err := c.BodyParser(&request); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Cannot parse JSON",
 		})
 	}
 
-	database.DB.Model(&apiKey).Updates(updateData)
+	updates := map[string]interface{}{}
+	if request.Name != nil {
+		updates["name"] = *request.Name
+	}
+	if request.Scopes != nil {
+		if message := validateScopes(c, request.Scopes); message != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": message,
+			})
+		}
+		updates["scopes"] = services.JoinScopes(request.Scopes)
+	}
+	if request.ExpiresAt != nil {
+		updates["expires_at"] = request.ExpiresAt
+	}
+
+	if err := database.DB.WithContext(c.UserContext()).Model(apiKey).Updates(updates).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update api key",
+		})
+	}
+	database.DB.First(apiKey, apiKey.ID)
+
+	return c.JSON(toApiKeyResponse(*apiKey))
+}
+
+// @Summary Rotate an API key
+// @Description Replace the secret of an API key. The old key stops working immediately and the new key is only included in this response.
+// @Produce json
+// @Tags ApiKey
+// @Param id path int true "API Key ID"
+// @Success 200 {object} dtos.IssuedApiKeyResponse
+// @Router /api/api_keys/{id}/rotate [post]
+func (uc *ApiKeyController) RotateApiKey(c *fiber.Ctx) error {
+	apiKey, err := uc.loadApiKey(c)
+	if apiKey == nil {
+		return err
+	}
+	if apiKey.RevokedAt != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Api key has been revoked",
+		})
+	}
+
+	key, err := services.IssueApiKey(apiKey)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate api key",
+		})
+	}
+	apiKey.LastUsedAt = nil
+	if err := database.DB.WithContext(c.UserContext()).Model(apiKey).Select("prefix", "salt", "key_hash", "last_used_at").Updates(apiKey).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to rotate api key",
+		})
+	}
+
+	return c.JSON(dtos.IssuedApiKeyResponse{
+		ApiKeyResponse: toApiKeyResponse(*apiKey),
+		Key:            key,
+	})
+}
+
+// @Summary Revoke an API key
+// @Description Revoke an API key. Revoked keys are kept for auditing but can no longer be used.
+// @Produce json
+// @Tags ApiKey
+// @Param id path int true "API Key ID"
+// @Success 200 {object} dtos.ApiKeyResponse
+// @Router /api/api_keys/{id}/revoke [post]
+func (uc *ApiKeyController) RevokeApiKey(c *fiber.Ctx) error {
+	apiKey, err := uc.loadApiKey(c)
+	if apiKey == nil {
+		return err
+	}
+
+	if apiKey.RevokedAt == nil {
+		now := time.Now()
+		if err := database.DB.WithContext(c.UserContext()).Model(apiKey).Update("revoked_at", now).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to revoke api key",
+			})
+		}
+		apiKey.RevokedAt = &now
+	}
 
-	return c.JSON(apiKey)
+	return c.JSON(toApiKeyResponse(*apiKey))
 }
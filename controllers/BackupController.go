@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"api/database"
+	"api/middleware"
+	"api/services"
+	"errors"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type BackupController struct {
+	backups *services.BackupService
+}
+
+func NewBackupController(backups *services.BackupService) *BackupController {
+	return &BackupController{backups: backups}
+}
+
+func (bc *BackupController) RegisterRoutes(app fiber.Router) {
+	log.Println("Setting up backups...")
+	// A backup holds the records of every user, so backups are for admins only
+	group := app.Group("/admin/backups")
+	group.Get("/", middleware.RequireScope("admin"), bc.GetBackups)
+	group.Post("/", middleware.RequireScope("admin"), bc.CreateBackup)
+	group.Post("/restore", middleware.RequireScope("admin"), bc.RestoreBackup)
+	group.Get("/:name", middleware.RequireScope("admin"), bc.DownloadBackup)
+}
+
+// @Summary List database backups
+// @Description List the gzipped database snapshots, newest first
+// @Produce json
+// @Tags Backup
+// @Success 200 {array} dtos.BackupInfo
+// @Router /api/admin/backups [get]
+func (bc *BackupController) GetBackups(c *fiber.Ctx) error {
+	backups, err := bc.backups.List()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list backups",
+		})
+	}
+	return c.JSON(backups)
+}
+
+// @Summary Back up the database
+// @Description Take a snapshot of the database now instead of waiting for the scheduled one. The oldest snapshots beyond the retention count are removed.
+// @Produce json
+// @Tags Backup
+// @Success 201 {object} dtos.BackupInfo
+// @Failure 501 {object} map[string]string
+// @Router /api/admin/backups [post]
+func (bc *BackupController) CreateBackup(c *fiber.Ctx) error {
+	backup, err := bc.backups.Backup()
+	if errors.Is(err, database.ErrNotSQLite) {
+		return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		log.Println("Backup failed:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to back up the database",
+		})
+	}
+	return c.Status(fiber.StatusCreated).JSON(backup)
+}
+
+// @Summary Download a database backup
+// @Description Download a gzipped database snapshot
+// @Produce application/gzip
+// @Tags Backup
+// @Param name path string true "Backup name"
+// @Success 200 {file} file
+// @Failure 404 {object} map[string]string
+// @Router /api/admin/backups/{name} [get]
+func (bc *BackupController) DownloadBackup(c *fiber.Ctx) error {
+	path, err := bc.backups.Path(c.Params("name"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Backup not found",
+		})
+	}
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Download(path)
+}
+
+// @Summary Restore the database
+// @Description Replace the database with an uploaded snapshot, gzipped or not. The snapshot must pass PRAGMA integrity_check
+// @Description and must not come from a newer version of the API, and be at most BACKUP_MAX_RESTORE_MB once decompressed.
+// @Description The current database is backed up first. Sync clients get reset on their next pull and pull everything again.
+// @Accept multipart/form-data
+// @Produce json
+// @Tags Backup
+// @Param file formData file true "Database snapshot"
+// @Success 200 {object} dtos.BackupRestoreResult
+// @Failure 400 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Failure 501 {object} map[string]string
+// @Router /api/admin/backups/restore [post]
+func (bc *BackupController) RestoreBackup(c *fiber.Ctx) error {
+	if database.ActiveDialect.Name != "sqlite" {
+		return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{
+			"error": database.ErrNotSQLite.Error(),
+		})
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Upload the snapshot as the multipart field file",
+		})
+	}
+	upload, err := header.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot read the upload",
+		})
+	}
+	defer upload.Close()
+
+	result, err := bc.backups.Restore(upload)
+	if errors.Is(err, services.ErrSnapshotTooLarge) {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if errors.Is(err, services.ErrInvalidSnapshot) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		log.Println("Restore failed:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to restore the database",
+		})
+	}
+	return c.JSON(result)
+}
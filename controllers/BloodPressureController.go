@@ -3,31 +3,97 @@ package controllers
 import (
 	"api/database"
 	"api/dtos"
+	"api/middleware"
 	"api/models"
+	"api/services"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
 	"log"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
-type BloodPressureController struct {}
+type BloodPressureController struct {
+	alerts *services.AlertService
+}
+
+func NewBloodPressureController(alerts *services.AlertService) *BloodPressureController {
+	return &BloodPressureController{alerts: alerts}
+}
 
 func (uc *BloodPressureController) RegisterRoutes(app fiber.Router) {
 	log.Println("Setting up user logs...")
 	group := app.Group("/blood_pressure")
-	group.Post("/", uc.CreateBloodPressure)
-	group.Get("/", uc.GetBloodPressureReports)
+	group.Post("/", middleware.RequireScope("bloodpressure:write"), uc.CreateBloodPressure)
+	group.Get("/", middleware.RequireScope("bloodpressure:read"), uc.GetBloodPressureReports)
+	group.Get("/stats", middleware.RequireScope("bloodpressure:read"), uc.GetBloodPressureStats)
+	group.Get("/export", middleware.RequireScope("bloodpressure:read"), uc.ExportBloodPressure)
+	group.Post("/import", middleware.RequireScope("bloodpressure:write"), uc.ImportBloodPressure)
+	group.Get("/report.pdf", middleware.RequireScope("bloodpressure:read"), uc.GetBloodPressureReport)
+	group.Get("/:id", middleware.RequireScope("bloodpressure:read"), uc.GetBloodPressure)
+	group.Put("/:id", middleware.RequireScope("bloodpressure:write"), uc.UpdateBloodPressure)
+	group.Patch("/:id", middleware.RequireScope("bloodpressure:write"), uc.PatchBloodPressure)
+	group.Delete("/:id", middleware.RequireScope("bloodpressure:write"), uc.DeleteBloodPressure)
+}
+
+func validationFailed(c *fiber.Ctx, fields map[string]string) error {
+	return c.Status(fiber.StatusUnprocessableEntity).JSON(dtos.ValidationErrorResponse{
+		Error:  "Validation failed",
+		Fields: fields,
+	})
+}
+
+// loadBloodPressure finds the reading in the id param that the caller may read.
+// When the returned reading is nil the error response has already been written.
+func (uc *BloodPressureController) loadBloodPressure(c *fiber.Ctx) (*models.BloodPressure, error) {
+	var bloodPressure models.BloodPressure
+	if err := database.DB.First(&bloodPressure, c.Params("id")).Error; err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Blood pressure record not found",
+		})
+	}
+	if user := middleware.CurrentUser(c); user != nil && !middleware.IsAdmin(c) {
+		allowed, err := services.CanReadUserRecords(uint(user.ID), bloodPressure.UserId)
+		if err != nil || !allowed {
+			return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Blood pressure record not found",
+			})
+		}
+	}
+	return &bloodPressure, nil
 }
 
 // @Summary Get a list of blood pressures
-// @Description Get a list of all blood pressure recordings
+// @Description Get a list of the blood pressure recordings of the current user, or of userId for caregivers
 // @Produce json
 // @Tags BloodPressure
+// @Param userId query int false "User whose readings to list"
+// @Param includeDeleted query bool false "Include records in the trash, admins only"
 // @Success 200 {array} models.BloodPressure
+// @Failure 403 {object} map[string]string
 // @Router /api/blood_pressure [get]
 func (uc *BloodPressureController) GetBloodPressureReports(c *fiber.Ctx) error {
+	ownerId, ok, err := resolveRecordOwner(c)
+	if !ok {
+		return err
+	}
 	var BloodPressureReports []models.BloodPressure
 
-	result := database.DB.Find(&BloodPressureReports)
+	query, err := includeDeleted(c, database.DB.Order("measured_at"))
+	if query == nil {
+		return err
+	}
+	if ownerId != 0 {
+		query = query.Where("user_id = ?", ownerId)
+	}
+	result := query.Find(&BloodPressureReports)
 	if result.Error != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to get blood pressure records",
@@ -38,12 +104,13 @@ func (uc *BloodPressureController) GetBloodPressureReports(c *fiber.Ctx) error {
 }
 
 // @Summary Create a new Blood Pressure report
-// @Description Create a new Blood Pressure report
+// @Description Create a new Blood Pressure report. measuredAt defaults to now.
 // @Accept json
 // @Produce json
 // @Tags BloodPressure
 // @Param user body dtos.CreateBloodPressure true "BloodPressure object"
-// @Success 200 {object} models.BloodPressure
+// @Success 201 {object} models.BloodPressure
+// @Failure 422 {object} dtos.ValidationErrorResponse
 // @Router /api/blood_pressure [post]
 func (uc *BloodPressureController) CreateBloodPressure(c *fiber.Ctx) error {
 	var BloodPressureReport dtos.CreateBloodPressure
@@ -53,14 +120,27 @@ func (uc *BloodPressureController) CreateBloodPressure(c *fiber.Ctx) error {
 			"error": "Cannot parse JSON",
 		})
 	}
+	measuredAt := time.Now()
+	if BloodPressureReport.MeasuredAt != nil {
+		measuredAt = *BloodPressureReport.MeasuredAt
+	}
+	if fields := services.ValidateBloodPressure(BloodPressureReport.Systolic, BloodPressureReport.Diastolic, BloodPressureReport.Pulse, BloodPressureReport.Medicine, measuredAt); fields != nil {
+		return validationFailed(c, fields)
+	}
+	ownerId, ok, err := resolveWriteOwner(c, BloodPressureReport.UserId)
+	if !ok {
+		return err
+	}
 	var BloodPressure = models.BloodPressure{
 		Systolic: BloodPressureReport.Systolic,
 		Diastolic: BloodPressureReport.Diastolic,
 		Pulse: BloodPressureReport.Pulse,
 		Medicine: BloodPressureReport.Medicine,
+		MeasuredAt: measuredAt,
+		UserId: ownerId,
 	}
 	
-	result := database.DB.Create(&BloodPressure)
+	result := database.DB.WithContext(c.UserContext()).Create(&BloodPressure)
 	if result.Error != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create blood pressure record",
@@ -68,4 +148,490 @@ func (uc *BloodPressureController) CreateBloodPressure(c *fiber.Ctx) error {
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(BloodPressure)
-}
\ No newline at end of file
+}
+
+// @Summary Get blood pressure statistics
+// @Description Min/max/mean/median, weekly and monthly buckets, a moving average and the share of readings per hypertension category.
+// @Description Use medicine to only include readings taken on a medication, or groupBy=medicine to compare medications.
+// @Produce json
+// @Tags BloodPressure
+// @Param from query string false "Start of the range (RFC 3339 or YYYY-MM-DD)"
+// @Param to query string false "End of the range (RFC 3339 or YYYY-MM-DD, inclusive)"
+// @Param medicine query string false "Only include readings with this medicine"
+// @Param groupBy query string false "Set to medicine to also get statistics per medicine"
+// @Param guideline query string false "Categorisation guideline, aha (default) or esc"
+// @Param window query int false "Number of readings in the moving average (default 7)"
+// @Param userId query int false "User whose readings to analyse"
+// @Success 200 {object} dtos.BloodPressureStatsResponse
+// @Failure 400 {object} map[string]string
+// @Router /api/blood_pressure/stats [get]
+func (uc *BloodPressureController) GetBloodPressureStats(c *fiber.Ctx) error {
+	from, err := parseTimeQuery(c, "from", false)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid from date",
+		})
+	}
+	to, err := parseTimeQuery(c, "to", true)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid to date",
+		})
+	}
+	guideline := c.Query("guideline", services.GuidelineAHA)
+	if guideline != services.GuidelineAHA && guideline != services.GuidelineESC {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "guideline must be aha or esc",
+		})
+	}
+	window := c.QueryInt("window", 7)
+	if window < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "window must be at least 1",
+		})
+	}
+
+	ownerId, ok, err := resolveRecordOwner(c)
+	if !ok {
+		return err
+	}
+
+	query := database.DB.Model(&models.BloodPressure{})
+	if ownerId != 0 {
+		query = query.Where("user_id = ?", ownerId)
+	}
+	if from != nil {
+		query = query.Where("measured_at >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("measured_at <= ?", *to)
+	}
+	if medicine := c.Query("medicine"); medicine != "" {
+		query = query.Where("medicine = ?", medicine)
+	}
+
+	var readings []models.BloodPressure
+	if err := query.Find(&readings).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get blood pressure records",
+		})
+	}
+
+	response := dtos.BloodPressureStatsResponse{
+		BloodPressureStats: services.ComputeBloodPressureStats(readings, guideline, window),
+		From:               from,
+		To:                 to,
+		Guideline:          guideline,
+		Window:             window,
+	}
+	if c.Query("groupBy") == "medicine" {
+		byMedicine := map[string][]models.BloodPressure{}
+		medicines := []string{}
+		for _, reading := range readings {
+			if _, ok := byMedicine[reading.Medicine]; !ok {
+				medicines = append(medicines, reading.Medicine)
+			}
+			byMedicine[reading.Medicine] = append(byMedicine[reading.Medicine], reading)
+		}
+		sort.Strings(medicines)
+		for _, medicine := range medicines {
+			stats := services.ComputeBloodPressureStats(byMedicine[medicine], guideline, window)
+			stats.Medicine = &medicine
+			response.ByMedicine = append(response.ByMedicine, stats)
+		}
+	}
+
+	return c.JSON(response)
+}
+
+// @Summary Get a Blood Pressure report
+// @Description Get a single Blood Pressure report by ID
+// @Produce json
+// @Tags BloodPressure
+// @Param id path int true "BloodPressure ID"
+// @Success 200 {object} models.BloodPressure
+// @Failure 404 {object} map[string]string
+// @Router /api/blood_pressure/{id} [get]
+func (uc *BloodPressureController) GetBloodPressure(c *fiber.Ctx) error {
+	bloodPressure, err := uc.loadBloodPressure(c)
+	if bloodPressure == nil {
+		return err
+	}
+	return c.JSON(bloodPressure)
+}
+
+// @Summary Replace a Blood Pressure report
+// @Description Replace all fields of a Blood Pressure report. Only the owner may change a reading.
+// @Accept json
+// @Produce json
+// @Tags BloodPressure
+// @Param id path int true "BloodPressure ID"
+// @Param bloodPressure body dtos.CreateBloodPressure true "BloodPressure object"
+// @Success 200 {object} models.BloodPressure
+// @Failure 422 {object} dtos.ValidationErrorResponse
+// @Router /api/blood_pressure/{id} [put]
+func (uc *BloodPressureController) UpdateBloodPressure(c *fiber.Ctx) error {
+	bloodPressure, err := uc.loadBloodPressure(c)
+	if bloodPressure == nil {
+		return err
+	}
+	var request dtos.CreateBloodPressure
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	bloodPressure.Systolic = request.Systolic
+	bloodPressure.Diastolic = request.Diastolic
+	bloodPressure.Pulse = request.Pulse
+	bloodPressure.Medicine = request.Medicine
+	if request.MeasuredAt != nil {
+		bloodPressure.MeasuredAt = *request.MeasuredAt
+	}
+	return uc.saveBloodPressure(c, bloodPressure)
+}
+
+// @Summary Update a Blood Pressure report
+// @Description Change some fields of a Blood Pressure report. Only the owner may change a reading.
+// @Accept json
+// @Produce json
+// @Tags BloodPressure
+// @Param id path int true "BloodPressure ID"
+// @Param bloodPressure body dtos.PatchBloodPressure true "Fields to change"
+// @Success 200 {object} models.BloodPressure
+// @Failure 422 {object} dtos.ValidationErrorResponse
+// @Router /api/blood_pressure/{id} [patch]
+func (uc *BloodPressureController) PatchBloodPressure(c *fiber.Ctx) error {
+	bloodPressure, err := uc.loadBloodPressure(c)
+	if bloodPressure == nil {
+		return err
+	}
+	var request dtos.PatchBloodPressure
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if request.Systolic != nil {
+		bloodPressure.Systolic = *request.Systolic
+	}
+	if request.Diastolic != nil {
+		bloodPressure.Diastolic = *request.Diastolic
+	}
+	if request.Pulse != nil {
+		bloodPressure.Pulse = *request.Pulse
+	}
+	if request.Medicine != nil {
+		bloodPressure.Medicine = *request.Medicine
+	}
+	if request.MeasuredAt != nil {
+		bloodPressure.MeasuredAt = *request.MeasuredAt
+	}
+	return uc.saveBloodPressure(c, bloodPressure)
+}
+
+// saveBloodPressure validates and stores an edited reading.
+func (uc *BloodPressureController) saveBloodPressure(c *fiber.Ctx, bloodPressure *models.BloodPressure) error {
+	if !canModifyRecord(c, bloodPressure.UserId) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Not allowed to change this blood pressure record",
+		})
+	}
+	if fields := services.ValidateBloodPressure(bloodPressure.Systolic, bloodPressure.Diastolic, bloodPressure.Pulse, bloodPressure.Medicine, bloodPressure.MeasuredAt); fields != nil {
+		return validationFailed(c, fields)
+	}
+
+	err := database.DB.WithContext(c.UserContext()).Model(bloodPressure).
+		Select("systolic", "diastolic", "pulse", "medicine", "measured_at").
+		Updates(bloodPressure).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update blood pressure record",
+		})
+	}
+	return c.JSON(bloodPressure)
+}
+
+// @Summary Delete a Blood Pressure report
+// @Description Soft delete a Blood Pressure report. Only the owner may delete a reading.
+// @Produce json
+// @Tags BloodPressure
+// @Param id path int true "BloodPressure ID"
+// @Success 200 {object} models.BloodPressure
+// @Router /api/blood_pressure/{id} [delete]
+func (uc *BloodPressureController) DeleteBloodPressure(c *fiber.Ctx) error {
+	bloodPressure, err := uc.loadBloodPressure(c)
+	if bloodPressure == nil {
+		return err
+	}
+	if !canModifyRecord(c, bloodPressure.UserId) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Not allowed to delete this blood pressure record",
+		})
+	}
+
+	if err := database.DB.WithContext(c.UserContext()).Delete(bloodPressure).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete blood pressure record",
+		})
+	}
+	return c.JSON(bloodPressure)
+}
+
+// bloodPressureWriter is implemented by the export formats.
+type bloodPressureWriter interface {
+	Write(reading models.BloodPressure) error
+	Flush() error
+}
+
+// @Summary Export blood pressure history
+// @Description Stream all readings in a date range as CSV (for spreadsheets) or JSON, oldest first.
+// @Produce text/csv
+// @Produce json
+// @Tags BloodPressure
+// @Param format query string false "csv (default) or json"
+// @Param from query string false "Start of the range (RFC 3339 or YYYY-MM-DD)"
+// @Param to query string false "End of the range (RFC 3339 or YYYY-MM-DD, inclusive)"
+// @Param userId query int false "User whose readings to export"
+// @Success 200 {file} file
+// @Router /api/blood_pressure/export [get]
+func (uc *BloodPressureController) ExportBloodPressure(c *fiber.Ctx) error {
+	format := c.Query("format", "csv")
+	if format != "csv" && format != "json" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "format must be csv or json",
+		})
+	}
+	from, err := parseTimeQuery(c, "from", false)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid from date",
+		})
+	}
+	to, err := parseTimeQuery(c, "to", true)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid to date",
+		})
+	}
+	ownerId, ok, err := resolveRecordOwner(c)
+	if !ok {
+		return err
+	}
+
+	query := database.DB.Model(&models.BloodPressure{}).Order("measured_at")
+	if ownerId != 0 {
+		query = query.Where("user_id = ?", ownerId)
+	}
+	if from != nil {
+		query = query.Where("measured_at >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("measured_at <= ?", *to)
+	}
+	rows, err := query.Rows()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get blood pressure records",
+		})
+	}
+
+	if format == "json" {
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
+	} else {
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	}
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="blood_pressure.`+format+`"`)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer rows.Close()
+
+		var writer bloodPressureWriter
+		var err error
+		if format == "json" {
+			writer, err = services.NewBloodPressureJSONWriter(w)
+		} else {
+			writer, err = services.NewBloodPressureCSVWriter(w)
+		}
+		if err != nil {
+			return
+		}
+		for rows.Next() {
+			var reading models.BloodPressure
+			if err := database.DB.ScanRows(rows, &reading); err != nil {
+				log.Println("Failed to export blood pressure record:", err)
+				return
+			}
+			if err := writer.Write(reading); err != nil {
+				return
+			}
+		}
+		if err := writer.Flush(); err != nil {
+			return
+		}
+		w.Flush()
+	})
+	return nil
+}
+
+// @Summary Import blood pressure history
+// @Description Import readings from a CSV file exported by another app. Every row is validated like a new reading,
+// @Description rows with a timestamp that already has a reading are skipped as duplicates. The import runs in one transaction.
+// @Accept multipart/form-data
+// @Produce json
+// @Tags BloodPressure
+// @Param file formData file true "CSV file with a header row"
+// @Param mapping formData string false "JSON object mapping systolic, diastolic, pulse, medicine and measuredAt to column headers"
+// @Param timeFormat formData string false "Go time layout of the measuredAt column, e.g. 2006-01-02 15:04"
+// @Param delimiter formData string false "Column delimiter (default ,)"
+// @Param strict formData bool false "Import nothing when any row is invalid"
+// @Param userId formData int false "User to import the readings for (admins only)"
+// @Success 200 {object} dtos.BloodPressureImportReport
+// @Failure 422 {object} dtos.BloodPressureImportReport
+// @Router /api/blood_pressure/import [post]
+func (uc *BloodPressureController) ImportBloodPressure(c *fiber.Ctx) error {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "file is required",
+		})
+	}
+
+	options := services.BloodPressureImportOptions{
+		TimeFormat: c.FormValue("timeFormat"),
+		Strict:     c.FormValue("strict") == "true",
+	}
+	if mapping := c.FormValue("mapping"); mapping != "" {
+		if err := json.Unmarshal([]byte(mapping), &options.Mapping); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "mapping must be a JSON object of field to column name",
+			})
+		}
+	}
+	if delimiter := c.FormValue("delimiter"); delimiter != "" {
+		r, size := utf8.DecodeRuneInString(delimiter)
+		if size != len(delimiter) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "delimiter must be a single character",
+			})
+		}
+		options.Delimiter = r
+	}
+	var requestedOwner int
+	if userId := c.FormValue("userId"); userId != "" {
+		if requestedOwner, err = strconv.Atoi(userId); err != nil || requestedOwner < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid userId",
+			})
+		}
+	}
+	ownerId, ok, err := resolveWriteOwner(c, uint(requestedOwner))
+	if !ok {
+		return err
+	}
+	options.UserId = ownerId
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot read file",
+		})
+	}
+	defer file.Close()
+
+	var report dtos.BloodPressureImportReport
+	err = database.DB.WithContext(c.UserContext()).Transaction(func(tx *gorm.DB) error {
+		var importErr error
+		report, importErr = services.ImportBloodPressureCSV(tx, file, options)
+		return importErr
+	})
+	if errors.Is(err, services.ErrInvalidImportRows) {
+		// Nothing was stored, report the valid rows as such
+		report.Imported = 0
+		for i := range report.Rows {
+			if report.Rows[i].Status == "imported" {
+				report.Rows[i].Status = "valid"
+				report.Rows[i].ID = 0
+			}
+		}
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(report)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Import failed: " + err.Error(),
+		})
+	}
+	// The import ran in one transaction, which the alert callbacks leave out
+	var imported []int
+	for _, row := range report.Rows {
+		if row.Status == "imported" {
+			imported = append(imported, row.ID)
+		}
+	}
+	uc.alerts.EvaluateReadings(imported)
+
+	return c.JSON(report)
+}
+
+// @Summary Printable blood pressure report
+// @Description A PDF for doctor visits with a summary table, morning/evening averages, a trend chart,
+// @Description medication periods and all hypertensive crisis readings in the chosen period.
+// @Produce application/pdf
+// @Tags BloodPressure
+// @Param from query string false "Start of the range (RFC 3339 or YYYY-MM-DD)"
+// @Param to query string false "End of the range (RFC 3339 or YYYY-MM-DD, inclusive)"
+// @Param userId query int false "User whose readings to report"
+// @Success 200 {file} file
+// @Router /api/blood_pressure/report.pdf [get]
+func (uc *BloodPressureController) GetBloodPressureReport(c *fiber.Ctx) error {
+	from, err := parseTimeQuery(c, "from", false)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid from date",
+		})
+	}
+	to, err := parseTimeQuery(c, "to", true)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid to date",
+		})
+	}
+	ownerId, ok, err := resolveRecordOwner(c)
+	if !ok {
+		return err
+	}
+
+	query := database.DB.Order("measured_at")
+	if ownerId != 0 {
+		query = query.Where("user_id = ?", ownerId)
+	}
+	if from != nil {
+		query = query.Where("measured_at >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("measured_at <= ?", *to)
+	}
+	report := services.BloodPressureReport{From: from, To: to}
+	if err := query.Find(&report.Readings).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get blood pressure records",
+		})
+	}
+	if ownerId != 0 {
+		var user models.User
+		if database.DB.First(&user, ownerId).Error == nil {
+			report.PatientName = user.Name
+		}
+	}
+
+	var pdf bytes.Buffer
+	if err := services.WriteBloodPressureReport(&pdf, report); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to render report",
+		})
+	}
+	c.Set(fiber.HeaderContentType, "application/pdf")
+	c.Set(fiber.HeaderContentDisposition, `inline; filename="blood_pressure_report.pdf"`)
+	return c.Send(pdf.Bytes())
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"api/database"
+	"api/dtos"
+	"api/middleware"
+	"api/models"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type CaregiverController struct{}
+
+func (cc *CaregiverController) RegisterRoutes(app fiber.Router) {
+	log.Println("Setting up caregiver logs...")
+	group := app.Group("/caregivers")
+	group.Get("/", middleware.RequireScope("caregivers:read"), cc.GetCaregivers)
+	group.Post("/", middleware.RequireScope("caregivers:write"), cc.CreateCaregiver)
+	group.Delete("/:id", middleware.RequireScope("caregivers:write"), cc.DeleteCaregiver)
+}
+
+// @Summary Get caregiver relationships
+// @Description Get the caregiver relationships the current user is part of, either as patient or as caregiver
+// @Produce json
+// @Tags Caregiver
+// @Param includeDeleted query bool false "Include records in the trash, admins only"
+// @Success 200 {array} models.Caregiver
+// @Failure 403 {object} map[string]string
+// @Router /api/caregivers [get]
+func (cc *CaregiverController) GetCaregivers(c *fiber.Ctx) error {
+	var caregivers []models.Caregiver
+
+	query, err := includeDeleted(c, database.DB.Preload("Patient").Preload("Caregiver"))
+	if query == nil {
+		return err
+	}
+	if user := middleware.CurrentUser(c); user != nil && !middleware.IsAdmin(c) {
+		query = query.Where("patient_id = ? OR caregiver_id = ?", user.ID, user.ID)
+	}
+	if err := query.Find(&caregivers).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get caregivers",
+		})
+	}
+	return c.JSON(caregivers)
+}
+
+// @Summary Add a caregiver
+// @Description Allow another user to read the health records and log book of the patient (the current user by default)
+// @Accept json
+// @Produce json
+// @Tags Caregiver
+// @Param caregiver body dtos.CreateCaregiverRequest true "Caregiver object"
+// @Success 201 {object} models.Caregiver
+// @Router /api/caregivers [post]
+func (cc *CaregiverController) CreateCaregiver(c *fiber.Ctx) error {
+	var request dtos.CreateCaregiverRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+	patientId, ok, err := resolveWriteOwner(c, request.PatientId)
+	if !ok {
+		return err
+	}
+	if patientId == 0 || request.CaregiverId == 0 || patientId == request.CaregiverId {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "patientId and caregiverId must be two different users",
+		})
+	}
+
+	var users int64
+	database.DB.Model(&models.User{}).Where("id IN ?", []uint{patientId, request.CaregiverId}).Count(&users)
+	if users != 2 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	caregiver := models.Caregiver{
+		PatientId:   patientId,
+		CaregiverId: request.CaregiverId,
+	}
+	if err := database.DB.WithContext(c.UserContext()).Create(&caregiver).Error; err != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Caregiver already exists",
+		})
+	}
+	database.DB.Preload("Patient").Preload("Caregiver").First(&caregiver, caregiver.ID)
+
+	return c.Status(fiber.StatusCreated).JSON(caregiver)
+}
+
+// @Summary Remove a caregiver
+// @Description Remove a caregiver relationship. Both the patient and the caregiver may end it.
+// @Produce json
+// @Tags Caregiver
+// @Param id path int true "Caregiver ID"
+// @Success 200 {object} models.Caregiver
+// @Router /api/caregivers/{id} [delete]
+func (cc *CaregiverController) DeleteCaregiver(c *fiber.Ctx) error {
+	var caregiver models.Caregiver
+	if err := database.DB.First(&caregiver, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Caregiver not found",
+		})
+	}
+	if !canModifyRecord(c, caregiver.PatientId) && !canModifyRecord(c, caregiver.CaregiverId) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Caregiver not found",
+		})
+	}
+
+	if err := database.DB.WithContext(c.UserContext()).Delete(&caregiver).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete caregiver",
+		})
+	}
+	return c.JSON(caregiver)
+}
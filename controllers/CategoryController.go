@@ -2,52 +2,232 @@ package controllers
 
 import (
 	"api/database"
+	"api/dtos"
+	"api/middleware"
 	"api/models"
+	"api/services"
+	"errors"
 	"log"
+	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 type CategoryController struct{}
 
-func (c *CategoryController) RegisterRoutes(app fiber.Router) {
-	log.Println("Setting up category logs...")
-	group := app.Group("/category")
-	group.Get("/", c.GetCategories)
-	group.Post("/", c.CreateCategory)
+func (cc *CategoryController) RegisterRoutes(app fiber.Router) {
+	log.Println("Setting up categories...")
+	group := app.Group("/categories")
+	group.Get("/", middleware.RequireScope("categories:read"), cc.GetCategories)
+	group.Get("/tree", middleware.RequireScope("categories:read"), cc.GetCategoryTree)
+	group.Get("/:id", middleware.RequireScope("categories:read"), cc.GetCategory)
+	group.Post("/", middleware.RequireScope("categories:write"), cc.CreateCategory)
+	group.Put("/:id", middleware.RequireScope("categories:write"), cc.UpdateCategory)
+	group.Delete("/:id", middleware.RequireScope("categories:write"), cc.DeleteCategory)
+	// Where categories used to be, for clients from before
+	legacy := app.Group("/category")
+	legacy.Get("/", middleware.RequireScope("categories:read"), cc.GetCategories)
+	legacy.Post("/", middleware.RequireScope("categories:write"), cc.CreateCategory)
+}
+
+// loadCategory finds the category in the id param.
+// When the returned category is nil the error response has already been written.
+func (cc *CategoryController) loadCategory(c *fiber.Ctx) (*models.Category, error) {
+	var category models.Category
+	if err := database.DB.First(&category, c.Params("id")).Error; err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Category not found",
+		})
+	}
+	return &category, nil
 }
 
 // @Summary Get a list of market categories
-// @Description Get a list of all market categories
+// @Description Get all market categories, ordered by title. Use the tree for how they nest.
 // @Produce json
 // @Tags Category
+// @Param includeDeleted query bool false "Include records in the trash, admins only"
 // @Success 200 {array} models.Category
-// @Router /api/category [get]
-func (uc *CategoryController) GetCategories(c *fiber.Ctx) error {
-	var marketItems []models.MarketItem
+// @Failure 403 {object} map[string]string
+// @Router /api/categories [get]
+func (cc *CategoryController) GetCategories(c *fiber.Ctx) error {
+	query, err := includeDeleted(c, database.DB)
+	if query == nil {
+		return err
+	}
+	categories := []models.Category{}
+	if err := query.Order("title, id").Find(&categories).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list categories",
+		})
+	}
+	return c.JSON(categories)
+}
+
+// @Summary Get the category tree
+// @Description Get the market categories nested below their parents, ordered by title, with the number of active
+// @Description market items in each category and in everything below it
+// @Produce json
+// @Tags Category
+// @Success 200 {array} dtos.CategoryTreeNode
+// @Router /api/categories/tree [get]
+func (cc *CategoryController) GetCategoryTree(c *fiber.Ctx) error {
+	tree, err := services.CategoryTree()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load categories",
+		})
+	}
+	return c.JSON(tree)
+}
 
-	database.DB.Find(&marketItems)
-	return c.JSON(marketItems)
+// @Summary Get a market category
+// @Description Get a market category by ID
+// @Produce json
+// @Tags Category
+// @Param id path int true "Category ID"
+// @Success 200 {object} models.Category
+// @Failure 404 {object} map[string]string
+// @Router /api/categories/{id} [get]
+func (cc *CategoryController) GetCategory(c *fiber.Ctx) error {
+	category, err := cc.loadCategory(c)
+	if category == nil {
+		return err
+	}
+	return c.JSON(category)
 }
 
 // @Summary Create a new market Category
-// @Description Create a new market Category
+// @Description Create a new market category, below parentId or at the top without one. The slug is made from the
+// @Description title when it is left out.
+// @Accept json
+// @Produce json
+// @Tags Category
+// @Param category body dtos.CreateCategory true "MarketCategory object"
+// @Success 201 {object} models.Category
+// @Failure 400 {object} map[string]string
+// @Failure 422 {object} dtos.ValidationErrorResponse
+// @Router /api/categories [post]
+func (cc *CategoryController) CreateCategory(c *fiber.Ctx) error {
+	var request dtos.CreateCategory
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+	category := models.Category{
+		Title:    request.Title,
+		Slug:     request.Slug,
+		ParentId: request.ParentId,
+		Icon:     request.Icon,
+		Color:    request.Color,
+	}
+	return cc.saveCategory(c, &category, fiber.StatusCreated)
+}
+
+// @Summary Replace a market category
+// @Description Replace all fields of a market category. A null parentId moves it to the top, it can't move below itself.
 // @Accept json
 // @Produce json
 // @Tags Category
-// @Param user body dtos.CreateCategory true "MarketCategory object"
+// @Param id path int true "Category ID"
+// @Param category body dtos.UpdateCategory true "MarketCategory object"
 // @Success 200 {object} models.Category
-// @Router /api/category [post]
-func (uc *CategoryController) CreateCategory(c *fiber.Ctx) error {
-	var marketItem models.MarketItem
-	// Parse the request body into the User struct
-	if err := c.BodyParser(&marketItem); err != nil {
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 422 {object} dtos.ValidationErrorResponse
+// @Router /api/categories/{id} [put]
+func (cc *CategoryController) UpdateCategory(c *fiber.Ctx) error {
+	category, err := cc.loadCategory(c)
+	if category == nil {
+		return err
+	}
+	var request dtos.UpdateCategory
+	if err := c.BodyParser(&request); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Cannot parse JSON",
 		})
 	}
+	category.Title = request.Title
+	category.Slug = request.Slug
+	category.ParentId = request.ParentId
+	category.Icon = request.Icon
+	category.Color = request.Color
+	return cc.saveCategory(c, category, fiber.StatusOK)
+}
+
+// saveCategory validates and stores a new or edited category and responds with it in status.
+func (cc *CategoryController) saveCategory(c *fiber.Ctx, category *models.Category, status int) error {
+	fields, err := services.ValidateCategory(category)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check the category",
+		})
+	}
+	if fields != nil {
+		return validationFailed(c, fields)
+	}
+
+	db := database.DB.WithContext(c.UserContext())
+	if category.ID == 0 {
+		err = db.Create(category).Error
+	} else {
+		err = db.Model(category).Select("title", "slug", "parent_id", "icon", "color").Updates(category).Error
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save category",
+		})
+	}
+	return c.Status(status).JSON(category)
+}
 
-	database.DB.Create(&marketItem)
+// @Summary Delete a market category
+// @Description Move a market category to the trash. A category with market items or categories below it is only
+// @Description deleted when reassignTo says where they go: another category, or 0 to leave the items without a
+// @Description category and move the categories below it to the top.
+// @Produce json
+// @Tags Category
+// @Param id path int true "Category ID"
+// @Param reassignTo query int false "Category to move the items and subcategories to, 0 for none"
+// @Success 200 {object} models.Category
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]any
+// @Failure 422 {object} dtos.ValidationErrorResponse
+// @Router /api/categories/{id} [delete]
+func (cc *CategoryController) DeleteCategory(c *fiber.Ctx) error {
+	category, err := cc.loadCategory(c)
+	if category == nil {
+		return err
+	}
+	var reassignTo *uint
+	if value := c.Query("reassignTo"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 0)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "reassignTo must be a category id or 0",
+			})
+		}
+		target := uint(id)
+		reassignTo = &target
+	}
 
-	return c.JSON(marketItem)
-}
\ No newline at end of file
+	err = services.DeleteCategory(c.UserContext(), category, reassignTo)
+	switch {
+	case errors.Is(err, services.ErrCategoryInUse):
+		items, subcategories, _ := services.CategoryUsage(category.ID)
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":         "Category is in use, pass reassignTo to move its items and subcategories",
+			"items":         items,
+			"subcategories": subcategories,
+		})
+	case errors.Is(err, services.ErrInvalidReassignment):
+		return validationFailed(c, map[string]string{"reassignTo": err.Error()})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete category",
+		})
+	}
+	return c.JSON(category)
+}
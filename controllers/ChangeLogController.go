@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"api/database"
+	"api/dtos"
+	"api/middleware"
+	"api/models"
+	"api/services"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultChangeLogLimit = 100
+	maxChangeLogLimit     = 1000
+)
+
+type ChangeLogController struct{}
+
+func (cc *ChangeLogController) RegisterRoutes(app fiber.Router) {
+	log.Println("Setting up change log...")
+	group := app.Group("/changes")
+	group.Get("/", middleware.RequireScope("changes:read"), cc.GetChanges)
+}
+
+// @Summary Get the change log
+// @Description Get creates, updates and deletes in the order they happened. For incremental sync pass the cursor of the
+// @Description previous page as since until hasMore is false. Callers see changes of their own records, of the users
+// @Description they are a caregiver of and of shared records, only of the models the API key has the read scope of;
+// @Description admins see everything. Market items of other sellers are only in sync.
+// @Produce json
+// @Tags ChangeLog
+// @Param model query string false "Comma separated model names, e.g. BloodPressure,MarketItem"
+// @Param since query string false "Change id cursor (exclusive), RFC 3339 timestamp or YYYY-MM-DD date"
+// @Param limit query int false "Changes per page, at most 1000" default(100)
+// @Success 200 {object} dtos.ChangeLogPage
+// @Failure 400 {object} map[string]string
+// @Router /api/changes [get]
+func (cc *ChangeLogController) GetChanges(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", defaultChangeLogLimit)
+	if limit < 1 || limit > maxChangeLogLimit {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "limit must be between 1 and " + strconv.Itoa(maxChangeLogLimit),
+		})
+	}
+
+	page := dtos.ChangeLogPage{Changes: []models.ChangeLogEntry{}}
+	query := database.DB.Model(&models.ChangeLogEntry{})
+	if model := c.Query("model"); model != "" {
+		query = query.Where("model_name IN ?", strings.Split(model, ","))
+	}
+	if since := c.Query("since"); since != "" {
+		if cursor, err := strconv.ParseUint(since, 10, 64); err == nil {
+			query = query.Where("id > ?", cursor)
+			page.Cursor = cursor
+		} else {
+			sinceTime, err := parseTimeQuery(c, "since", false)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "since must be a change id, an RFC 3339 timestamp or a YYYY-MM-DD date",
+				})
+			}
+			query = query.Where("timestamp >= ?", *sinceTime)
+		}
+	}
+	if !middleware.IsAdmin(c) {
+		// Like sync, changes of a model need its read scope on top of changes:read
+		query = query.Where("model_name IN ?", services.ReadableChangeModels(syncCaller(c)))
+		if user := middleware.CurrentUser(c); user != nil {
+			readable, err := services.ReadableUserIds(uint(user.ID))
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to check access",
+				})
+			}
+			// Shared records are left to sync, which keeps drafts from everyone but their seller
+			query = query.Where("owner_id IS NULL OR owner_id IN ?", readable)
+		}
+	}
+
+	// One extra row tells whether there is another page
+	if err := query.Order("id").Limit(limit + 1).Find(&page.Changes).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get changes",
+		})
+	}
+	if len(page.Changes) > limit {
+		page.Changes = page.Changes[:limit]
+		page.HasMore = true
+	}
+	if len(page.Changes) > 0 {
+		page.Cursor = page.Changes[len(page.Changes)-1].ID
+	}
+	return c.JSON(page)
+}
@@ -3,6 +3,7 @@ package controllers
 import (
 	"api/database"
 	"api/dtos"
+	"api/middleware"
 	"api/models"
 	"api/services"
 	"context"
@@ -22,12 +23,12 @@ func NewChatController(chatService *services.ChatService) *ChatController {
 }
 
 func (cc *ChatController) RegisterRoutes(app fiber.Router) {
-	app.Post("/chat", cc.Chat)
+	app.Post("/chat", middleware.RequireScope("chat:write"), cc.Chat)
 	threads := app.Group("/chat/threads")
-	threads.Get("/", cc.ListThreads)
-	threads.Post("/", cc.CreateThread)
-	threads.Get("/:id", cc.GetThread)
-	threads.Post("/:id/messages", cc.AddMessage)
+	threads.Get("/", middleware.RequireScope("chat:read"), cc.ListThreads)
+	threads.Post("/", middleware.RequireScope("chat:write"), cc.CreateThread)
+	threads.Get("/:id", middleware.RequireScope("chat:read"), cc.GetThread)
+	threads.Post("/:id/messages", middleware.RequireScope("chat:write"), cc.AddMessage)
 }
 
 // chatMessagesToOpenAI converts stored messages to OpenAI format (user/assistant only).
@@ -76,11 +77,17 @@ func (cc *ChatController) Chat(c *fiber.Ctx) error {
 // @Summary List chat threads
 // @Produce json
 // @Tags Chat
+// @Param includeDeleted query bool false "Include records in the trash, admins only"
 // @Success 200 {array} dtos.ChatThreadResponse
+// @Failure 403 {object} map[string]string
 // @Router /api/chat/threads [get]
 func (cc *ChatController) ListThreads(c *fiber.Ctx) error {
 	var threads []models.ChatThread
-	if err := database.DB.Order("updated_at DESC").Find(&threads).Error; err != nil {
+	query, err := includeDeleted(c, database.DB.Order("updated_at DESC"))
+	if query == nil {
+		return err
+	}
+	if err := query.Find(&threads).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
 	list := make([]dtos.ChatThreadResponse, 0, len(threads))
@@ -107,7 +114,7 @@ func (cc *ChatController) CreateThread(c *fiber.Ctx) error {
 	var req dtos.CreateThreadRequest
 	_ = c.BodyParser(&req)
 	thread := models.ChatThread{Title: req.Title}
-	if err := database.DB.Create(&thread).Error; err != nil {
+	if err := database.DB.WithContext(c.UserContext()).Create(&thread).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
 	return c.Status(fiber.StatusCreated).JSON(dtos.ChatThreadResponse{
@@ -198,14 +205,14 @@ func (cc *ChatController) AddMessage(c *fiber.Ctx) error {
 	// Persist user message and assistant reply
 	userMsg := models.ChatMessage{ThreadID: id, Role: "user", Content: req.Message}
 	asstMsg := models.ChatMessage{ThreadID: id, Role: "assistant", Content: reply}
-	if err := database.DB.Create(&userMsg).Error; err != nil {
+	if err := database.DB.WithContext(c.UserContext()).Create(&userMsg).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
-	if err := database.DB.Create(&asstMsg).Error; err != nil {
+	if err := database.DB.WithContext(c.UserContext()).Create(&asstMsg).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
 	// Touch thread updated_at
-	database.DB.Model(&thread).Update("UpdatedAt", time.Now())
+	database.DB.WithContext(c.UserContext()).Model(&thread).Update("UpdatedAt", time.Now())
 
 	return c.JSON(dtos.AddMessageResponse{Reply: reply})
 }
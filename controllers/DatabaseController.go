@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"api/middleware"
+	"api/services"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type DatabaseController struct{}
+
+func (dc *DatabaseController) RegisterRoutes(app fiber.Router) {
+	log.Println("Setting up database stats...")
+	// Slow queries are logged with their values, which can belong to any user
+	app.Get("/admin/db", middleware.RequireScope("admin"), dc.GetDatabaseStats)
+}
+
+// @Summary Get database stats
+// @Description Connection pool usage and the most recent slow queries. On SQLite also the page count, free pages,
+// @Description write-ahead log size and the journal mode, synchronous level and busy timeout in effect.
+// @Produce json
+// @Tags Database
+// @Success 200 {object} dtos.DatabaseStatsResponse
+// @Router /api/admin/db [get]
+func (dc *DatabaseController) GetDatabaseStats(c *fiber.Ctx) error {
+	stats, err := services.DatabaseStats()
+	if err != nil {
+		log.Println("Failed to read database stats:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to read database stats",
+		})
+	}
+	return c.JSON(stats)
+}
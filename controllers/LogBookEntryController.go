@@ -2,57 +2,411 @@ package controllers
 
 import (
 	"api/database"
+	"api/dtos"
+	"api/middleware"
 	"api/models"
+	"api/services"
+	"bufio"
+	"encoding/json"
+	"fmt"
 	"log"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
-type LogBookEntryController struct{}
+const (
+	defaultLogBookPageSize = 50
+	maxLogBookPageSize     = 500
+	// streamHeartbeat keeps proxies from closing an idle stream and detects gone clients
+	streamHeartbeat   = 15 * time.Second
+	streamReplayBatch = 500
+	streamRetryMillis = 3000
+)
+
+type LogBookEntryController struct {
+	stream *services.LogBookStream
+}
+
+func NewLogBookEntryController(stream *services.LogBookStream) *LogBookEntryController {
+	return &LogBookEntryController{stream: stream}
+}
 
 func (uc *LogBookEntryController) RegisterRoutes(app fiber.Router) {
 
 	group := app.Group("/log_book")
-	group.Post("/", uc.CreateLogBookEntry)
-	group.Get("/", uc.GetLogBookEntries)
-	group.Delete("/:id", uc.DeleteLogBookEntry)
+	group.Post("/", middleware.RequireScope("logbook:write"), uc.CreateLogBookEntry)
+	group.Get("/", middleware.RequireScope("logbook:read"), uc.GetLogBookEntries)
+	group.Get("/stream", middleware.RequireScope("logbook:read"), uc.StreamLogBookEntries)
+	group.Get("/:id", middleware.RequireScope("logbook:read"), uc.GetLogBookEntry)
+	group.Put("/:id", middleware.RequireScope("logbook:write"), uc.UpdateLogBookEntry)
+	group.Delete("/:id", middleware.RequireScope("logbook:write"), uc.DeleteLogBookEntry)
+}
+
+// loadLogBookEntry finds the entry in the id param that the caller may read.
+// When the returned entry is nil the error response has already been written.
+func (uc *LogBookEntryController) loadLogBookEntry(c *fiber.Ctx) (*models.LogBookEntry, error) {
+	var logBookEntry models.LogBookEntry
+	if err := database.DB.First(&logBookEntry, c.Params("id")).Error; err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "LogBookEntry not found",
+		})
+	}
+	if user := middleware.CurrentUser(c); user != nil && logBookEntry.UserId != 0 && !middleware.IsAdmin(c) {
+		allowed, err := services.CanReadUserRecords(uint(user.ID), logBookEntry.UserId)
+		if err != nil || !allowed {
+			return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "LogBookEntry not found",
+			})
+		}
+	}
+	return &logBookEntry, nil
+}
+
+// filterLogBookEntries applies the level, category, from, to and q query parameters.
+// When the returned query is nil the error response has already been written.
+func filterLogBookEntries(c *fiber.Ctx, query *gorm.DB) (*gorm.DB, error) {
+	if level := c.Query("level"); level != "" {
+		levels := strings.Split(level, ",")
+		for _, l := range levels {
+			if !slices.Contains(services.LogLevels, l) {
+				return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "level must be one of " + strings.Join(services.LogLevels, ", "),
+				})
+			}
+		}
+		query = query.Where("level IN ?", levels)
+	}
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category IN ?", strings.Split(category, ","))
+	}
+	from, err := parseTimeQuery(c, "from", false)
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "from must be an RFC 3339 timestamp or a YYYY-MM-DD date",
+		})
+	}
+	to, err := parseTimeQuery(c, "to", true)
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "to must be an RFC 3339 timestamp or a YYYY-MM-DD date",
+		})
+	}
+	if from != nil {
+		query = query.Where("timestamp >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("timestamp <= ?", *to)
+	}
+	// Every word of the search has to appear in the message, in any case
+	for _, term := range strings.Fields(c.Query("q")) {
+		query = query.Where("LOWER(message) LIKE ? "+database.ActiveDialect.LikeEscape, services.LikePattern(strings.ToLower(term)))
+	}
+	return query, nil
 }
 
 // @Summary Get a list of LogBookEntries
-// @Description Get a list of all LogBookEntries
+// @Description Get a page of the LogBookEntries of the current user, or of userId for caregivers, together with system entries. Newest first.
 // @Produce json
 // @Tags LogBookEntry
-// @Success 200 {array} models.LogBookEntry
+// @Param userId query int false "User whose entries to list"
+// @Param level query string false "Comma separated levels (debug, info, warn, error)"
+// @Param category query string false "Comma separated categories"
+// @Param from query string false "Earliest timestamp, RFC 3339 or YYYY-MM-DD"
+// @Param to query string false "Latest timestamp, RFC 3339 or YYYY-MM-DD"
+// @Param q query string false "Words that must all appear in the message"
+// @Param page query int false "Page number, starting at 1"
+// @Param pageSize query int false "Entries per page, at most 500" default(50)
+// @Param includeDeleted query bool false "Include records in the trash, admins only"
+// @Success 200 {object} dtos.LogBookEntryPage
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Router /api/log_book [get]
 func (uc *LogBookEntryController) GetLogBookEntries(c *fiber.Ctx) error {
-	var logBookEntries []models.LogBookEntry
+	ownerId, ok, err := resolveRecordOwner(c)
+	if !ok {
+		return err
+	}
+	page := c.QueryInt("page", 1)
+	pageSize := c.QueryInt("pageSize", defaultLogBookPageSize)
+	if page < 1 || pageSize < 1 || pageSize > maxLogBookPageSize {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "page must be at least 1 and pageSize between 1 and " + strconv.Itoa(maxLogBookPageSize),
+		})
+	}
+
+	query, err := includeDeleted(c, database.DB.Model(&models.LogBookEntry{}))
+	if query == nil {
+		return err
+	}
+	if ownerId != 0 {
+		// System entries (user 0) are shared by everyone
+		query = query.Where("user_id IN ?", []uint{ownerId, 0})
+	}
+	query, err = filterLogBookEntries(c, query)
+	if query == nil {
+		return err
+	}
+
+	result := dtos.LogBookEntryPage{Items: []models.LogBookEntry{}, Page: page, PageSize: pageSize}
+	if err := query.Count(&result.Total).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get LogBookEntries",
+		})
+	}
+	err = query.Order("timestamp DESC").Order("id DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).
+		Find(&result.Items).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get LogBookEntries",
+		})
+	}
+	return c.JSON(result)
+}
+
+// @Summary Stream new LogBookEntries
+// @Description Server-Sent Events stream of LogBookEntries as they are created, as "log_book_entry" events with the entry id as event id.
+// @Description A reconnecting client sends the Last-Event-ID header (or lastEventId query) and first gets the entries it missed.
+// @Produce text/event-stream
+// @Tags LogBookEntry
+// @Param userId query int false "User whose entries to stream"
+// @Param level query string false "Comma separated levels (debug, info, warn, error)"
+// @Param category query string false "Comma separated categories"
+// @Param Last-Event-ID header int false "Id of the last entry received"
+// @Param lastEventId query int false "Id of the last entry received, for clients that cannot set headers"
+// @Success 200 {string} string "text/event-stream"
+// @Failure 400 {object} map[string]string
+// @Router /api/log_book/stream [get]
+func (uc *LogBookEntryController) StreamLogBookEntries(c *fiber.Ctx) error {
+	ownerId, ok, err := resolveRecordOwner(c)
+	if !ok {
+		return err
+	}
+	filter := services.LogBookFilter{OwnerId: ownerId}
+	if level := c.Query("level"); level != "" {
+		filter.Levels = strings.Split(level, ",")
+		for _, l := range filter.Levels {
+			if !slices.Contains(services.LogLevels, l) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "level must be one of " + strings.Join(services.LogLevels, ", "),
+				})
+			}
+		}
+	}
+	if category := c.Query("category"); category != "" {
+		filter.Categories = strings.Split(category, ",")
+	}
+	lastEventId := c.Get("Last-Event-ID", c.Query("lastEventId"))
+	var lastId int
+	if lastEventId != "" {
+		if lastId, err = strconv.Atoi(lastEventId); err != nil || lastId < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Last-Event-ID must be an entry id",
+			})
+		}
+	}
+
+	// Subscribe before replaying, so nothing created in between is missed. Duplicates are skipped by id.
+	entries, unsubscribe := uc.stream.Subscribe(filter)
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+
+		fmt.Fprintf(w, "retry: %d\n\n", streamRetryMillis)
+		if lastEventId != "" {
+			for {
+				var missed []models.LogBookEntry
+				err := database.DB.Scopes(logBookFilterScope(filter)).Where("id > ?", lastId).
+					Order("id").Limit(streamReplayBatch).Find(&missed).Error
+				if err != nil {
+					log.Println("Failed to replay LogBookEntries:", err)
+					return
+				}
+				for _, entry := range missed {
+					if writeLogBookEvent(w, entry) != nil {
+						return
+					}
+					lastId = entry.ID
+				}
+				if len(missed) < streamReplayBatch {
+					break
+				}
+			}
+		}
+		if w.Flush() != nil {
+			return
+		}
 
-	database.DB.Find(&logBookEntries)
-	return c.JSON(logBookEntries)
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case entry, ok := <-entries:
+				if !ok {
+					// Fell too far behind, the client reconnects and replays
+					return
+				}
+				if entry.ID <= lastId {
+					continue
+				}
+				if writeLogBookEvent(w, entry) != nil || w.Flush() != nil {
+					return
+				}
+				lastId = entry.ID
+			case <-heartbeat.C:
+				if _, err := w.WriteString(": ping\n\n"); err != nil || w.Flush() != nil {
+					return
+				}
+			}
+		}
+	})
+	return nil
+}
+
+// logBookFilterScope selects the entries passing filter.
+func logBookFilterScope(filter services.LogBookFilter) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if len(filter.Levels) > 0 {
+			db = db.Where("level IN ?", filter.Levels)
+		}
+		if len(filter.Categories) > 0 {
+			db = db.Where("category IN ?", filter.Categories)
+		}
+		if filter.OwnerId != 0 {
+			db = db.Where("user_id IN ?", []uint{filter.OwnerId, 0})
+		}
+		return db
+	}
+}
+
+func writeLogBookEvent(w *bufio.Writer, entry models.LogBookEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: log_book_entry\ndata: %s\n\n", entry.ID, data)
+	return err
+}
+
+// @Summary Get a LogBookEntry
+// @Description Get a LogBookEntry by ID
+// @Produce json
+// @Tags LogBookEntry
+// @Param id path int true "LogBookEntry ID"
+// @Success 200 {object} models.LogBookEntry
+// @Failure 404 {object} map[string]string
+// @Router /api/log_book/{id} [get]
+func (uc *LogBookEntryController) GetLogBookEntry(c *fiber.Ctx) error {
+	logBookEntry, err := uc.loadLogBookEntry(c)
+	if logBookEntry == nil {
+		return err
+	}
+	return c.JSON(logBookEntry)
 }
 
 // @Summary Create a new LogBookEntry
-// @Description Create a new LogBookEntry
+// @Description Create a new LogBookEntry. level defaults to info and timestamp to now.
 // @Accept json
 // @Produce json
 // @Tags LogBookEntry
 // @Param log_book body dtos.CreateLogBookEntryRequest true "LogBookEntry object"
-// @Success 200 {object} models.LogBookEntry
+// @Success 201 {object} models.LogBookEntry
+// @Failure 422 {object} dtos.ValidationErrorResponse
 // @Router /api/log_book [post]
 func (uc *LogBookEntryController) CreateLogBookEntry(c *fiber.Ctx) error {
-	var logBookEntry models.LogBookEntry
-	// Parse the request body into the User struct
-	if err := c.BodyParser(&logBookEntry); err != nil {
+	var request dtos.CreateLogBookEntryRequest
+	if err := c.BodyParser(&request); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Cannot parse LogBookEntry JSON",
 		})
 	}
-	logBookEntry.Timestamp = time.Now()
+	if request.Level == "" {
+		request.Level = "info"
+	}
+	if fields := services.ValidateLogBookEntry(request.Message, request.Level, request.Category); fields != nil {
+		return validationFailed(c, fields)
+	}
+	ownerId, ok, err := resolveWriteOwner(c, request.UserId)
+	if !ok {
+		return err
+	}
+	logBookEntry := models.LogBookEntry{
+		Message:   request.Message,
+		Level:     request.Level,
+		Category:  request.Category,
+		Timestamp: time.Now(),
+		UserId:    ownerId,
+	}
+	if request.Timestamp != nil {
+		logBookEntry.Timestamp = *request.Timestamp
+	}
+
+	if err := database.DB.WithContext(c.UserContext()).Create(&logBookEntry).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create LogBookEntry",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(logBookEntry)
+}
 
-	database.DB.Create(&logBookEntry)
+// @Summary Update a LogBookEntry
+// @Description Update the fields that are set in the request
+// @Accept json
+// @Produce json
+// @Tags LogBookEntry
+// @Param id path int true "LogBookEntry ID"
+// @Param log_book body dtos.UpdateLogBookEntryRequest true "Changed fields"
+// @Success 200 {object} models.LogBookEntry
+// @Failure 403 {object} map[string]string
+// @Failure 422 {object} dtos.ValidationErrorResponse
+// @Router /api/log_book/{id} [put]
+func (uc *LogBookEntryController) UpdateLogBookEntry(c *fiber.Ctx) error {
+	logBookEntry, err := uc.loadLogBookEntry(c)
+	if logBookEntry == nil {
+		return err
+	}
+	if !canModifyRecord(c, logBookEntry.UserId) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Not allowed to update this LogBookEntry",
+		})
+	}
+	var request dtos.UpdateLogBookEntryRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse LogBookEntry JSON",
+		})
+	}
+	if request.Message != nil {
+		logBookEntry.Message = *request.Message
+	}
+	if request.Level != nil {
+		logBookEntry.Level = *request.Level
+	}
+	if request.Category != nil {
+		logBookEntry.Category = *request.Category
+	}
+	if request.Timestamp != nil {
+		logBookEntry.Timestamp = *request.Timestamp
+	}
+	if fields := services.ValidateLogBookEntry(logBookEntry.Message, logBookEntry.Level, logBookEntry.Category); fields != nil {
+		return validationFailed(c, fields)
+	}
 
+	if err := database.DB.WithContext(c.UserContext()).Save(logBookEntry).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update LogBookEntry",
+		})
+	}
 	return c.JSON(logBookEntry)
 }
 
@@ -82,8 +436,13 @@ func (uc *LogBookEntryController) DeleteLogBookEntry(c *fiber.Ctx) error {
 		})
 	}
 	log.Println("LogBookEntry found: ", logBookEntry)
+	if !canModifyRecord(c, logBookEntry.UserId) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Not allowed to delete this LogBookEntry",
+		})
+	}
 
-	database.DB.Delete(&logBookEntry)
+	database.DB.WithContext(c.UserContext()).Delete(&logBookEntry)
 
 	log.Println("LogBookEntry deleted.")
 
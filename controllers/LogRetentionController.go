@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"api/database"
+	"api/dtos"
+	"api/middleware"
+	"api/models"
+	"api/services"
+	"errors"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type LogRetentionController struct {
+	retention *services.LogRetentionService
+}
+
+func NewLogRetentionController(retention *services.LogRetentionService) *LogRetentionController {
+	return &LogRetentionController{retention: retention}
+}
+
+func (lc *LogRetentionController) RegisterRoutes(app fiber.Router) {
+	log.Println("Setting up log retention...")
+	// Retention applies to the entries of every user, so it is for admins only
+	group := app.Group("/log_retention")
+	group.Get("/policies", middleware.RequireScope("admin"), lc.GetPolicies)
+	group.Post("/policies", middleware.RequireScope("admin"), lc.CreatePolicy)
+	group.Put("/policies/:id", middleware.RequireScope("admin"), lc.UpdatePolicy)
+	group.Delete("/policies/:id", middleware.RequireScope("admin"), lc.DeletePolicy)
+	group.Get("/status", middleware.RequireScope("admin"), lc.GetStatus)
+	group.Post("/purge", middleware.RequireScope("admin"), lc.Purge)
+}
+
+// applyPolicyRequest copies the request onto policy. When ok is false the error response has already been written.
+func (lc *LogRetentionController) applyPolicyRequest(c *fiber.Ctx, policy *models.LogRetentionPolicy) (ok bool, err error) {
+	var request dtos.LogRetentionPolicyRequest
+	if err := c.BodyParser(&request); err != nil {
+		return false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+	policy.Level = request.Level
+	policy.Category = request.Category
+	policy.MaxAgeDays = request.MaxAgeDays
+	policy.Archive = request.Archive
+	if fields := services.ValidateLogRetentionPolicy(*policy); fields != nil {
+		return false, validationFailed(c, fields)
+	}
+	return true, nil
+}
+
+func (lc *LogRetentionController) savePolicy(c *fiber.Ctx, policy *models.LogRetentionPolicy, status int) error {
+	if err := database.DB.WithContext(c.UserContext()).Save(policy).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "A policy for this level and category already exists",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save retention policy",
+		})
+	}
+	return c.Status(status).JSON(policy)
+}
+
+// @Summary Get log retention policies
+// @Description Get the policies that decide how long LogBookEntries are kept. Entries no policy matches are kept forever.
+// @Produce json
+// @Tags LogRetention
+// @Param includeDeleted query bool false "Include records in the trash, admins only"
+// @Success 200 {array} models.LogRetentionPolicy
+// @Failure 403 {object} map[string]string
+// @Router /api/log_retention/policies [get]
+func (lc *LogRetentionController) GetPolicies(c *fiber.Ctx) error {
+	var policies []models.LogRetentionPolicy
+	query, err := includeDeleted(c, database.DB.Order("id"))
+	if query == nil {
+		return err
+	}
+	if err := query.Find(&policies).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get retention policies",
+		})
+	}
+	return c.JSON(policies)
+}
+
+// @Summary Create a log retention policy
+// @Description Keep entries of level and/or category (empty matches any) for maxAgeDays. The most specific matching policy wins:
+// @Description level and category, then category, then level, then the default policy with both empty.
+// @Accept json
+// @Produce json
+// @Tags LogRetention
+// @Param policy body dtos.LogRetentionPolicyRequest true "Policy"
+// @Success 201 {object} models.LogRetentionPolicy
+// @Failure 409 {object} map[string]string
+// @Failure 422 {object} dtos.ValidationErrorResponse
+// @Router /api/log_retention/policies [post]
+func (lc *LogRetentionController) CreatePolicy(c *fiber.Ctx) error {
+	var policy models.LogRetentionPolicy
+	if ok, err := lc.applyPolicyRequest(c, &policy); !ok {
+		return err
+	}
+	return lc.savePolicy(c, &policy, fiber.StatusCreated)
+}
+
+// @Summary Update a log retention policy
+// @Description Replace a log retention policy
+// @Accept json
+// @Produce json
+// @Tags LogRetention
+// @Param id path int true "Policy ID"
+// @Param policy body dtos.LogRetentionPolicyRequest true "Policy"
+// @Success 200 {object} models.LogRetentionPolicy
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 422 {object} dtos.ValidationErrorResponse
+// @Router /api/log_retention/policies/{id} [put]
+func (lc *LogRetentionController) UpdatePolicy(c *fiber.Ctx) error {
+	var policy models.LogRetentionPolicy
+	if err := database.DB.First(&policy, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Retention policy not found",
+		})
+	}
+	if ok, err := lc.applyPolicyRequest(c, &policy); !ok {
+		return err
+	}
+	return lc.savePolicy(c, &policy, fiber.StatusOK)
+}
+
+// @Summary Delete a log retention policy
+// @Description Delete a log retention policy. Entries it covered fall back to a less specific policy.
+// @Produce json
+// @Tags LogRetention
+// @Param id path int true "Policy ID"
+// @Success 200 {object} models.LogRetentionPolicy
+// @Failure 404 {object} map[string]string
+// @Router /api/log_retention/policies/{id} [delete]
+func (lc *LogRetentionController) DeletePolicy(c *fiber.Ctx) error {
+	var policy models.LogRetentionPolicy
+	if err := database.DB.First(&policy, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Retention policy not found",
+		})
+	}
+	if err := database.DB.WithContext(c.UserContext()).Delete(&policy).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete retention policy",
+		})
+	}
+	return c.JSON(policy)
+}
+
+// @Summary Get log retention status
+// @Description Get the row count (and on SQLite the size) of every table, the next scheduled purge and the result of the last one
+// @Produce json
+// @Tags LogRetention
+// @Success 200 {object} dtos.LogRetentionStatusResponse
+// @Router /api/log_retention/status [get]
+func (lc *LogRetentionController) GetStatus(c *fiber.Ctx) error {
+	status, err := lc.retention.Status()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get table sizes",
+		})
+	}
+	return c.JSON(status)
+}
+
+// @Summary Purge expired log entries
+// @Description Enforce the retention policies now instead of waiting for the next scheduled purge
+// @Produce json
+// @Tags LogRetention
+// @Success 200 {object} dtos.LogPurgeResult
+// @Failure 500 {object} dtos.LogPurgeResult
+// @Router /api/log_retention/purge [post]
+func (lc *LogRetentionController) Purge(c *fiber.Ctx) error {
+	result := lc.retention.Purge()
+	if result.Error != "" {
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+	return c.JSON(result)
+}
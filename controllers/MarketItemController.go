@@ -2,113 +2,678 @@ package controllers
 
 import (
 	"api/database"
+	"api/dtos"
+	"api/middleware"
 	"api/models"
+	"api/services"
+	"errors"
 	"log"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-type MarketItemController struct{}
+const (
+	defaultMarketItemPageSize = 50
+	maxMarketItemPageSize     = 500
+)
+
+// Listings show what can still be bought unless the status parameter asks for more
+var defaultMarketItemStatuses = []string{services.MarketItemActive, services.MarketItemReserved}
+
+type MarketItemController struct {
+	lifecycle *services.MarketLifecycleService
+}
+
+func NewMarketItemController(lifecycle *services.MarketLifecycleService) *MarketItemController {
+	return &MarketItemController{lifecycle: lifecycle}
+}
 
 func (mc *MarketItemController) RegisterRoutes(app fiber.Router) {
-	log.Println("Setting up user logs...")
+	log.Println("Setting up market items...")
 	group := app.Group("/marketitem")
-	group.Post("/", mc.CreateMarketItem)
-	group.Get("/", mc.GetMarketItems)
-	group.Delete("/", mc.DeleteMarketItem)
-	group.Put("/", mc.UpdateMarketItem)
+	group.Post("/", middleware.RequireScope("marketitem:write"), mc.CreateMarketItem)
+	group.Get("/", middleware.RequireScope("marketitem:read"), mc.GetMarketItems)
+	group.Get("/stats", middleware.RequireScope("marketitem:read"), mc.GetSalesStats)
+	group.Get("/:id", middleware.RequireScope("marketitem:read"), mc.GetMarketItem)
+	group.Get("/:id/price_history", middleware.RequireScope("marketitem:read"), mc.GetPriceHistory)
+	group.Post("/:id/publish", middleware.RequireScope("marketitem:write"), mc.PublishMarketItem)
+	group.Post("/:id/reserve", middleware.RequireScope("marketitem:write"), mc.ReserveMarketItem)
+	group.Post("/:id/release", middleware.RequireScope("marketitem:write"), mc.ReleaseMarketItem)
+	group.Post("/:id/sell", middleware.RequireScope("marketitem:write"), mc.SellMarketItem)
+	group.Post("/:id/withdraw", middleware.RequireScope("marketitem:write"), mc.WithdrawMarketItem)
+	group.Put("/:id", middleware.RequireScope("marketitem:write"), mc.UpdateMarketItem)
+	group.Patch("/:id", middleware.RequireScope("marketitem:write"), mc.PatchMarketItem)
+	group.Delete("/:id", middleware.RequireScope("marketitem:write"), mc.DeleteMarketItem)
+}
+
+// loadMarketItem finds the item in the id param, with its category and seller. Drafts are only
+// found for their seller. When the returned item is nil the error response has already been written.
+func (mc *MarketItemController) loadMarketItem(c *fiber.Ctx) (*models.MarketItem, error) {
+	var marketItem models.MarketItem
+	err := preloadMarketItemSummaries(database.DB).First(&marketItem, c.Params("id")).Error
+	if err != nil || (marketItem.Status == services.MarketItemDraft && !canModifyRecord(c, marketItem.UserId)) {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Market item not found",
+		})
+	}
+	return &marketItem, nil
+}
+
+func toMarketItemResponse(marketItem models.MarketItem) dtos.MarketItemResponse {
+	response := dtos.MarketItemResponse{
+		ID:          marketItem.ID,
+		CreatedAt:   marketItem.CreatedAt,
+		UpdatedAt:   marketItem.UpdatedAt,
+		Title:       marketItem.Title,
+		Description: marketItem.Description,
+		Price:       marketItem.Price,
+		CategoryId:  marketItem.CategoryId,
+		UserId:      marketItem.UserId,
+		Status:      marketItem.Status,
+		ReducedFrom: services.ReducedFrom(marketItem),
+		PublishedAt: marketItem.PublishedAt,
+		SoldAt:      marketItem.SoldAt,
+	}
+	if marketItem.Status == services.MarketItemReserved {
+		response.ReservedById = marketItem.ReservedById
+		response.ReservedUntil = marketItem.ReservedUntil
+	}
+	if marketItem.DeletedAt.Valid {
+		response.DeletedAt = &marketItem.DeletedAt.Time
+	}
+	if marketItem.Category.ID != 0 {
+		response.Category = &dtos.CategorySummary{ID: marketItem.Category.ID, Title: marketItem.Category.Title, Slug: marketItem.Category.Slug}
+	}
+	if marketItem.User.ID != 0 {
+		response.Seller = &dtos.SellerSummary{ID: marketItem.User.ID, Name: marketItem.User.Name}
+	}
+	response.Images = make([]dtos.MarketItemImageResponse, 0, len(marketItem.Images))
+	for _, image := range marketItem.Images {
+		response.Images = append(response.Images, toMarketItemImageResponse(image))
+	}
+	return response
+}
+
+// preloadMarketItemSummaries loads what toMarketItemResponse shows of the category, seller, images
+// and price history.
+func preloadMarketItemSummaries(query *gorm.DB) *gorm.DB {
+	return query.
+		Preload("Category", func(db *gorm.DB) *gorm.DB { return db.Select("id", "title", "slug") }).
+		Preload("User", func(db *gorm.DB) *gorm.DB { return db.Select("id", "name") }).
+		Preload("Images", func(db *gorm.DB) *gorm.DB { return db.Order("position, id") }).
+		Preload("PriceChanges", func(db *gorm.DB) *gorm.DB { return db.Order("id") })
+}
+
+// filterMarketItems applies the q, minPrice, maxPrice, categoryId, sellerId and status query
+// parameters. When the returned query is nil the error response has already been written.
+func filterMarketItems(c *fiber.Ctx, query *gorm.DB) (*gorm.DB, error) {
+	statuses := defaultMarketItemStatuses
+	if c.Query("status") != "" {
+		statuses = strings.Split(c.Query("status"), ",")
+		for _, status := range statuses {
+			if !services.ValidMarketItemStatus(status) {
+				return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "status must be a comma separated list of " + strings.Join(services.MarketItemStatuses, ", "),
+				})
+			}
+		}
+	}
+	query = query.Where("status IN ?", statuses)
+	// Drafts are only listed for their seller
+	if user := middleware.CurrentUser(c); user != nil && !middleware.IsAdmin(c) {
+		query = query.Where("status <> ? OR user_id = ?", services.MarketItemDraft, user.ID)
+	}
+	// Every word of the search has to appear in the title or the description, in any case
+	for _, term := range strings.Fields(c.Query("q")) {
+		pattern := services.LikePattern(strings.ToLower(term))
+		escape := database.ActiveDialect.LikeEscape
+		query = query.Where("LOWER(title) LIKE ? "+escape+" OR LOWER(description) LIKE ? "+escape, pattern, pattern)
+	}
+	minPrice, err := parseFloatQuery(c, "minPrice")
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "minPrice must be a number",
+		})
+	}
+	maxPrice, err := parseFloatQuery(c, "maxPrice")
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "maxPrice must be a number",
+		})
+	}
+	if minPrice != nil && maxPrice != nil && *minPrice > *maxPrice {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "minPrice must not be above maxPrice",
+		})
+	}
+	if minPrice != nil {
+		query = query.Where("price >= ?", *minPrice)
+	}
+	if maxPrice != nil {
+		query = query.Where("price <= ?", *maxPrice)
+	}
+	categoryIds, err := parseIdsQuery(c, "categoryId")
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "categoryId must be a comma separated list of ids",
+		})
+	}
+	if categoryIds != nil {
+		// A category includes the ones below it, Furniture lists the chairs too
+		if categoryIds, err = services.CategoriesWithin(categoryIds); err != nil {
+			return nil, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to load categories",
+			})
+		}
+		query = query.Where("category_id IN ?", categoryIds)
+	}
+	sellerIds, err := parseIdsQuery(c, "sellerId")
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "sellerId must be a comma separated list of ids",
+		})
+	}
+	if sellerIds != nil {
+		query = query.Where("user_id IN ?", sellerIds)
+	}
+	return query, nil
 }
 
 // @Summary Get a list of market items
-// @Description Get a list of all market items
+// @Description Search market items, with their category, seller and images. Pass the returned nextCursor as cursor to get the
+// @Description next page, with the same filters and sort; total counts every matching item. Only active and reserved items
+// @Description are listed unless status says otherwise, and drafts only for their seller.
 // @Produce json
 // @Tags MarketItem
-// @Success 200 {array} models.MarketItem
-// @Router /api/marketItem [get]
-func (uc *MarketItemController) GetMarketItems(c *fiber.Ctx) error {
+// @Param q query string false "Words that must all appear in the title or description"
+// @Param minPrice query number false "Lowest price"
+// @Param maxPrice query number false "Highest price"
+// @Param categoryId query string false "Comma separated category ids, each including the categories below it"
+// @Param sellerId query string false "Comma separated user ids of sellers"
+// @Param status query string false "Comma separated statuses: draft, active, reserved, sold, withdrawn" default(active,reserved)
+// @Param sort query string false "Order of the items" Enums(newest, oldest, price_asc, price_desc, title) default(newest)
+// @Param cursor query string false "nextCursor of the previous page"
+// @Param limit query int false "Items per page, at most 500" default(50)
+// @Param includeDeleted query bool false "Include records in the trash, admins only"
+// @Success 200 {object} dtos.MarketItemPage
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /api/marketitem [get]
+func (mc *MarketItemController) GetMarketItems(c *fiber.Ctx) error {
+	sortName := c.Query("sort", "newest")
+	sort, ok := services.MarketItemSorts[sortName]
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "sort must be one of newest, oldest, price_asc, price_desc, title",
+		})
+	}
+	limit := c.QueryInt("limit", defaultMarketItemPageSize)
+	if limit < 1 || limit > maxMarketItemPageSize {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "limit must be between 1 and " + strconv.Itoa(maxMarketItemPageSize),
+		})
+	}
+
+	query, err := includeDeleted(c, database.DB.Model(&models.MarketItem{}))
+	if query == nil {
+		return err
+	}
+	if query, err = filterMarketItems(c, query); query == nil {
+		return err
+	}
+	page := dtos.MarketItemPage{Items: []dtos.MarketItemResponse{}}
+	if err := query.Session(&gorm.Session{}).Count(&page.Total).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to count market items",
+		})
+	}
+
+	if encoded := c.Query("cursor"); encoded != "" {
+		cursor, err := services.DecodeMarketItemCursor(encoded, sortName)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "cursor is not from a page with this sort",
+			})
+		}
+		query = sort.After(query, cursor)
+	}
 	var marketItems []models.MarketItem
+	// One more than asked for tells whether there is a next page
+	err = preloadMarketItemSummaries(sort.Order(query)).Limit(limit + 1).Find(&marketItems).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list market items",
+		})
+	}
+	if len(marketItems) > limit {
+		marketItems = marketItems[:limit]
+		page.HasMore = true
+		page.NextCursor = services.EncodeMarketItemCursor(sort.Cursor(sortName, marketItems[limit-1]))
+	}
+	for _, marketItem := range marketItems {
+		page.Items = append(page.Items, toMarketItemResponse(marketItem))
+	}
+	return c.JSON(page)
+}
 
-	database.DB.Find(&marketItems)
-	return c.JSON(marketItems)
+// @Summary Get a market item
+// @Description Get a market item by ID, with its category, seller and images. Drafts are only found for their seller.
+// @Produce json
+// @Tags MarketItem
+// @Param id path int true "Market item ID"
+// @Success 200 {object} dtos.MarketItemResponse
+// @Failure 404 {object} map[string]string
+// @Router /api/marketitem/{id} [get]
+func (mc *MarketItemController) GetMarketItem(c *fiber.Ctx) error {
+	marketItem, err := mc.loadMarketItem(c)
+	if marketItem == nil {
+		return err
+	}
+	return c.JSON(toMarketItemResponse(*marketItem))
 }
 
 // @Summary Create a new market item
-// @Description Create a new market item
+// @Description Create a new market item, sold by the current user. Only admins may list items for another user.
+// @Description The item is listed right away, unless draft is set.
 // @Accept json
 // @Produce json
 // @Tags MarketItem
-// @Param user body dtos.CreateMarketItemRequest true "MarketItem object"
-// @Success 200 {object} models.MarketItem
-// @Router /api/marketItem [post]
-func (uc *MarketItemController) CreateMarketItem(c *fiber.Ctx) error {
-	var marketItem models.MarketItem
-	// Parse the request body into the User struct
-	if err := c.BodyParser(&marketItem); err != nil {
+// @Param marketItem body dtos.CreateMarketItemRequest true "MarketItem object"
+// @Success 201 {object} dtos.MarketItemResponse
+// @Failure 403 {object} map[string]string
+// @Failure 422 {object} dtos.ValidationErrorResponse
+// @Router /api/marketitem [post]
+func (mc *MarketItemController) CreateMarketItem(c *fiber.Ctx) error {
+	var request dtos.CreateMarketItemRequest
+	if err := c.BodyParser(&request); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Cannot parse JSON",
 		})
 	}
+	ownerId, ok, err := resolveWriteOwner(c, request.UserId)
+	if !ok {
+		return err
+	}
+	marketItem := models.MarketItem{
+		Title:       request.Title,
+		Description: request.Description,
+		Price:       request.Price,
+		CategoryId:  request.CategoryId,
+		UserId:      ownerId,
+		Status:      services.MarketItemDraft,
+	}
+	if !request.Draft {
+		now := time.Now()
+		marketItem.Status, marketItem.PublishedAt = services.MarketItemActive, &now
+	}
+	return mc.saveMarketItem(c, &marketItem, fiber.StatusCreated)
+}
 
-	database.DB.Create(&marketItem)
+// @Summary Replace a market item
+// @Description Replace all fields of a market item. Only the seller may change an item, and only admins may move it to another seller.
+// @Accept json
+// @Produce json
+// @Tags MarketItem
+// @Param id path int true "Market item ID"
+// @Param marketItem body dtos.UpdateMarketItemRequest true "Market item object"
+// @Success 200 {object} dtos.MarketItemResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 422 {object} dtos.ValidationErrorResponse
+// @Router /api/marketitem/{id} [put]
+func (mc *MarketItemController) UpdateMarketItem(c *fiber.Ctx) error {
+	marketItem, err := mc.loadMarketItem(c)
+	if marketItem == nil {
+		return err
+	}
+	var request dtos.UpdateMarketItemRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+	if ok, err := mc.checkSeller(c, marketItem, request.UserId); !ok {
+		return err
+	}
 
-	return c.JSON(marketItem)
+	marketItem.Title = request.Title
+	marketItem.Description = request.Description
+	marketItem.Price = request.Price
+	marketItem.CategoryId = request.CategoryId
+	return mc.saveMarketItem(c, marketItem, fiber.StatusOK)
 }
 
-// @Summary Delete a market item
-// @Description Delete a market item
+// @Summary Update a market item
+// @Description Change some fields of a market item. Only the seller may change an item, and only admins may move it to another seller.
 // @Accept json
 // @Produce json
 // @Tags MarketItem
 // @Param id path int true "Market item ID"
-// @Success 200 {object} models.MarketItem
-// @Router /api/marketItem [delete]
-func (uc *MarketItemController) DeleteMarketItem(c *fiber.Ctx) error {
-	var marketItem models.MarketItem
-	id := c.Params("id")
-	if err := database.DB.First(&marketItem, id).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Market item not found",
+// @Param marketItem body dtos.PatchMarketItemRequest true "Fields to change"
+// @Success 200 {object} dtos.MarketItemResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 422 {object} dtos.ValidationErrorResponse
+// @Router /api/marketitem/{id} [patch]
+func (mc *MarketItemController) PatchMarketItem(c *fiber.Ctx) error {
+	marketItem, err := mc.loadMarketItem(c)
+	if marketItem == nil {
+		return err
+	}
+	var request dtos.PatchMarketItemRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
 		})
 	}
-	err := database.DB.Delete(marketItem)
-	if (err != nil) {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Failed to delete item",
+	var sellerId uint
+	if request.UserId != nil {
+		sellerId = *request.UserId
+	}
+	if ok, err := mc.checkSeller(c, marketItem, sellerId); !ok {
+		return err
+	}
+
+	if request.Title != nil {
+		marketItem.Title = *request.Title
+	}
+	if request.Description != nil {
+		marketItem.Description = *request.Description
+	}
+	if request.Price != nil {
+		marketItem.Price = *request.Price
+	}
+	if request.CategoryId != nil {
+		marketItem.CategoryId = *request.CategoryId
+	}
+	return mc.saveMarketItem(c, marketItem, fiber.StatusOK)
+}
+
+// checkSeller makes sure the caller may change marketItem and, when sellerId is set, hands the
+// item over to that seller. When ok is false the error response has already been written.
+func (mc *MarketItemController) checkSeller(c *fiber.Ctx, marketItem *models.MarketItem, sellerId uint) (ok bool, err error) {
+	if !canModifyRecord(c, marketItem.UserId) {
+		return false, c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the seller may change this market item",
 		})
 	}
-	return c.JSON(marketItem)
+	if sellerId == 0 || sellerId == marketItem.UserId {
+		return true, nil
+	}
+	if user := middleware.CurrentUser(c); user != nil && !middleware.IsAdmin(c) {
+		return false, c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Not allowed to move a market item to another seller",
+		})
 	}
+	marketItem.UserId = sellerId
+	return true, nil
+}
 
-// @Summary Update a market item
-// @Description Update an existing market item
-// @Accept json
+// saveMarketItem validates and stores a new or edited item and responds with it in status.
+func (mc *MarketItemController) saveMarketItem(c *fiber.Ctx, marketItem *models.MarketItem, status int) error {
+	fields, err := services.ValidateMarketItem(marketItem)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check the category and seller",
+		})
+	}
+	if fields != nil {
+		return validationFailed(c, fields)
+	}
+
+	// The preloaded category and seller are only for the response, they must not be written back
+	db := database.DB.WithContext(c.UserContext()).Omit(clause.Associations)
+	if marketItem.ID == 0 {
+		err = db.Create(marketItem).Error
+	} else {
+		err = db.Model(marketItem).Select("title", "description", "price", "category_id", "user_id").Updates(marketItem).Error
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save market item",
+		})
+	}
+	// Load it again for the category and seller it has now
+	var saved models.MarketItem
+	if err := preloadMarketItemSummaries(database.DB).First(&saved, marketItem.ID).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load market item",
+		})
+	}
+	return c.Status(status).JSON(toMarketItemResponse(saved))
+}
+
+// @Summary Delete a market item
+// @Description Move a market item to the trash. Only the seller may delete an item. Its images are kept until it is purged from the trash,
+// @Description which happens by itself after MARKET_TRASH_RETENTION (30 days by default).
 // @Produce json
 // @Tags MarketItem
-// @Param id path int true "MarketItemId ID"
-// @Param user body dtos.UpdateMarketItemRequest true "Updated market item object"
-// @Success 200 {object} models.MarketItem
-// @Failure 400 {object} fiber.Map "Bad Request"
-// @Failure 404 {object} fiber.Map "Market Item Not Found"
-// @Router /api/marketItem/{id} [put]
-
-func (uc *MarketItemController) UpdateMarketItem(c *fiber.Ctx) error {
-	id := c.Params("id")
-	var marketItem models.MarketItem
+// @Param id path int true "Market item ID"
+// @Success 200 {object} dtos.MarketItemResponse
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/marketitem/{id} [delete]
+func (mc *MarketItemController) DeleteMarketItem(c *fiber.Ctx) error {
+	marketItem, err := mc.loadMarketItem(c)
+	if marketItem == nil {
+		return err
+	}
+	if !canModifyRecord(c, marketItem.UserId) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the seller may delete this market item",
+		})
+	}
 
-	if err := database.DB.First(&marketItem, id).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Market item not found",
+	if err := database.DB.WithContext(c.UserContext()).Delete(marketItem).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete market item",
 		})
 	}
+	return c.JSON(toMarketItemResponse(*marketItem))
+}
 
-	var updateData models.MarketItem
-	if err := c.BodyParser(&updateData); err != nil {
+// @Summary Get the price history of a market item
+// @Description The price the item was listed with and every change since, oldest first
+// @Produce json
+// @Tags MarketItem
+// @Param id path int true "Market item ID"
+// @Success 200 {array} dtos.MarketItemPriceChangeResponse
+// @Failure 404 {object} map[string]string
+// @Router /api/marketitem/{id}/price_history [get]
+func (mc *MarketItemController) GetPriceHistory(c *fiber.Ctx) error {
+	marketItem, err := mc.loadMarketItem(c)
+	if marketItem == nil {
+		return err
+	}
+	history := make([]dtos.MarketItemPriceChangeResponse, 0, len(marketItem.PriceChanges))
+	for _, change := range marketItem.PriceChanges {
+		history = append(history, dtos.MarketItemPriceChangeResponse{OldPrice: change.OldPrice, Price: change.Price, ChangedAt: change.ChangedAt})
+	}
+	return c.JSON(history)
+}
+
+// @Summary Get market sales statistics
+// @Description How many items were sold and how long they were listed before, from publishing to selling
+// @Produce json
+// @Tags MarketItem
+// @Param sellerId query string false "Comma separated user ids of sellers"
+// @Success 200 {object} dtos.MarketSalesStatsResponse
+// @Failure 400 {object} map[string]string
+// @Router /api/marketitem/stats [get]
+func (mc *MarketItemController) GetSalesStats(c *fiber.Ctx) error {
+	sellerIds, err := parseIdsQuery(c, "sellerId")
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Cannot parse JSON",
+			"error": "sellerId must be a comma separated list of ids",
+		})
+	}
+	stats, err := services.SalesStats(sellerIds)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to compute sales statistics",
 		})
 	}
+	return c.JSON(dtos.MarketSalesStatsResponse{
+		Sold:               stats.Sold,
+		AverageHoursToSell: stats.Average.Hours(),
+		MedianHoursToSell:  stats.Median.Hours(),
+		SoldAfterReduction: stats.SoldAfterReduction,
+	})
+}
+
+// @Summary Publish a market item
+// @Description List a draft or withdrawn item. Only the seller may publish an item.
+// @Produce json
+// @Tags MarketItem
+// @Param id path int true "Market item ID"
+// @Success 200 {object} dtos.MarketItemResponse
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/marketitem/{id}/publish [post]
+func (mc *MarketItemController) PublishMarketItem(c *fiber.Ctx) error {
+	return mc.sellerTransition(c, "publish")
+}
 
-	database.DB.Model(&marketItem).Updates(updateData)
+// @Summary Reserve a market item
+// @Description Hold an active item for a buyer, until it is released, sold or the reservation expires. Users reserve items
+// @Description for themselves, only the seller may reserve an item for someone else.
+// @Accept json
+// @Produce json
+// @Tags MarketItem
+// @Param id path int true "Market item ID"
+// @Param reservation body dtos.ReserveMarketItemRequest false "Buyer to reserve the item for"
+// @Success 200 {object} dtos.MarketItemResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 422 {object} dtos.ValidationErrorResponse
+// @Router /api/marketitem/{id}/reserve [post]
+func (mc *MarketItemController) ReserveMarketItem(c *fiber.Ctx) error {
+	marketItem, err := mc.loadMarketItem(c)
+	if marketItem == nil {
+		return err
+	}
+	var request dtos.ReserveMarketItemRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&request); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Cannot parse JSON",
+			})
+		}
+	}
+	buyerId := request.UserId
+	if user := middleware.CurrentUser(c); user != nil {
+		if buyerId == 0 {
+			buyerId = uint(user.ID)
+		}
+		if buyerId != uint(user.ID) && !canModifyRecord(c, marketItem.UserId) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Only the seller may reserve this market item for someone else",
+			})
+		}
+	}
+	if buyerId == 0 {
+		return validationFailed(c, map[string]string{"userId": "is required"})
+	}
+	return mc.applyTransition(c, marketItem, "reserve", buyerId)
+}
 
-	return c.JSON(marketItem)
+// @Summary Release a market item
+// @Description End the reservation of an item, which is active again. The seller and the buyer it is reserved for may release it.
+// @Produce json
+// @Tags MarketItem
+// @Param id path int true "Market item ID"
+// @Success 200 {object} dtos.MarketItemResponse
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/marketitem/{id}/release [post]
+func (mc *MarketItemController) ReleaseMarketItem(c *fiber.Ctx) error {
+	marketItem, err := mc.loadMarketItem(c)
+	if marketItem == nil {
+		return err
+	}
+	user := middleware.CurrentUser(c)
+	isBuyer := user != nil && marketItem.ReservedById != nil && *marketItem.ReservedById == uint(user.ID)
+	if !isBuyer && !canModifyRecord(c, marketItem.UserId) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the seller or the buyer may release this market item",
+		})
+	}
+	return mc.applyTransition(c, marketItem, "release", 0)
+}
+
+// @Summary Sell a market item
+// @Description Mark an active or reserved item as sold. Only the seller may sell an item.
+// @Produce json
+// @Tags MarketItem
+// @Param id path int true "Market item ID"
+// @Success 200 {object} dtos.MarketItemResponse
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/marketitem/{id}/sell [post]
+func (mc *MarketItemController) SellMarketItem(c *fiber.Ctx) error {
+	return mc.sellerTransition(c, "sell")
+}
+
+// @Summary Withdraw a market item
+// @Description Take an item that is not sold off the market, it can be published again later. Only the seller may withdraw an item.
+// @Produce json
+// @Tags MarketItem
+// @Param id path int true "Market item ID"
+// @Success 200 {object} dtos.MarketItemResponse
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/marketitem/{id}/withdraw [post]
+func (mc *MarketItemController) WithdrawMarketItem(c *fiber.Ctx) error {
+	return mc.sellerTransition(c, "withdraw")
+}
+
+// sellerTransition applies an action only the seller may take.
+func (mc *MarketItemController) sellerTransition(c *fiber.Ctx, action string) error {
+	marketItem, err := mc.loadMarketItem(c)
+	if marketItem == nil {
+		return err
+	}
+	if !canModifyRecord(c, marketItem.UserId) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the seller may " + action + " this market item",
+		})
+	}
+	return mc.applyTransition(c, marketItem, action, 0)
+}
+
+// applyTransition moves marketItem through action and responds with its new state.
+func (mc *MarketItemController) applyTransition(c *fiber.Ctx, marketItem *models.MarketItem, action string, buyerId uint) error {
+	err := mc.lifecycle.Transition(c.UserContext(), marketItem, action, buyerId)
+	switch {
+	case errors.Is(err, services.ErrInvalidTransition):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Cannot " + action + " a " + marketItem.Status + " market item",
+		})
+	case errors.Is(err, services.ErrReserveOwnItem), errors.Is(err, services.ErrUnknownBuyer):
+		return validationFailed(c, map[string]string{"userId": err.Error()})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to " + action + " market item",
+		})
+	}
+	var saved models.MarketItem
+	if err := preloadMarketItemSummaries(database.DB).First(&saved, marketItem.ID).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load market item",
+		})
+	}
+	return c.JSON(toMarketItemResponse(saved))
 }
@@ -0,0 +1,253 @@
+package controllers
+
+import (
+	"api/database"
+	"api/dtos"
+	"api/middleware"
+	"api/models"
+	"api/services"
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// MarketItemImageController serves the photos of market items. Image files never change, a new
+// upload gets a new id, so they can be cached for good.
+type MarketItemImageController struct {
+	images *services.MarketImageService
+}
+
+func NewMarketItemImageController(images *services.MarketImageService) *MarketItemImageController {
+	return &MarketItemImageController{images: images}
+}
+
+func (ic *MarketItemImageController) RegisterRoutes(app fiber.Router) {
+	log.Println("Setting up market item images...")
+	group := app.Group("/marketitem/:id/images")
+	group.Post("/", middleware.RequireScope("marketitem:write"), ic.UploadImages)
+	group.Get("/:imageId", middleware.RequireScope("marketitem:read"), ic.GetImage)
+	group.Get("/:imageId/thumbnail", middleware.RequireScope("marketitem:read"), ic.GetThumbnail)
+	group.Delete("/:imageId", middleware.RequireScope("marketitem:write"), ic.DeleteImage)
+}
+
+func marketItemImageURL(image models.MarketItemImage) string {
+	return fmt.Sprintf("/api/marketitem/%d/images/%d", image.MarketItemId, image.ID)
+}
+
+func toMarketItemImageResponse(image models.MarketItemImage) dtos.MarketItemImageResponse {
+	return dtos.MarketItemImageResponse{
+		ID:           image.ID,
+		Position:     image.Position,
+		ContentType:  image.ContentType,
+		Size:         image.Size,
+		Width:        image.Width,
+		Height:       image.Height,
+		URL:          marketItemImageURL(image),
+		ThumbnailURL: marketItemImageURL(image) + "/thumbnail",
+		CreatedAt:    image.CreatedAt,
+	}
+}
+
+// loadImage finds the image in the imageId param of the item in the id param. Like the item
+// itself, images of deleted items are not found, and those of drafts only for their seller.
+// When the returned image is nil the error response has already been written.
+func (ic *MarketItemImageController) loadImage(c *fiber.Ctx) (*models.MarketItemImage, error) {
+	var item models.MarketItem
+	err := database.DB.Select("id", "user_id", "status").First(&item, c.Params("id")).Error
+	if err != nil || (item.Status == services.MarketItemDraft && !canModifyRecord(c, item.UserId)) {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Image not found",
+		})
+	}
+	var image models.MarketItemImage
+	err = database.DB.Where("market_item_id = ?", item.ID).First(&image, c.Params("imageId")).Error
+	if err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Image not found",
+		})
+	}
+	return &image, nil
+}
+
+// @Summary Upload market item images
+// @Description Add photos to a market item, after the ones it has. JPEG, PNG, GIF and WebP are accepted, recognized
+// @Description by their content. Only the seller may add images. Either all uploaded images are added or none.
+// @Accept multipart/form-data
+// @Produce json
+// @Tags MarketItem
+// @Param id path int true "Market item ID"
+// @Param images formData file true "One or more images"
+// @Success 201 {array} dtos.MarketItemImageResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Router /api/marketitem/{id}/images [post]
+func (ic *MarketItemImageController) UploadImages(c *fiber.Ctx) error {
+	var marketItem models.MarketItem
+	if err := database.DB.First(&marketItem, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Market item not found",
+		})
+	}
+	if !canModifyRecord(c, marketItem.UserId) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the seller may add images to this market item",
+		})
+	}
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["images"]) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Upload the images as the multipart field images",
+		})
+	}
+
+	var added []models.MarketItemImage
+	for _, header := range form.File["images"] {
+		image, err := ic.addImage(c, uint(marketItem.ID), header)
+		if err != nil {
+			for _, image := range added {
+				if err := ic.images.Delete(c.UserContext(), image); err != nil {
+					log.Println("Failed to remove uploaded image:", err)
+				}
+			}
+			return ic.uploadFailed(c, header.Filename, err)
+		}
+		added = append(added, image)
+	}
+
+	response := make([]dtos.MarketItemImageResponse, 0, len(added))
+	for _, image := range added {
+		response = append(response, toMarketItemImageResponse(image))
+	}
+	return c.Status(fiber.StatusCreated).JSON(response)
+}
+
+func (ic *MarketItemImageController) addImage(c *fiber.Ctx, itemId uint, header *multipart.FileHeader) (models.MarketItemImage, error) {
+	file, err := header.Open()
+	if err != nil {
+		return models.MarketItemImage{}, err
+	}
+	defer file.Close()
+	return ic.images.Add(c.UserContext(), itemId, file)
+}
+
+func (ic *MarketItemImageController) uploadFailed(c *fiber.Ctx, fileName string, err error) error {
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrUnsupportedImage):
+		status = fiber.StatusUnsupportedMediaType
+	case errors.Is(err, services.ErrImageTooLarge):
+		status = fiber.StatusRequestEntityTooLarge
+	case errors.Is(err, services.ErrTooManyImages):
+		status = fiber.StatusConflict
+	default:
+		log.Println("Failed to store market image:", err)
+		return c.Status(status).JSON(fiber.Map{
+			"error": "Failed to store " + fileName,
+		})
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"error": fileName + ": " + err.Error(),
+	})
+}
+
+// @Summary Get a market item image
+// @Description Get an image as it was uploaded
+// @Produce image/jpeg,image/png,image/gif,image/webp
+// @Tags MarketItem
+// @Param id path int true "Market item ID"
+// @Param imageId path int true "Image ID"
+// @Success 200 {file} file
+// @Success 304
+// @Failure 404 {object} map[string]string
+// @Router /api/marketitem/{id}/images/{imageId} [get]
+func (ic *MarketItemImageController) GetImage(c *fiber.Ctx) error {
+	image, err := ic.loadImage(c)
+	if image == nil {
+		return err
+	}
+	return ic.sendImage(c, *image, false)
+}
+
+// @Summary Get a market item thumbnail
+// @Description Get a JPEG of the image scaled down to at most 320 pixels on the longest side
+// @Produce image/jpeg
+// @Tags MarketItem
+// @Param id path int true "Market item ID"
+// @Param imageId path int true "Image ID"
+// @Success 200 {file} file
+// @Success 304
+// @Failure 404 {object} map[string]string
+// @Router /api/marketitem/{id}/images/{imageId}/thumbnail [get]
+func (ic *MarketItemImageController) GetThumbnail(c *fiber.Ctx) error {
+	image, err := ic.loadImage(c)
+	if image == nil {
+		return err
+	}
+	return ic.sendImage(c, *image, true)
+}
+
+func (ic *MarketItemImageController) sendImage(c *fiber.Ctx, image models.MarketItemImage, thumbnail bool) error {
+	etag := fmt.Sprintf(`"%d"`, image.ID)
+	contentType := image.ContentType
+	if thumbnail {
+		etag = fmt.Sprintf(`"%d-thumbnail"`, image.ID)
+		contentType = "image/jpeg"
+	}
+	// Private, the images are only for holders of an API key
+	c.Set(fiber.HeaderCacheControl, "private, max-age=31536000, immutable")
+	c.Set(fiber.HeaderETag, etag)
+	if c.Get(fiber.HeaderIfNoneMatch) == etag {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	if err := c.SendFile(ic.images.Path(image, thumbnail)); err != nil {
+		log.Println("Failed to send market image:", err)
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Image file is missing",
+		})
+	}
+	// SendFile guesses the type from the extension, the sniffed one is known for sure
+	c.Set(fiber.HeaderContentType, contentType)
+	return nil
+}
+
+// @Summary Delete a market item image
+// @Description Remove an image and its files. Only the seller may remove images.
+// @Tags MarketItem
+// @Param id path int true "Market item ID"
+// @Param imageId path int true "Image ID"
+// @Success 204
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/marketitem/{id}/images/{imageId} [delete]
+func (ic *MarketItemImageController) DeleteImage(c *fiber.Ctx) error {
+	image, err := ic.loadImage(c)
+	if image == nil {
+		return err
+	}
+	var marketItem models.MarketItem
+	if err := database.DB.First(&marketItem, image.MarketItemId).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Market item not found",
+		})
+	}
+	if !canModifyRecord(c, marketItem.UserId) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the seller may remove images of this market item",
+		})
+	}
+
+	if err := ic.images.Delete(c.UserContext(), *image); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete image",
+		})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
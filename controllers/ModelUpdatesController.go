@@ -2,29 +2,74 @@ package controllers
 
 import (
 	"api/database"
+	"api/dtos"
+	"api/middleware"
 	"api/models"
 	"log"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/etag"
 )
 
 type ModelUpdatesController struct{}
 
 func (mc *ModelUpdatesController) RegisterRoutes(app fiber.Router) {
-	log.Println("Setting up user logs...")
-	group := app.Group("/marketitem")
-	group.Get("/", mc.GetModelUpdates)
+	log.Println("Setting up model updates...")
+	group := app.Group("/model_updates")
+	// The ETag is a hash of the response, so a dashboard polling with If-None-Match gets 304 until something changes
+	group.Get("/", middleware.RequireScope("model_updates:read"), etag.New(), mc.GetModelUpdates)
+	group.Get("/:model", middleware.RequireScope("model_updates:read"), etag.New(), mc.GetModelUpdate)
+}
+
+func toModelUpdateResponse(modelUpdate models.ModelUpdates) dtos.ModelUpdateResponse {
+	return dtos.ModelUpdateResponse{
+		Model:     modelUpdate.ModelName,
+		Method:    modelUpdate.Method,
+		UpdatedAt: modelUpdate.UpdatedAt,
+	}
 }
 
 // @Summary Get Model updates
-// @Description Get a list of all market items
+// @Description Get the time and method (create, update, delete) of the last change of every model.
+// @Description Responses carry an ETag; send it as If-None-Match to get 304 Not Modified while nothing changed.
 // @Produce json
 // @Tags ModelUpdates
-// @Success 200 {array} models.ModelUpdates
-// @Router /api/modelUpdates [get]
-func (uc *ModelUpdatesController) GetModelUpdates(c *fiber.Ctx) error {
+// @Param If-None-Match header string false "ETag of a previous response"
+// @Success 200 {array} dtos.ModelUpdateResponse
+// @Success 304
+// @Router /api/model_updates [get]
+func (mc *ModelUpdatesController) GetModelUpdates(c *fiber.Ctx) error {
 	var modelUpdates []models.ModelUpdates
 
-	database.DB.Find(&modelUpdates)
-	return c.JSON(modelUpdates)
-}
\ No newline at end of file
+	if err := database.DB.Order("model_name").Find(&modelUpdates).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get model updates",
+		})
+	}
+	response := make([]dtos.ModelUpdateResponse, len(modelUpdates))
+	for i, modelUpdate := range modelUpdates {
+		response[i] = toModelUpdateResponse(modelUpdate)
+	}
+	return c.JSON(response)
+}
+
+// @Summary Get the last update of a model
+// @Description Get the time and method of the last change of one model, e.g. MarketItem. Supports If-None-Match like the list.
+// @Produce json
+// @Tags ModelUpdates
+// @Param model path string true "Model name"
+// @Param If-None-Match header string false "ETag of a previous response"
+// @Success 200 {object} dtos.ModelUpdateResponse
+// @Success 304
+// @Failure 404 {object} map[string]string
+// @Router /api/model_updates/{model} [get]
+func (mc *ModelUpdatesController) GetModelUpdate(c *fiber.Ctx) error {
+	var modelUpdate models.ModelUpdates
+
+	if err := database.DB.Where("model_name = ?", c.Params("model")).First(&modelUpdate).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "No updates recorded for this model",
+		})
+	}
+	return c.JSON(toModelUpdateResponse(modelUpdate))
+}
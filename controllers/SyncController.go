@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"api/dtos"
+	"api/middleware"
+	"api/services"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultSyncLimit = 500
+	maxSyncLimit     = 5000
+)
+
+// SyncController checks the per model scopes itself, a pull or push only covers the
+// models the API key can read or write.
+type SyncController struct{}
+
+func (sc *SyncController) RegisterRoutes(app fiber.Router) {
+	log.Println("Setting up sync...")
+	group := app.Group("/sync")
+	group.Get("/", sc.Pull)
+	group.Post("/", sc.Push)
+}
+
+func syncCaller(c *fiber.Ctx) services.SyncCaller {
+	caller := services.SyncCaller{
+		Admin:    middleware.IsAdmin(c),
+		HasScope: func(scope string) bool { return middleware.HasScope(c, scope) },
+	}
+	if user := middleware.CurrentUser(c); user != nil {
+		caller.UserId = uint(user.ID)
+	}
+	return caller
+}
+
+// @Summary Pull changes for offline sync
+// @Description Get the current state of every record created, updated or deleted after the cursor. Start with cursor 0
+// @Description and pass the returned cursor on the next pull until hasMore is false. Deleted records come back with
+// @Description operation "delete" and no record. Models the API key cannot read are left out. Market items of every
+// @Description seller are pulled, drafts only by their seller. After a database restore the pull comes back with reset
+// @Description set: drop the synced records and pull again from cursor 0.
+// @Produce json
+// @Tags Sync
+// @Param cursor query int false "Cursor of the previous pull" default(0)
+// @Param models query string false "Comma separated model names, e.g. BloodPressure,LogBookEntry"
+// @Param limit query int false "Changes per pull, at most 5000" default(500)
+// @Success 200 {object} dtos.SyncPullResponse
+// @Failure 400 {object} map[string]string
+// @Router /api/sync [get]
+func (sc *SyncController) Pull(c *fiber.Ctx) error {
+	cursor, err := strconv.ParseUint(c.Query("cursor", "0"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "cursor must be a non-negative number",
+		})
+	}
+	limit := c.QueryInt("limit", defaultSyncLimit)
+	if limit < 1 || limit > maxSyncLimit {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "limit must be between 1 and " + strconv.Itoa(maxSyncLimit),
+		})
+	}
+	var names []string
+	if value := c.Query("models"); value != "" {
+		names = strings.Split(value, ",")
+	}
+
+	response, err := services.PullChanges(syncCaller(c), cursor, names, limit)
+	if errors.Is(err, services.ErrUnknownSyncModel) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get changes",
+		})
+	}
+	return c.JSON(response)
+}
+
+// @Summary Push offline changes
+// @Description Apply a batch of client changes. Every change is applied on its own: creates always apply when valid,
+// @Description updates and deletes only when the server record still has the updatedAt the client based the change on.
+// @Description Otherwise the result is a conflict carrying the server version. BloodPressure, MarketItem and
+// @Description LogBookEntry can be pushed.
+// @Accept json
+// @Produce json
+// @Tags Sync
+// @Param changes body dtos.SyncPushRequest true "Client changes"
+// @Success 200 {object} dtos.SyncPushResponse
+// @Failure 400 {object} map[string]string
+// @Router /api/sync [post]
+func (sc *SyncController) Push(c *fiber.Ctx) error {
+	var request dtos.SyncPushRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+	return c.JSON(services.PushChanges(c.UserContext(), syncCaller(c), request.Changes))
+}
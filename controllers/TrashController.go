@@ -0,0 +1,220 @@
+package controllers
+
+import (
+	"api/database"
+	"api/dtos"
+	"api/middleware"
+	"api/services"
+	"errors"
+	"log"
+	"reflect"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultTrashPageSize = 50
+	maxTrashPageSize     = 500
+)
+
+// TrashController checks the per model scopes itself. Non-admins only see and restore their own
+// records, the trash of models without an owner is for admins only.
+type TrashController struct{}
+
+func (tc *TrashController) RegisterRoutes(app fiber.Router) {
+	log.Println("Setting up trash...")
+	group := app.Group("/trash")
+	group.Get("/", tc.GetTrashSummary)
+	group.Get("/:model", tc.GetTrash)
+	group.Post("/:model/:id/restore", tc.RestoreRecord)
+	group.Delete("/:model/:id", tc.PurgeRecord)
+}
+
+func trashAccessible(c *fiber.Ctx, model services.TrashModel, access string) bool {
+	if middleware.IsAdmin(c) {
+		return true
+	}
+	return model.OwnerColumn != "" && middleware.HasScope(c, model.Resource+":"+access)
+}
+
+// trashQuery selects the deleted records of model the caller may see.
+func trashQuery(c *fiber.Ctx, model services.TrashModel) *gorm.DB {
+	query := database.DB.Unscoped().Model(model.New()).Where("deleted_at IS NOT NULL")
+	if user := middleware.CurrentUser(c); user != nil && !middleware.IsAdmin(c) {
+		query = query.Where(model.OwnerColumn+" = ?", user.ID)
+	}
+	return query
+}
+
+// loadTrashModel resolves the model param and checks the caller may use its trash with access.
+// When ok is false the error response has already been written.
+func loadTrashModel(c *fiber.Ctx, access string) (services.TrashModel, bool, error) {
+	model, found := services.FindTrashModel(c.Params("model"))
+	if !found {
+		return model, false, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Unknown model",
+		})
+	}
+	if !trashAccessible(c, model, access) {
+		return model, false, c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Not allowed to use the trash of " + model.Name,
+		})
+	}
+	return model, true, nil
+}
+
+// loadTrashedRecord finds the deleted record in the id param. When the returned record is nil
+// the error response has already been written.
+func loadTrashedRecord(c *fiber.Ctx, model services.TrashModel) (any, error) {
+	record := model.New()
+	if err := trashQuery(c, model).First(record, c.Params("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Record not found in the trash",
+			})
+		}
+		return nil, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get record",
+		})
+	}
+	return record, nil
+}
+
+// @Summary Get the trash
+// @Description Get the number of deleted records per model the caller can see in the trash
+// @Produce json
+// @Tags Trash
+// @Success 200 {array} dtos.TrashSummary
+// @Router /api/trash [get]
+func (tc *TrashController) GetTrashSummary(c *fiber.Ctx) error {
+	summary := []dtos.TrashSummary{}
+	for _, model := range services.TrashModels {
+		if !trashAccessible(c, model, "read") {
+			continue
+		}
+		entry := dtos.TrashSummary{Model: model.Name}
+		if err := trashQuery(c, model).Count(&entry.Count).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to count deleted records",
+			})
+		}
+		summary = append(summary, entry)
+	}
+	return c.JSON(summary)
+}
+
+// @Summary Get the deleted records of a model
+// @Description Get a page of the deleted records of a model, most recently deleted first
+// @Produce json
+// @Tags Trash
+// @Param model path string true "Model name, e.g. BloodPressure"
+// @Param page query int false "Page number, starting at 1"
+// @Param pageSize query int false "Records per page, at most 500" default(50)
+// @Success 200 {object} dtos.TrashPage
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/trash/{model} [get]
+func (tc *TrashController) GetTrash(c *fiber.Ctx) error {
+	model, ok, err := loadTrashModel(c, "read")
+	if !ok {
+		return err
+	}
+	page := c.QueryInt("page", 1)
+	pageSize := c.QueryInt("pageSize", defaultTrashPageSize)
+	if page < 1 || pageSize < 1 || pageSize > maxTrashPageSize {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "page must be at least 1 and pageSize between 1 and " + strconv.Itoa(maxTrashPageSize),
+		})
+	}
+
+	result := dtos.TrashPage{Model: model.Name, Page: page, PageSize: pageSize}
+	if err := trashQuery(c, model).Count(&result.Total).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to count deleted records",
+		})
+	}
+	items := reflect.New(reflect.SliceOf(reflect.TypeOf(model.New()).Elem()))
+	items.Elem().Set(reflect.MakeSlice(items.Elem().Type(), 0, 0))
+	err = trashQuery(c, model).Order("deleted_at DESC").Order("id DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).Find(items.Interface()).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get deleted records",
+		})
+	}
+	result.Items = items.Elem().Interface()
+	return c.JSON(result)
+}
+
+// @Summary Restore a deleted record
+// @Description Move a record out of the trash again
+// @Produce json
+// @Tags Trash
+// @Param model path string true "Model name, e.g. BloodPressure"
+// @Param id path int true "Record ID"
+// @Success 200 {object} object
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/trash/{model}/{id}/restore [post]
+func (tc *TrashController) RestoreRecord(c *fiber.Ctx) error {
+	model, ok, err := loadTrashModel(c, "write")
+	if !ok {
+		return err
+	}
+	record, err := loadTrashedRecord(c, model)
+	if record == nil {
+		return err
+	}
+
+	err = database.DB.WithContext(c.UserContext()).Unscoped().Model(record).Update("deleted_at", nil).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		// A partial unique index, e.g. the same caregiver pair was added again since
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Cannot restore, a record like it exists again",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to restore record",
+		})
+	}
+	if err := database.DB.First(record).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load restored record",
+		})
+	}
+	return c.JSON(record)
+}
+
+// @Summary Purge a deleted record
+// @Description Permanently delete a record from the trash, with what belongs to it outside the database such as the images of a MarketItem.
+// @Description Records that are not deleted have to be deleted first.
+// @Produce json
+// @Tags Trash
+// @Param model path string true "Model name, e.g. BloodPressure"
+// @Param id path int true "Record ID"
+// @Success 204
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/trash/{model}/{id} [delete]
+func (tc *TrashController) PurgeRecord(c *fiber.Ctx) error {
+	model, ok, err := loadTrashModel(c, "write")
+	if !ok {
+		return err
+	}
+	record, err := loadTrashedRecord(c, model)
+	if record == nil {
+		return err
+	}
+
+	if err := services.PurgeRecord(c.UserContext(), model, record); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to purge record",
+		})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
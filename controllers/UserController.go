@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"api/database"
+	"api/middleware"
 	"api/models"
 	"log"
 
@@ -13,20 +14,26 @@ type UserController struct{}
 func (uc *UserController) RegisterRoutes(app fiber.Router) {
 	log.Println("Setting up user logs...")
 	group := app.Group("/users")
-	group.Post("/", uc.CreateUser)
-	group.Get("/", uc.GetUsers)
+	group.Post("/", middleware.RequireScope("users:write"), uc.CreateUser)
+	group.Get("/", middleware.RequireScope("users:read"), uc.GetUsers)
 }
 
 // @Summary Get a list of users
 // @Description Get a list of all users
 // @Produce json
 // @Tags User
+// @Param includeDeleted query bool false "Include records in the trash, admins only"
 // @Success 200 {array} models.User
+// @Failure 403 {object} map[string]string
 // @Router /api/users [get]
 func (uc *UserController) GetUsers(c *fiber.Ctx) error {
 	var users []models.User
 
-	database.DB.Find(&users)
+	query, err := includeDeleted(c, database.DB)
+	if query == nil {
+		return err
+	}
+	query.Find(&users)
 	return c.JSON(users)
 }
 
@@ -47,7 +54,7 @@ func (uc *UserController) CreateUser(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := database.DB.Create(&user).Error; err != nil {
+	if err := database.DB.WithContext(c.UserContext()).Create(&user).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
 		})
@@ -82,7 +89,7 @@ func (uc *UserController) UpdateUser(c *fiber.Ctx) error {
 		})
 	}
 
-	database.DB.Model(&user).Updates(updateData)
+	database.DB.WithContext(c.UserContext()).Model(&user).Updates(updateData)
 
 	return c.JSON(user)
 }
@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"api/middleware"
+	"api/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// resolveRecordOwner returns whose health records a read request is about: the userId query
+// parameter when the caller may read that user's records, otherwise the caller.
+// It returns 0 when authentication is disabled and no userId was given, meaning all records.
+// When ok is false the error response has already been written.
+func resolveRecordOwner(c *fiber.Ctx) (ownerId uint, ok bool, err error) {
+	user := middleware.CurrentUser(c)
+	requested := c.QueryInt("userId", 0)
+	if requested < 0 {
+		return 0, false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid userId",
+		})
+	}
+	if requested == 0 {
+		if user == nil {
+			return 0, true, nil
+		}
+		return uint(user.ID), true, nil
+	}
+	if user == nil || middleware.IsAdmin(c) {
+		return uint(requested), true, nil
+	}
+
+	allowed, dbErr := services.CanReadUserRecords(uint(user.ID), uint(requested))
+	if dbErr != nil {
+		return 0, false, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check access",
+		})
+	}
+	if !allowed {
+		return 0, false, c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Not allowed to read records of this user",
+		})
+	}
+	return uint(requested), true, nil
+}
+
+// resolveWriteOwner returns the user a new record is created for. Only admins (or requests
+// while authentication is disabled) may create records on behalf of another user.
+func resolveWriteOwner(c *fiber.Ctx, requested uint) (ownerId uint, ok bool, err error) {
+	user := middleware.CurrentUser(c)
+	if user == nil {
+		return requested, true, nil
+	}
+	if requested == 0 || requested == uint(user.ID) {
+		return uint(user.ID), true, nil
+	}
+	if !middleware.IsAdmin(c) {
+		return 0, false, c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Not allowed to create records for another user",
+		})
+	}
+	return requested, true, nil
+}
+
+// canModifyRecord reports whether the caller may change or delete a record owned by ownerId.
+func canModifyRecord(c *fiber.Ctx, ownerId uint) bool {
+	user := middleware.CurrentUser(c)
+	return user == nil || uint(user.ID) == ownerId || middleware.IsAdmin(c)
+}
@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"api/middleware"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// parseTimeQuery reads an RFC 3339 timestamp or a plain YYYY-MM-DD date from the query string.
+// A plain date used as the end of a range (endOfDay) includes the whole day.
+func parseTimeQuery(c *fiber.Ctx, name string, endOfDay bool) (*time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return &t, nil
+}
+
+// parseIdsQuery reads a comma separated list of ids from the query string, nil when it is not set.
+func parseIdsQuery(c *fiber.Ctx, name string) ([]uint, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	var ids []uint
+	for _, part := range strings.Split(value, ",") {
+		id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+// parseFloatQuery reads a number from the query string, nil when it is not set.
+func parseFloatQuery(c *fiber.Ctx, name string) (*float64, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &number, nil
+}
+
+// includeDeleted applies ?includeDeleted=true, which lists records in the trash too and is only
+// open to admins. When the returned query is nil the error response has already been written.
+func includeDeleted(c *fiber.Ctx, query *gorm.DB) (*gorm.DB, error) {
+	if !c.QueryBool("includeDeleted") {
+		return query, nil
+	}
+	if !middleware.IsAdmin(c) {
+		return nil, c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "includeDeleted is only available to admins",
+		})
+	}
+	return query.Unscoped(), nil
+}
@@ -0,0 +1,320 @@
+package database
+
+import (
+	"api/models"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	changeLogBeforeKey = "change_log:before"
+	skipChangeLogKey   = "change_log:skip"
+)
+
+// Models that are bookkeeping themselves and never written to the change log.
+var untrackedModels = map[string]bool{
+	"ModelUpdates":   true,
+	"ChangeLogEntry": true,
+	// Follows from the price changes of market items, which are in the change log themselves
+	"MarketItemPriceChange": true,
+}
+
+// Fields that name the user a record belongs to, see ChangeLogEntry.OwnerId.
+var ownerFields = []string{"UserId", "PatientId"}
+
+type actingUserKey struct{}
+
+// WithActingUser returns a context that attributes the changes made with it to userId.
+func WithActingUser(ctx context.Context, userId uint) context.Context {
+	return context.WithValue(ctx, actingUserKey{}, userId)
+}
+
+func actingUser(ctx context.Context) *uint {
+	if ctx == nil {
+		return nil
+	}
+	if userId, ok := ctx.Value(actingUserKey{}).(uint); ok {
+		return &userId
+	}
+	return nil
+}
+
+// WithoutChangeLog returns a session whose changes are neither written to the change log nor
+// to ModelUpdates, for bookkeeping like last used timestamps or purging expired rows.
+func WithoutChangeLog(db *gorm.DB) *gorm.DB {
+	return db.Set(skipChangeLogKey, true)
+}
+
+type fieldChange struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+func changeLogTracked(db *gorm.DB) bool {
+	if db.Error != nil || db.Statement.Schema == nil || untrackedModels[db.Statement.Schema.Name] {
+		return false
+	}
+	skip, _ := db.Get(skipChangeLogKey)
+	return skip != true
+}
+
+// captureChangedRows loads the rows an update or delete is about to change, so the
+// after callback can diff them.
+func captureChangedRows(db *gorm.DB) {
+	if !changeLogTracked(db) {
+		return
+	}
+	query := db.Session(&gorm.Session{NewDB: true}).Unscoped().Table(db.Statement.Table)
+	conditions := false
+	if ids := primaryKeys(db, db.Statement.ReflectValue); len(ids) > 0 {
+		query = query.Where(clause.IN{Column: clause.Column{Name: db.Statement.Schema.PrioritizedPrimaryField.DBName}, Values: ids})
+		conditions = true
+	}
+	if c, ok := db.Statement.Clauses["WHERE"]; ok {
+		if where, ok := c.Expression.(clause.Where); ok && len(where.Exprs) > 0 {
+			query = query.Clauses(clause.Where{Exprs: where.Exprs})
+			conditions = true
+		}
+	}
+	if !conditions {
+		return
+	}
+	rows := reflect.New(reflect.SliceOf(db.Statement.Schema.ModelType))
+	if err := query.Find(rows.Interface()).Error; err != nil {
+		log.Println("Failed to load rows for the change log:", err)
+		return
+	}
+	db.InstanceSet(changeLogBeforeKey, rows.Elem())
+}
+
+// RowsBeforeChange returns the rows an update or delete changed as they were before it, a
+// slice of the model. ok is false when they weren't loaded, as the change log skips the statement.
+func RowsBeforeChange(db *gorm.DB) (rows reflect.Value, ok bool) {
+	before, ok := db.InstanceGet(changeLogBeforeKey)
+	if !ok {
+		return reflect.Value{}, false
+	}
+	return before.(reflect.Value), true
+}
+
+// recordChanges appends the changes of a finished create, update or delete to the change log.
+func recordChanges(db *gorm.DB, operation string) {
+	if !changeLogTracked(db) {
+		return
+	}
+	stmt := db.Statement
+	pk := stmt.Schema.PrioritizedPrimaryField
+	if pk == nil {
+		return
+	}
+	now := time.Now()
+	var entries []models.ChangeLogEntry
+	add := func(oldRow, newRow reflect.Value) {
+		if entry, ok := newChangeLogEntry(stmt.Context, stmt.Schema, operation, oldRow, newRow, now); ok {
+			entries = append(entries, entry)
+		}
+	}
+
+	if operation == "create" {
+		forEachRow(stmt.ReflectValue, func(row reflect.Value) { add(reflect.Value{}, row) })
+	} else {
+		before, ok := db.InstanceGet(changeLogBeforeKey)
+		if !ok {
+			return
+		}
+		oldRows := before.(reflect.Value)
+		ids := primaryKeys(db, oldRows)
+		if len(ids) == 0 {
+			return
+		}
+		// Reload to see what the statement actually did, including rows it turned out not to touch
+		newRows := reflect.New(reflect.SliceOf(stmt.Schema.ModelType))
+		err := db.Session(&gorm.Session{NewDB: true}).Unscoped().Table(stmt.Table).
+			Where(clause.IN{Column: clause.Column{Name: pk.DBName}, Values: ids}).
+			Find(newRows.Interface()).Error
+		if err != nil {
+			log.Println("Failed to reload rows for the change log:", err)
+			return
+		}
+		byId := map[string]reflect.Value{}
+		forEachRow(newRows.Elem(), func(row reflect.Value) {
+			id, _ := pk.ValueOf(stmt.Context, row)
+			byId[fmt.Sprint(id)] = row
+		})
+		forEachRow(oldRows, func(row reflect.Value) {
+			id, _ := pk.ValueOf(stmt.Context, row)
+			add(row, byId[fmt.Sprint(id)])
+		})
+	}
+
+	if len(entries) == 0 {
+		return
+	}
+	if err := db.Session(&gorm.Session{NewDB: true}).CreateInBatches(&entries, 100).Error; err != nil {
+		log.Println("Failed to write the change log:", err)
+	}
+}
+
+// newChangeLogEntry describes the change from oldRow to newRow. ok is false when nothing changed.
+func newChangeLogEntry(ctx context.Context, s *schema.Schema, operation string, oldRow, newRow reflect.Value, now time.Time) (entry models.ChangeLogEntry, ok bool) {
+	row := newRow
+	if !row.IsValid() {
+		row = oldRow
+	}
+	changes := diffRows(ctx, s, oldRow, newRow)
+	if len(changes) == 0 {
+		return entry, false
+	}
+	diff, err := json.Marshal(changes)
+	if err != nil {
+		log.Println("Failed to encode change log diff:", err)
+		return entry, false
+	}
+	id, _ := s.PrioritizedPrimaryField.ValueOf(ctx, row)
+	return models.ChangeLogEntry{
+		ModelName: s.Name,
+		RecordId:  fmt.Sprint(id),
+		Operation: operation,
+		Changes:   diff,
+		UserId:    actingUser(ctx),
+		OwnerId:   recordOwner(ctx, s, row),
+		Timestamp: now,
+	}, true
+}
+
+// backfillChangeLog records the rows of tables that existed before the change log as creates,
+// so that a client syncing from cursor 0 gets every record.
+func backfillChangeLog(tx *gorm.DB, tables []any) error {
+	var count int64
+	if err := tx.Model(&models.ChangeLogEntry{}).Count(&count).Error; err != nil || count > 0 {
+		return err
+	}
+	now := time.Now()
+	for _, model := range tables {
+		stmt := &gorm.Statement{DB: tx}
+		if err := stmt.Parse(model); err != nil {
+			return err
+		}
+		// Tables added by later migrations don't exist yet, and start out empty anyway
+		if untrackedModels[stmt.Schema.Name] || !tx.Migrator().HasTable(stmt.Schema.Table) {
+			continue
+		}
+		rows := reflect.New(reflect.SliceOf(stmt.Schema.ModelType))
+		err := tx.Unscoped().Model(model).FindInBatches(rows.Interface(), 500, func(batchTx *gorm.DB, batch int) error {
+			var entries []models.ChangeLogEntry
+			forEachRow(rows.Elem(), func(row reflect.Value) {
+				if entry, ok := newChangeLogEntry(context.Background(), stmt.Schema, "create", reflect.Value{}, row, now); ok {
+					entries = append(entries, entry)
+				}
+			})
+			if len(entries) == 0 {
+				return nil
+			}
+			return tx.Session(&gorm.Session{NewDB: true}).Create(&entries).Error
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diffRows returns the fields that differ between oldRow and newRow. An invalid oldRow
+// (create) or newRow (hard delete) counts as all fields being null.
+func diffRows(ctx context.Context, s *schema.Schema, oldRow, newRow reflect.Value) map[string]fieldChange {
+	changes := map[string]fieldChange{}
+	for _, field := range s.Fields {
+		if field.DBName == "" || field.PrimaryKey || field.AutoCreateTime != 0 || field.AutoUpdateTime != 0 {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			// Hidden from the API, e.g. key hashes, so hidden from the change log too
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		var change fieldChange
+		if oldRow.IsValid() {
+			change.Old, _ = field.ValueOf(ctx, oldRow)
+		}
+		if newRow.IsValid() {
+			change.New, _ = field.ValueOf(ctx, newRow)
+		}
+		oldJSON, _ := json.Marshal(change.Old)
+		newJSON, _ := json.Marshal(change.New)
+		if bytes.Equal(oldJSON, newJSON) {
+			continue
+		}
+		changes[name] = change
+	}
+	return changes
+}
+
+func recordOwner(ctx context.Context, s *schema.Schema, row reflect.Value) *uint {
+	var value any
+	if s.Name == "User" {
+		value, _ = s.PrioritizedPrimaryField.ValueOf(ctx, row)
+	} else {
+		for _, name := range ownerFields {
+			if field := s.LookUpField(name); field != nil {
+				value, _ = field.ValueOf(ctx, row)
+				break
+			}
+		}
+	}
+	var owner uint
+	switch v := value.(type) {
+	case uint:
+		owner = v
+	case int:
+		owner = uint(v)
+	}
+	if owner == 0 {
+		return nil
+	}
+	return &owner
+}
+
+func primaryKeys(db *gorm.DB, rows reflect.Value) []any {
+	pk := db.Statement.Schema.PrioritizedPrimaryField
+	if pk == nil || !rows.IsValid() {
+		return nil
+	}
+	var ids []any
+	forEachRow(rows, func(row reflect.Value) {
+		if id, zero := pk.ValueOf(db.Statement.Context, row); !zero {
+			ids = append(ids, id)
+		}
+	})
+	return ids
+}
+
+// forEachRow calls fn with every struct in value, which may be a struct, a pointer or a slice of either.
+func forEachRow(value reflect.Value, fn func(row reflect.Value)) {
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			forEachRow(value.Index(i), fn)
+		}
+	case reflect.Struct:
+		fn(value)
+	}
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ConnectionConfig tunes the connection pool and, on SQLite, the pragmas every connection starts with.
+type ConnectionConfig struct {
+	// JournalMode is the SQLite journal mode. In WAL mode readers don't block the writer and
+	// the writer doesn't block readers, and a write touches the SD card far less often.
+	JournalMode string
+	// Synchronous is how often SQLite waits for the disk. NORMAL is safe with WAL, a power cut
+	// can only lose the last transactions, never corrupt the database.
+	Synchronous string
+	// BusyTimeout is how long a statement waits for a lock before it fails with "database is locked"
+	BusyTimeout time.Duration
+	// TxLock is how SQLite transactions begin. With immediate a transaction takes the write lock
+	// at BEGIN, so concurrent writers queue on BusyTimeout; a deferred transaction that first
+	// reads and then writes fails right away when another connection wrote in between.
+	TxLock string
+
+	// MaxOpenConns is 0 for no limit
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// SlowQuery is how long a query may take before it is logged and listed in the database stats
+	SlowQuery time.Duration
+}
+
+// DefaultConnectionConfig reads the config from SQLITE_JOURNAL_MODE (default WAL), SQLITE_SYNCHRONOUS
+// (default NORMAL), SQLITE_BUSY_TIMEOUT (default 5s), SQLITE_TXLOCK (default immediate),
+// DB_MAX_OPEN_CONNS, DB_MAX_IDLE_CONNS (default 2), DB_CONN_MAX_LIFETIME, DB_CONN_MAX_IDLE_TIME
+// and DB_SLOW_QUERY (default 200ms).
+func DefaultConnectionConfig() ConnectionConfig {
+	config := ConnectionConfig{
+		JournalMode:  "WAL",
+		Synchronous:  "NORMAL",
+		BusyTimeout:  5 * time.Second,
+		TxLock:       "immediate",
+		MaxIdleConns: 2,
+		SlowQuery:    200 * time.Millisecond,
+	}
+	if mode := os.Getenv("SQLITE_JOURNAL_MODE"); mode != "" {
+		config.JournalMode = strings.ToUpper(mode)
+	}
+	if synchronous := os.Getenv("SQLITE_SYNCHRONOUS"); synchronous != "" {
+		config.Synchronous = strings.ToUpper(synchronous)
+	}
+	if timeout, err := time.ParseDuration(os.Getenv("SQLITE_BUSY_TIMEOUT")); err == nil && timeout >= 0 {
+		config.BusyTimeout = timeout
+	}
+	if lock := os.Getenv("SQLITE_TXLOCK"); lock != "" {
+		config.TxLock = strings.ToLower(lock)
+	}
+	if conns, err := strconv.Atoi(os.Getenv("DB_MAX_OPEN_CONNS")); err == nil && conns >= 0 {
+		config.MaxOpenConns = conns
+	}
+	if conns, err := strconv.Atoi(os.Getenv("DB_MAX_IDLE_CONNS")); err == nil && conns >= 0 {
+		config.MaxIdleConns = conns
+	}
+	if lifetime, err := time.ParseDuration(os.Getenv("DB_CONN_MAX_LIFETIME")); err == nil && lifetime > 0 {
+		config.ConnMaxLifetime = lifetime
+	}
+	if idle, err := time.ParseDuration(os.Getenv("DB_CONN_MAX_IDLE_TIME")); err == nil && idle > 0 {
+		config.ConnMaxIdleTime = idle
+	}
+	if threshold, err := time.ParseDuration(os.Getenv("DB_SLOW_QUERY")); err == nil && threshold > 0 {
+		config.SlowQuery = threshold
+	}
+	return config
+}
+
+// ActiveConnectionConfig is the config DB was opened with, set by Connect.
+var ActiveConnectionConfig ConnectionConfig
+
+// sqliteDSN adds the pragmas of config to dsn. The driver runs them on every new connection,
+// which matters for busy_timeout and synchronous, they only hold for the connection they ran on.
+// Parameters already in dsn win.
+func sqliteDSN(dsn string, config ConnectionConfig) string {
+	path, query, _ := strings.Cut(dsn, "?")
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		// Let the driver report the broken DSN
+		return dsn
+	}
+	set := func(value string, names ...string) {
+		for _, name := range names {
+			if params.Has(name) {
+				return
+			}
+		}
+		if value != "" {
+			params.Set(names[0], value)
+		}
+	}
+	set(config.JournalMode, "_journal_mode", "_journal")
+	set(config.Synchronous, "_synchronous", "_sync")
+	set(strconv.FormatInt(config.BusyTimeout.Milliseconds(), 10), "_busy_timeout", "_timeout")
+	set(config.TxLock, "_txlock")
+	return path + "?" + params.Encode()
+}
+
+// configurePool applies the pool settings of config to db.
+func configurePool(db *gorm.DB, config ConnectionConfig) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	sqlDB.SetMaxOpenConns(config.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(config.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(config.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+	return nil
+}
@@ -2,28 +2,55 @@ package database
 
 import (
 	"log"
+	"os"
+	"time"
 
-	"gorm.io/driver/sqlite" // SQLite driver for GORM
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
 var DB *gorm.DB
 
-func InitDB() {
-	var err error
-	// Connect to SQLite database (you can replace this with any other database driver)
-	DB, err = gorm.Open(sqlite.Open("./database.db"), &gorm.Config{})
+// Connect opens the database DB_DRIVER and DB_DSN point at, without touching the schema.
+func Connect() {
+	dialect, dsn, err := databaseConfig()
 	if err != nil {
+		log.Fatal("Failed to connect to database: ", err)
+	}
+	ActiveDialect = dialect
+	config := DefaultConnectionConfig()
+	ActiveConnectionConfig = config
+	if dialect.tuneDSN != nil {
+		dsn = dialect.tuneDSN(dsn, config)
+	}
+	DB, err = gorm.Open(dialect.open(dsn), &gorm.Config{
+		NowFunc: func() time.Time {
+			return time.Now().Truncate(dialect.TimePrecision)
+		},
+		// Violated unique indexes come back as gorm.ErrDuplicatedKey, whichever the database
+		TranslateError: true,
+		Logger: logger.New(log.New(os.Stdout, "\r\n", log.LstdFlags), logger.Config{
+			SlowThreshold: config.SlowQuery,
+			LogLevel:      logger.Warn,
+			Colorful:      true,
+		}),
+	})
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	if err := configurePool(DB, config); err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
+	setupSlowQueryLog(config.SlowQuery)
+	log.Println("Using", dialect.Name, "database")
+}
 
-	DB = DB.Set("gorm:query_option", "WHERE deleted_at IS NULL")
-	
-	
-	
-	// Auto migrate 
+func InitDB() {
+	Connect()
+
+	// Apply pending migrations
 	migrateDb()
-	
+
 	// Set up callbacks for tracking model updates
 	setupModelTracking()
 
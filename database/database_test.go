@@ -0,0 +1,200 @@
+package database
+
+import (
+	"api/models"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// TestSQLite runs the database tests against an in-memory SQLite database.
+func TestSQLite(t *testing.T) {
+	// Every connection to a shared in-memory database sees the same tables, one at a time
+	// keeps them from locking each other out
+	t.Setenv("DB_MAX_OPEN_CONNS", "1")
+	connectForTest(t, "sqlite", "file:"+strings.ReplaceAll(t.Name(), "/", "_")+"?mode=memory&cache=shared")
+	runDatabaseTests(t)
+}
+
+// TestPostgres runs the database tests against the Postgres database TEST_POSTGRES_DSN points at,
+// e.g. a container from "docker run -e POSTGRES_PASSWORD=test -p 5432:5432 postgres" with
+// TEST_POSTGRES_DSN="host=localhost user=postgres password=test". Everything in it is dropped,
+// which is why it doesn't use DB_DSN: a shell set up for a deployment has that pointing at real data.
+func TestPostgres(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+	connectForTest(t, "postgres", dsn)
+	runDatabaseTests(t)
+}
+
+// connectForTest points DB at a database that starts out without any migration applied.
+func connectForTest(t *testing.T, driver, dsn string) {
+	t.Helper()
+	t.Setenv("DB_DRIVER", driver)
+	t.Setenv("DB_DSN", dsn)
+	Connect()
+	if err := MigrateTo(0, nil); err != nil {
+		t.Fatalf("reverting leftover migrations failed: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := MigrateTo(0, nil); err != nil {
+			t.Errorf("reverting migrations failed: %v", err)
+		}
+		if sqlDB, err := DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+func runDatabaseTests(t *testing.T) {
+	t.Run("migrate up and down", testMigrateUpDown)
+	t.Run("migrate legacy database", testMigrateLegacyDatabase)
+	t.Run("crud", testCRUD)
+}
+
+func testMigrateUpDown(t *testing.T) {
+	if err := MigrateUp(nil); err != nil {
+		t.Fatalf("MigrateUp() failed: %v", err)
+	}
+	assertApplied(t, LatestMigration())
+	for _, model := range Models {
+		if !DB.Migrator().HasTable(model) {
+			t.Errorf("table of %T is missing", model)
+		}
+	}
+	if err := MigrateUp(nil); err != nil {
+		t.Fatalf("MigrateUp() on an up to date database failed: %v", err)
+	}
+
+	for version := LatestMigration(); version > 0; version-- {
+		if err := MigrateDown(1, nil); err != nil {
+			t.Fatalf("MigrateDown(1) from %d failed: %v", version, err)
+		}
+		assertApplied(t, version-1)
+	}
+	if DB.Migrator().HasTable(&models.User{}) {
+		t.Error("users table is left after reverting all migrations")
+	}
+	if err := MigrateDown(1, nil); err == nil {
+		t.Error("MigrateDown(1) without any migration applied succeeded")
+	}
+
+	if err := MigrateUp(nil); err != nil {
+		t.Fatalf("MigrateUp() after reverting everything failed: %v", err)
+	}
+	assertApplied(t, LatestMigration())
+	if err := MigrateTo(0, nil); err != nil {
+		t.Fatalf("MigrateTo(0) failed: %v", err)
+	}
+}
+
+// testMigrateLegacyDatabase migrates a database AutoMigrate created before there were migrations.
+func testMigrateLegacyDatabase(t *testing.T) {
+	if err := DB.AutoMigrate(baselineTables()...); err != nil {
+		t.Fatalf("creating the legacy tables failed: %v", err)
+	}
+	if err := DB.Exec("INSERT INTO users (name, phone, email, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
+		"Alice", "555", "alice@example.com", DB.NowFunc(), DB.NowFunc()).Error; err != nil {
+		t.Fatalf("inserting a legacy user failed: %v", err)
+	}
+
+	if err := MigrateUp(nil); err != nil {
+		t.Fatalf("MigrateUp() failed: %v", err)
+	}
+	assertApplied(t, LatestMigration())
+
+	var user models.User
+	if err := DB.Where("email = ?", "alice@example.com").First(&user).Error; err != nil {
+		t.Fatalf("legacy user is gone: %v", err)
+	}
+	var entries []models.ChangeLogEntry
+	if err := DB.Find(&entries).Error; err != nil {
+		t.Fatalf("loading the change log failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].ModelName != "User" || entries[0].Operation != "create" {
+		t.Fatalf("change log = %+v, want one create of the legacy user", entries)
+	}
+	if !strings.Contains(string(entries[0].Changes), `"phoneNumber"`) {
+		t.Errorf("seeded changes %s don't use the JSON names of the fields", entries[0].Changes)
+	}
+
+	if err := MigrateTo(0, nil); err != nil {
+		t.Fatalf("MigrateTo(0) failed: %v", err)
+	}
+}
+
+func testCRUD(t *testing.T) {
+	if err := MigrateUp(nil); err != nil {
+		t.Fatalf("MigrateUp() failed: %v", err)
+	}
+	user := models.User{Name: "Bob", Email: "bob@example.com"}
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("creating a user failed: %v", err)
+	}
+	reading := models.BloodPressure{Systolic: 120, Diastolic: 80, Pulse: 60, MeasuredAt: DB.NowFunc(), UserId: uint(user.ID)}
+	if err := DB.Create(&reading).Error; err != nil {
+		t.Fatalf("creating a reading failed: %v", err)
+	}
+
+	var loaded models.BloodPressure
+	if err := DB.First(&loaded, reading.ID).Error; err != nil {
+		t.Fatalf("loading the reading failed: %v", err)
+	}
+	// Sync compares updated_at with what the client saw, the stored time has to come back unchanged
+	if !loaded.UpdatedAt.Equal(reading.UpdatedAt) || !loaded.MeasuredAt.Equal(reading.MeasuredAt) {
+		t.Errorf("times changed on the round trip: updated %v, want %v; measured %v, want %v",
+			loaded.UpdatedAt, reading.UpdatedAt, loaded.MeasuredAt, reading.MeasuredAt)
+	}
+	if loaded.Systolic != 120 || loaded.UserId != uint(user.ID) {
+		t.Errorf("loaded %+v, want %+v", loaded, reading)
+	}
+
+	if err := DB.Model(&loaded).Updates(models.BloodPressure{Systolic: 130, Medicine: "Ramipril"}).Error; err != nil {
+		t.Fatalf("updating the reading failed: %v", err)
+	}
+	var updated models.BloodPressure
+	if err := DB.First(&updated, reading.ID).Error; err != nil {
+		t.Fatalf("loading the updated reading failed: %v", err)
+	}
+	if updated.Systolic != 130 || updated.Medicine != "Ramipril" || updated.Diastolic != 80 {
+		t.Errorf("updated reading = %+v", updated)
+	}
+
+	if err := DB.Delete(&updated).Error; err != nil {
+		t.Fatalf("deleting the reading failed: %v", err)
+	}
+	if err := DB.First(&models.BloodPressure{}, reading.ID).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("loading the deleted reading = %v, want not found", err)
+	}
+	var trashed models.BloodPressure
+	if err := DB.Unscoped().First(&trashed, reading.ID).Error; err != nil || !trashed.DeletedAt.Valid {
+		t.Errorf("deleted reading isn't in the trash: %+v, %v", trashed, err)
+	}
+
+	if err := DB.Unscoped().Delete(&trashed).Error; err != nil {
+		t.Fatalf("purging the reading failed: %v", err)
+	}
+	var count int64
+	if err := DB.Unscoped().Model(&models.BloodPressure{}).Count(&count).Error; err != nil || count != 0 {
+		t.Errorf("%d readings left after purging, %v", count, err)
+	}
+}
+
+// assertApplied checks that exactly the migrations up to version are applied.
+func assertApplied(t *testing.T, version int) {
+	t.Helper()
+	states, err := MigrationStatus()
+	if err != nil {
+		t.Fatalf("MigrationStatus() failed: %v", err)
+	}
+	for _, state := range states {
+		if applied := state.AppliedAt != nil; applied != (state.Version <= version) {
+			t.Errorf("migration %d %s applied = %v, want migrations up to %d", state.Version, state.Name, applied, version)
+		}
+	}
+}
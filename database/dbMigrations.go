@@ -2,14 +2,43 @@ package database
 
 import (
 	"api/models"
+	"errors"
 	"log"
+	"os"
+	"strconv"
 )
 
+// Models are all models stored in the database. The schema itself is created by migrations.
+var Models = []any{
+	&models.User{}, &models.ApiKey{}, &models.MarketItem{}, &models.Category{}, &models.BloodPressure{}, &models.ModelUpdates{}, &models.LogBookEntry{},
+	&models.ChatThread{}, &models.ChatMessage{}, &models.Caregiver{}, &models.AlertRule{}, &models.LogRetentionPolicy{}, &models.ChangeLogEntry{},
+	&models.MarketItemImage{}, &models.MarketItemPriceChange{},
+}
+
+// migrateDb brings the schema up to date. A database migrated by a newer binary is left
+// alone; running against it could lose whatever the newer schema holds.
 func migrateDb() {
-	err := DB.AutoMigrate(
-		&models.User{}, &models.ApiKey{}, &models.MarketItem{}, &models.Category{}, &models.BloodPressure{}, &models.ModelUpdates{}, &models.LogBookEntry{},
-		&models.ChatThread{}, &models.ChatMessage{})
-	if err != nil {
+	if err := MigrateUp(nil); err != nil {
+		if errors.Is(err, ErrDatabaseAhead) {
+			log.Fatal("Refusing to start, ", err)
+		}
 		log.Fatal("Failed to migrate, ", err)
 	}
+	assignUnownedBloodPressure()
+}
+
+// assignUnownedBloodPressure gives readings from before records had an owner to
+// the user in LEGACY_RECORDS_OWNER_ID. Until then no user sees them.
+func assignUnownedBloodPressure() {
+	ownerId, err := strconv.Atoi(os.Getenv("LEGACY_RECORDS_OWNER_ID"))
+	if err != nil || ownerId <= 0 {
+		return
+	}
+	result := DB.Model(&models.BloodPressure{}).Where("user_id = 0 OR user_id IS NULL").UpdateColumn("user_id", ownerId)
+	if result.Error != nil {
+		log.Fatal("Failed to assign unowned blood pressure readings, ", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Assigned %d unowned blood pressure readings to user %d", result.RowsAffected, ownerId)
+	}
 }
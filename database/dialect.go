@@ -0,0 +1,244 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite" // SQLite driver for GORM
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Dialect holds everything that differs between the supported databases, so the rest of the
+// code never has to check which driver it runs on.
+type Dialect struct {
+	Name string
+	// TransactionalDDL means schema changes can be rolled back, which migrate -dry-run relies on
+	TransactionalDDL bool
+	// TimePrecision is how precisely timestamps are stored. Times from gorm are truncated to it,
+	// so a record compares equal to what was stored, e.g. UpdatedAt for sync conflicts.
+	TimePrecision time.Duration
+	// LikeEscape is the ESCAPE clause matching services.LikePattern
+	LikeEscape string
+	defaultDSN string
+	open       func(dsn string) gorm.Dialector
+	// tuneDSN adds the connection settings the driver takes in the DSN, nil when it takes none
+	tuneDSN func(dsn string, config ConnectionConfig) string
+	// storageStats reports how the database file is doing, nil for servers which manage their own storage
+	storageStats func(db *gorm.DB) (*StorageStats, error)
+	// tableBytes returns the bytes per table and of the whole database, as far as the database tells
+	tableBytes func(db *gorm.DB) (map[string]int64, *int64)
+}
+
+var dialects = map[string]Dialect{
+	"sqlite": {
+		Name:             "sqlite",
+		TransactionalDDL: true,
+		LikeEscape:       `ESCAPE '\'`,
+		defaultDSN:       "./database.db",
+		open:             sqlite.Open,
+		tuneDSN:          sqliteDSN,
+		storageStats:     sqliteStorageStats,
+		tableBytes:       sqliteTableBytes,
+	},
+	"postgres": {
+		Name:             "postgres",
+		TransactionalDDL: true,
+		TimePrecision:    time.Microsecond,
+		LikeEscape:       `ESCAPE '\'`,
+		open:             postgres.Open,
+		tableBytes:       postgresTableBytes,
+	},
+	// MySQL has no partial indexes. Unique indexes that leave out deleted rows elsewhere cover
+	// them too, so a record in the trash has to be restored or purged before it can be added again.
+	"mysql": {
+		Name:          "mysql",
+		TimePrecision: time.Millisecond,
+		// Backslash is the string escape in MySQL, so it has to be escaped itself
+		LikeEscape: `ESCAPE '\\'`,
+		open:       openMySQL,
+		tableBytes: mysqlTableBytes,
+	},
+}
+
+// ActiveDialect is the dialect of DB, set by Connect.
+var ActiveDialect Dialect
+
+// databaseConfig reads DB_DRIVER (sqlite, postgres or mysql, sqlite by default) and DB_DSN.
+// Only SQLite has a default DSN, the database.db file in the working directory.
+func databaseConfig() (Dialect, string, error) {
+	driver := strings.ToLower(os.Getenv("DB_DRIVER"))
+	if driver == "" || driver == "sqlite3" {
+		driver = "sqlite"
+	}
+	if driver == "postgresql" {
+		driver = "postgres"
+	}
+	dialect, ok := dialects[driver]
+	if !ok {
+		return dialect, "", fmt.Errorf("unknown DB_DRIVER %q, use sqlite, postgres or mysql", driver)
+	}
+	dsn := os.Getenv("DB_DSN")
+	if dsn == "" {
+		dsn = dialect.defaultDSN
+	}
+	if dsn == "" {
+		return dialect, "", fmt.Errorf("DB_DSN is required for %s", driver)
+	}
+	return dialect, dsn, nil
+}
+
+// TableBytes returns the bytes each table takes and the size of the whole database, when
+// the database can tell.
+func TableBytes() (map[string]int64, *int64) {
+	return ActiveDialect.tableBytes(DB)
+}
+
+// StorageStats describes the SQLite database file and its write-ahead log.
+type StorageStats struct {
+	PageCount     int64
+	PageSize      int64
+	FreelistCount int64
+	// WalBytes is nil when there is no WAL file, outside WAL mode or after the last connection closed
+	WalBytes    *int64
+	JournalMode string
+	Synchronous string
+	BusyTimeout time.Duration
+}
+
+// Storage returns the StorageStats of the database, or nil when it is not a SQLite file.
+func Storage() (*StorageStats, error) {
+	if ActiveDialect.storageStats == nil {
+		return nil, nil
+	}
+	return ActiveDialect.storageStats(DB)
+}
+
+func sqliteStorageStats(db *gorm.DB) (*StorageStats, error) {
+	// Pragmas are per connection, so they all have to run on the same one
+	var stats *StorageStats
+	err := db.Connection(func(conn *gorm.DB) error {
+		stats = &StorageStats{}
+		var synchronous, busyTimeout int64
+		var files []struct {
+			Name string
+			File string
+		}
+		err := errors.Join(
+			conn.Raw("PRAGMA page_count").Scan(&stats.PageCount).Error,
+			conn.Raw("PRAGMA page_size").Scan(&stats.PageSize).Error,
+			conn.Raw("PRAGMA freelist_count").Scan(&stats.FreelistCount).Error,
+			conn.Raw("PRAGMA journal_mode").Scan(&stats.JournalMode).Error,
+			conn.Raw("PRAGMA synchronous").Scan(&synchronous).Error,
+			conn.Raw("PRAGMA busy_timeout").Scan(&busyTimeout).Error,
+			conn.Raw("PRAGMA database_list").Scan(&files).Error,
+		)
+		if err != nil {
+			return err
+		}
+		stats.JournalMode = strings.ToUpper(stats.JournalMode)
+		if levels := []string{"OFF", "NORMAL", "FULL", "EXTRA"}; synchronous >= 0 && synchronous < int64(len(levels)) {
+			stats.Synchronous = levels[synchronous]
+		}
+		stats.BusyTimeout = time.Duration(busyTimeout) * time.Millisecond
+		for _, file := range files {
+			if file.Name != "main" || file.File == "" {
+				continue
+			}
+			if info, err := os.Stat(file.File + "-wal"); err == nil {
+				size := info.Size()
+				stats.WalBytes = &size
+			}
+		}
+		return nil
+	})
+	return stats, err
+}
+
+func sqliteTableBytes(db *gorm.DB) (map[string]int64, *int64) {
+	bytesByTable := map[string]int64{}
+	var rows []struct {
+		Name  string
+		Bytes int64
+	}
+	// dbstat is optional in SQLite builds, sizes are left out without it
+	if err := db.Raw("SELECT name, SUM(pgsize) AS bytes FROM dbstat GROUP BY name").Scan(&rows).Error; err == nil {
+		for _, row := range rows {
+			bytesByTable[row.Name] = row.Bytes
+		}
+	}
+	var pageCount, pageSize int64
+	if db.Raw("PRAGMA page_count").Scan(&pageCount).Error != nil || db.Raw("PRAGMA page_size").Scan(&pageSize).Error != nil {
+		return bytesByTable, nil
+	}
+	size := pageCount * pageSize
+	return bytesByTable, &size
+}
+
+func postgresTableBytes(db *gorm.DB) (map[string]int64, *int64) {
+	bytesByTable := map[string]int64{}
+	var rows []struct {
+		Name  string
+		Bytes int64
+	}
+	if err := db.Raw("SELECT relname AS name, pg_total_relation_size(relid) AS bytes FROM pg_catalog.pg_statio_user_tables").Scan(&rows).Error; err == nil {
+		for _, row := range rows {
+			bytesByTable[row.Name] = row.Bytes
+		}
+	}
+	var size int64
+	if db.Raw("SELECT pg_database_size(current_database())").Scan(&size).Error != nil {
+		return bytesByTable, nil
+	}
+	return bytesByTable, &size
+}
+
+// mysqlDialector gives strings in unique indexes the size the MySQL driver gives those in other
+// indexes, as MySQL can't index TEXT. Baseline creates such columns without a size.
+type mysqlDialector struct {
+	*mysql.Dialector
+}
+
+func openMySQL(dsn string) gorm.Dialector {
+	return mysqlDialector{mysql.Open(dsn).(*mysql.Dialector)}
+}
+
+func (d mysqlDialector) DataTypeOf(field *schema.Field) string {
+	if field.DataType == schema.String && field.Size == 0 && field.TagSettings["UNIQUEINDEX"] != "" {
+		sized := *field
+		sized.Size = 191 // utf8mb4, as for other indexes
+		return d.Dialector.DataTypeOf(&sized)
+	}
+	return d.Dialector.DataTypeOf(field)
+}
+
+// Migrator creates tables with the column types of d.
+func (d mysqlDialector) Migrator(db *gorm.DB) gorm.Migrator {
+	migrator := d.Dialector.Migrator(db).(mysql.Migrator)
+	migrator.Migrator.Config.Dialector = d
+	return migrator
+}
+
+func mysqlTableBytes(db *gorm.DB) (map[string]int64, *int64) {
+	var rows []struct {
+		Name  string
+		Bytes int64
+	}
+	err := db.Raw("SELECT table_name AS name, data_length + index_length AS bytes FROM information_schema.tables WHERE table_schema = DATABASE()").
+		Scan(&rows).Error
+	if err != nil {
+		return map[string]int64{}, nil
+	}
+	bytesByTable := map[string]int64{}
+	var size int64
+	for _, row := range rows {
+		bytesByTable[row.Name] = row.Bytes
+		size += row.Bytes
+	}
+	return bytesByTable, &size
+}
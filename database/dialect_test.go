@@ -0,0 +1,101 @@
+package database
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDatabaseConfig(t *testing.T) {
+	tests := []struct {
+		driver, dsn string
+		wantName    string
+		wantDSN     string
+		wantErr     bool
+	}{
+		{driver: "", dsn: "", wantName: "sqlite", wantDSN: "./database.db"},
+		{driver: "sqlite3", dsn: "/data/api.db", wantName: "sqlite", wantDSN: "/data/api.db"},
+		{driver: "SQLite", dsn: "", wantName: "sqlite", wantDSN: "./database.db"},
+		{driver: "postgres", dsn: "host=db user=api", wantName: "postgres", wantDSN: "host=db user=api"},
+		{driver: "postgresql", dsn: "postgres://api@db/api", wantName: "postgres", wantDSN: "postgres://api@db/api"},
+		{driver: "mysql", dsn: "api@tcp(db)/api", wantName: "mysql", wantDSN: "api@tcp(db)/api"},
+		{driver: "postgres", dsn: "", wantErr: true},
+		{driver: "mysql", dsn: "", wantErr: true},
+		{driver: "oracle", dsn: "api@db", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.driver+"/"+test.dsn, func(t *testing.T) {
+			t.Setenv("DB_DRIVER", test.driver)
+			t.Setenv("DB_DSN", test.dsn)
+			dialect, dsn, err := databaseConfig()
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("databaseConfig() = %s %q, want an error", dialect.Name, dsn)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("databaseConfig() failed: %v", err)
+			}
+			if dialect.Name != test.wantName || dsn != test.wantDSN {
+				t.Errorf("databaseConfig() = %s %q, want %s %q", dialect.Name, dsn, test.wantName, test.wantDSN)
+			}
+		})
+	}
+}
+
+func TestSqliteDSN(t *testing.T) {
+	config := ConnectionConfig{JournalMode: "WAL", Synchronous: "NORMAL", BusyTimeout: 5 * time.Second, TxLock: "immediate"}
+	tests := []struct {
+		name, dsn  string
+		wantPath   string
+		wantParams url.Values
+	}{
+		{
+			name:     "defaults",
+			dsn:      "./database.db",
+			wantPath: "./database.db",
+			wantParams: url.Values{"_journal_mode": {"WAL"}, "_synchronous": {"NORMAL"},
+				"_busy_timeout": {"5000"}, "_txlock": {"immediate"}},
+		},
+		{
+			name:     "dsn wins",
+			dsn:      "file:api.db?_journal_mode=DELETE&_txlock=deferred&cache=shared",
+			wantPath: "file:api.db",
+			wantParams: url.Values{"_journal_mode": {"DELETE"}, "_synchronous": {"NORMAL"},
+				"_busy_timeout": {"5000"}, "_txlock": {"deferred"}, "cache": {"shared"}},
+		},
+		{
+			name:     "aliases win",
+			dsn:      "api.db?_journal=TRUNCATE&_sync=FULL&_timeout=100",
+			wantPath: "api.db",
+			wantParams: url.Values{"_journal": {"TRUNCATE"}, "_sync": {"FULL"},
+				"_timeout": {"100"}, "_txlock": {"immediate"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := sqliteDSN(test.dsn, config)
+			path, query, _ := strings.Cut(got, "?")
+			params, err := url.ParseQuery(query)
+			if err != nil {
+				t.Fatalf("sqliteDSN(%q) = %q, not a valid query: %v", test.dsn, got, err)
+			}
+			if path != test.wantPath || params.Encode() != test.wantParams.Encode() {
+				t.Errorf("sqliteDSN(%q) = %q, want %s?%s", test.dsn, got, test.wantPath, test.wantParams.Encode())
+			}
+		})
+	}
+
+	t.Run("empty settings", func(t *testing.T) {
+		if got := sqliteDSN("api.db", ConnectionConfig{}); got != "api.db?_busy_timeout=0" {
+			t.Errorf("sqliteDSN() = %q, want only the busy timeout", got)
+		}
+	})
+	t.Run("broken query", func(t *testing.T) {
+		if got := sqliteDSN("api.db?%zz", config); got != "api.db?%zz" {
+			t.Errorf("sqliteDSN() = %q, want the DSN unchanged", got)
+		}
+	})
+}
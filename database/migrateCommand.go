@@ -0,0 +1,110 @@
+package database
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const migrateUsage = `Usage: api migrate [-dry-run] <command>
+
+Commands:
+  status        list the migrations and whether they are applied
+  up            apply all pending migrations
+  down [n]      revert the last n migrations, 1 by default
+  to <version>  apply or revert migrations until version is the last one applied, 0 reverts all
+
+With -dry-run nothing is changed; the SQL that would run is printed instead.
+`
+
+// RunMigrateCommand runs the migrate subcommand with the arguments after "migrate".
+func RunMigrateCommand(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.SetOutput(out)
+	flags.Usage = func() { fmt.Fprint(out, migrateUsage) }
+	dryRun := flags.Bool("dry-run", false, "print the SQL instead of running it")
+	// Parse stops at the first argument that is not a flag, go on after it so flags may
+	// follow the command too, like in "migrate up -dry-run"
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+	var command string
+	var rest []string
+	if len(positional) > 0 {
+		command, rest = positional[0], positional[1:]
+	}
+	maxArgs, ok := map[string]int{"status": 0, "up": 0, "down": 1, "to": 1}[command]
+	if !ok {
+		flags.Usage()
+		return fmt.Errorf("unknown migrate command %q", command)
+	}
+	if len(rest) > maxArgs {
+		flags.Usage()
+		return fmt.Errorf("too many arguments for %s: %s", command, strings.Join(rest, " "))
+	}
+	var sqlOut io.Writer
+	if *dryRun {
+		sqlOut = out
+	}
+
+	Connect()
+	switch command {
+	case "status":
+		return printMigrationStatus(out)
+	case "up":
+		return MigrateUp(sqlOut)
+	case "down":
+		steps := 1
+		if len(rest) > 0 {
+			n, err := strconv.Atoi(rest[0])
+			if err != nil {
+				return fmt.Errorf("down takes a number of migrations, got %q", rest[0])
+			}
+			steps = n
+		}
+		return MigrateDown(steps, sqlOut)
+	case "to":
+		if len(rest) == 0 {
+			return errors.New("to needs a version")
+		}
+		version, err := strconv.Atoi(rest[0])
+		if err != nil {
+			return fmt.Errorf("to takes a version number, got %q", rest[0])
+		}
+		return MigrateTo(version, sqlOut)
+	}
+	return nil
+}
+
+func printMigrationStatus(out io.Writer) error {
+	states, err := MigrationStatus()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+	for _, state := range states {
+		applied := "pending"
+		if state.AppliedAt != nil {
+			applied = state.AppliedAt.Format(time.RFC3339)
+		}
+		if !state.Known {
+			applied += " (unknown to this binary)"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", state.Version, state.Name, applied)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRunMigrateCommandRejectsArguments(t *testing.T) {
+	tests := []struct {
+		args    string
+		wantErr string
+	}{
+		{args: "", wantErr: "unknown migrate command"},
+		{args: "sideways", wantErr: "unknown migrate command"},
+		{args: "up 3", wantErr: "too many arguments"},
+		{args: "status -dry-run now", wantErr: "too many arguments"},
+		{args: "to 3 4", wantErr: "too many arguments"},
+		{args: "down 1 -dry-run 2", wantErr: "too many arguments"},
+		{args: "to 3 -force", wantErr: "flag provided but not defined"},
+	}
+	for _, test := range tests {
+		t.Run(test.args, func(t *testing.T) {
+			// Rejected before connecting, so no database is needed
+			err := RunMigrateCommand(strings.Fields(test.args), io.Discard)
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("RunMigrateCommand(%q) = %v, want %q", test.args, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunMigrateCommandDryRunAfterCommand(t *testing.T) {
+	t.Setenv("DB_MAX_OPEN_CONNS", "1")
+	t.Setenv("DB_DRIVER", "sqlite")
+	t.Setenv("DB_DSN", "file:"+t.Name()+"?mode=memory&cache=shared")
+	t.Cleanup(func() {
+		if sqlDB, err := DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	var out strings.Builder
+	if err := RunMigrateCommand([]string{"up", "-dry-run"}, &out); err != nil {
+		t.Fatalf("migrate up -dry-run failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "-- up 1 baseline") {
+		t.Errorf("migrate up -dry-run printed %q, want the SQL of the migrations", out.String())
+	}
+	assertApplied(t, 0)
+}
@@ -0,0 +1,540 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+	"gorm.io/gorm"
+)
+
+// migrations in the order they are applied. Append new ones with the next version; never
+// change one that has been released, databases already ran it.
+var migrations = []Migration{
+	{Version: 1, Name: "baseline", Up: baselineUp, Down: baselineDown},
+	{Version: 2, Name: "seed_change_log", Up: func(tx *gorm.DB) error {
+		return backfillChangeLog(tx, seedChangeLogTables())
+	}, Down: func(tx *gorm.DB) error {
+		// The seeded creates can't be told apart from real ones any more
+		return nil
+	}},
+	{Version: 3, Name: "market_item_images", Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(marketItemImagesTable())
+	}, Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(marketItemImagesTable())
+	}},
+	{Version: 4, Name: "market_item_lifecycle", Up: func(tx *gorm.DB) error {
+		item, priceChange := marketItemLifecycleTables()
+		if err := tx.AutoMigrate(item, priceChange); err != nil {
+			return err
+		}
+		// Items from before were all listed since they were created
+		err := tx.Exec("UPDATE market_items SET status = ?, published_at = created_at WHERE status IS NULL OR status = ''", "active").Error
+		if err != nil {
+			return err
+		}
+		// Their price history starts with the price they have now
+		return tx.Exec("INSERT INTO market_item_price_changes (market_item_id, price, changed_at) " +
+			"SELECT id, price, created_at FROM market_items").Error
+	}, Down: func(tx *gorm.DB) error {
+		item, priceChange := marketItemLifecycleTables()
+		if err := tx.Migrator().DropTable(priceChange); err != nil {
+			return err
+		}
+		for _, column := range []string{"Status", "ReservedById", "ReservedUntil", "PublishedAt", "SoldAt"} {
+			if err := tx.Migrator().DropColumn(item, column); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{Version: 5, Name: "category_hierarchy", Up: func(tx *gorm.DB) error {
+		// The unique slug index can only be created once every category has its own slug
+		if err := tx.AutoMigrate(categoryHierarchyTable(false)); err != nil {
+			return err
+		}
+		if err := backfillCategorySlugs(tx); err != nil {
+			return err
+		}
+		return tx.Migrator().CreateIndex(categoryHierarchyTable(true), "idx_category_slug")
+	}, Down: func(tx *gorm.DB) error {
+		category := categoryHierarchyTable(true)
+		if err := tx.Migrator().DropIndex(category, "idx_category_slug"); err != nil {
+			return err
+		}
+		for _, column := range []string{"Slug", "ParentId", "Icon", "Color"} {
+			if err := tx.Migrator().DropColumn(category, column); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{Version: 6, Name: "index_column_sizes", Up: func(tx *gorm.DB) error {
+		return alterIndexColumns(tx, indexColumnTables(true))
+	}, Down: func(tx *gorm.DB) error {
+		return alterIndexColumns(tx, indexColumnTables(false))
+	}},
+}
+
+// baselineTables are the tables as they were when versioned migrations were introduced.
+// Databases from before that were created by AutoMigrate with these same structs, so for
+// them baseline only fills in what an older binary had not created yet. The types are local
+// so they can carry the model names, which decide the table and constraint names.
+func baselineTables() []any {
+	type BaseModel struct {
+		ID        int `gorm:"primaryKey"`
+		CreatedAt time.Time
+		UpdatedAt time.Time
+		DeletedAt gorm.DeletedAt `gorm:"index"`
+	}
+	type (
+		User struct {
+			BaseModel
+			Name  string
+			Phone string
+			Email string
+		}
+		ApiKey struct {
+			BaseModel
+			Name       string
+			Prefix     string `gorm:"uniqueIndex"`
+			KeyHash    string
+			Salt       string
+			Scopes     string
+			Type       string
+			ExpiresAt  *time.Time
+			LastUsedAt *time.Time
+			RevokedAt  *time.Time
+			UserId     uint
+			User       User
+		}
+		Category struct {
+			BaseModel
+			Title string
+		}
+		MarketItem struct {
+			BaseModel
+			Description string
+			Price       float32
+			Title       string
+			CategoryId  uint
+			Category    Category `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+			UserId      uint
+			User        User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+		}
+		BloodPressure struct {
+			BaseModel
+			Systolic   int
+			Diastolic  int
+			Pulse      int
+			Medicine   string
+			MeasuredAt time.Time `gorm:"index"`
+			UserId     uint      `gorm:"index"`
+		}
+		ModelUpdates struct {
+			BaseModel
+			ModelName string `gorm:"uniqueIndex"`
+			Method    string
+		}
+		LogBookEntry struct {
+			BaseModel
+			Message   string
+			Level     string    `gorm:"index"`
+			Category  string    `gorm:"index"`
+			Timestamp time.Time `gorm:"index"`
+			UserId    uint      `gorm:"index"`
+		}
+		ChatThread struct {
+			BaseModel
+			Title string `gorm:"size:512"`
+		}
+		ChatMessage struct {
+			BaseModel
+			ThreadID int    `gorm:"not null;index"`
+			Role     string `gorm:"size:32;not null"`
+			Content  string `gorm:"type:text;not null"`
+		}
+		Caregiver struct {
+			BaseModel
+			PatientId   uint `gorm:"uniqueIndex:idx_caregiver_pair,where:deleted_at IS NULL"`
+			Patient     User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+			CaregiverId uint `gorm:"uniqueIndex:idx_caregiver_pair;index"`
+			Caregiver   User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+		}
+		AlertRule struct {
+			BaseModel
+			Name        string
+			Type        string
+			Field       string
+			Operator    string
+			Threshold   float64
+			Count       int
+			WindowHours int
+			Notifier    string
+			Target      string
+			Enabled     bool
+			LastFiredAt *time.Time
+			UserId      uint `gorm:"index"`
+			CreatedById uint
+		}
+		LogRetentionPolicy struct {
+			BaseModel
+			Level      string `gorm:"uniqueIndex:idx_log_retention_scope,where:deleted_at IS NULL"`
+			Category   string `gorm:"uniqueIndex:idx_log_retention_scope"`
+			MaxAgeDays int
+			Archive    bool
+		}
+		ChangeLogEntry struct {
+			ID        uint64 `gorm:"primaryKey"`
+			ModelName string `gorm:"index:idx_change_log_record"`
+			RecordId  string `gorm:"index:idx_change_log_record"`
+			Operation string
+			Changes   json.RawMessage `gorm:"type:text"`
+			UserId    *uint
+			OwnerId   *uint     `gorm:"index"`
+			Timestamp time.Time `gorm:"index"`
+		}
+	)
+
+	return []any{
+		&User{}, &ApiKey{}, &MarketItem{}, &Category{}, &BloodPressure{}, &ModelUpdates{}, &LogBookEntry{},
+		&ChatThread{}, &ChatMessage{}, &Caregiver{}, &AlertRule{}, &LogRetentionPolicy{}, &ChangeLogEntry{},
+	}
+}
+
+// seedChangeLogTables are the tables seed_change_log recorded, with the JSON names the change
+// log used for their fields at the time.
+func seedChangeLogTables() []any {
+	type BaseModel struct {
+		ID        int            `json:"id" gorm:"primaryKey"`
+		CreatedAt time.Time      `json:"createdAt"`
+		UpdatedAt time.Time      `json:"updatedAt"`
+		DeletedAt gorm.DeletedAt `json:"deletedAt"`
+	}
+	type (
+		User struct {
+			BaseModel
+			Name  string `json:"name"`
+			Phone string `json:"phoneNumber"`
+			Email string `json:"email"`
+		}
+		ApiKey struct {
+			BaseModel
+			Name       string     `json:"name"`
+			Prefix     string     `json:"prefix"`
+			KeyHash    string     `json:"-"`
+			Salt       string     `json:"-"`
+			Scopes     string     `json:"scopes"`
+			Type       string     `json:"api_type"`
+			ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+			LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+			RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+			UserId     uint       `json:"user_id"`
+		}
+		MarketItem struct {
+			BaseModel
+			Description string  `json:"description"`
+			Price       float32 `json:"price"`
+			Title       string  `json:"title"`
+			CategoryId  uint
+			UserId      uint
+		}
+		Category struct {
+			BaseModel
+			Title string `json:"title"`
+		}
+		BloodPressure struct {
+			BaseModel
+			Systolic   int       `json:"systolic"`
+			Diastolic  int       `json:"diastolic"`
+			Pulse      int       `json:"pulse"`
+			Medicine   string    `json:"medicine"`
+			MeasuredAt time.Time `json:"measuredAt"`
+			UserId     uint      `json:"userId"`
+		}
+		LogBookEntry struct {
+			BaseModel
+			Message   string    `json:"message"`
+			Level     string    `json:"level"`
+			Category  string    `json:"category"`
+			Timestamp time.Time `json:"timestamp"`
+			UserId    uint      `json:"userId"`
+		}
+		ChatThread struct {
+			BaseModel
+			Title string `json:"title"`
+		}
+		ChatMessage struct {
+			BaseModel
+			ThreadID int    `json:"threadId"`
+			Role     string `json:"role"`
+			Content  string `json:"content"`
+		}
+		Caregiver struct {
+			BaseModel
+			PatientId   uint `json:"patientId"`
+			CaregiverId uint `json:"caregiverId"`
+		}
+		AlertRule struct {
+			BaseModel
+			Name        string     `json:"name"`
+			Type        string     `json:"type"`
+			Field       string     `json:"field"`
+			Operator    string     `json:"operator"`
+			Threshold   float64    `json:"threshold"`
+			Count       int        `json:"count"`
+			WindowHours int        `json:"windowHours"`
+			Notifier    string     `json:"notifier"`
+			Target      string     `json:"target"`
+			Enabled     bool       `json:"enabled"`
+			LastFiredAt *time.Time `json:"lastFiredAt,omitempty"`
+			UserId      uint       `json:"userId"`
+			CreatedById uint       `json:"createdById"`
+		}
+		LogRetentionPolicy struct {
+			BaseModel
+			Level      string `json:"level"`
+			Category   string `json:"category"`
+			MaxAgeDays int    `json:"maxAgeDays"`
+			Archive    bool   `json:"archive"`
+		}
+	)
+
+	return []any{
+		&User{}, &ApiKey{}, &MarketItem{}, &Category{}, &BloodPressure{}, &LogBookEntry{},
+		&ChatThread{}, &ChatMessage{}, &Caregiver{}, &AlertRule{}, &LogRetentionPolicy{},
+	}
+}
+
+// indexColumnTables are the string columns in unique indexes, with the size they got for MySQL,
+// which can't index TEXT, or without it as baseline created them.
+func indexColumnTables(sized bool) map[any][]string {
+	if !sized {
+		type (
+			ApiKey struct {
+				Prefix string `gorm:"uniqueIndex"`
+			}
+			ModelUpdates struct {
+				ModelName string `gorm:"uniqueIndex"`
+			}
+			LogRetentionPolicy struct {
+				Level    string `gorm:"uniqueIndex:idx_log_retention_scope,where:deleted_at IS NULL"`
+				Category string `gorm:"uniqueIndex:idx_log_retention_scope"`
+			}
+		)
+		return map[any][]string{&ApiKey{}: {"Prefix"}, &ModelUpdates{}: {"ModelName"}, &LogRetentionPolicy{}: {"Level", "Category"}}
+	}
+	type (
+		ApiKey struct {
+			Prefix string `gorm:"size:32;uniqueIndex"`
+		}
+		ModelUpdates struct {
+			ModelName string `gorm:"size:64;uniqueIndex"`
+		}
+		LogRetentionPolicy struct {
+			Level    string `gorm:"size:16;uniqueIndex:idx_log_retention_scope,where:deleted_at IS NULL"`
+			Category string `gorm:"size:100;uniqueIndex:idx_log_retention_scope"`
+		}
+	)
+	return map[any][]string{&ApiKey{}: {"Prefix"}, &ModelUpdates{}: {"ModelName"}, &LogRetentionPolicy{}: {"Level", "Category"}}
+}
+
+func alterIndexColumns(tx *gorm.DB, tables map[any][]string) error {
+	if tx.Dialector.Name() == "sqlite" {
+		// SQLite ignores column sizes, and altering a column there copies the whole table
+		return nil
+	}
+	for table, columns := range tables {
+		for _, column := range columns {
+			if err := tx.Migrator().AlterColumn(table, column); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func baselineUp(tx *gorm.DB) error {
+	if err := dropLegacyApiKeyColumns(tx); err != nil {
+		return err
+	}
+	if err := dropFullUniqueIndexes(tx); err != nil {
+		return err
+	}
+	if err := tx.AutoMigrate(baselineTables()...); err != nil {
+		return err
+	}
+	// Readings from before measuredAt existed were measured when they were created
+	return tx.Table("blood_pressures").Where("measured_at IS NULL").UpdateColumn("measured_at", gorm.Expr("created_at")).Error
+}
+
+func baselineDown(tx *gorm.DB) error {
+	tables := baselineTables()
+	for i := len(tables) - 1; i >= 0; i-- {
+		if err := tx.Migrator().DropTable(tables[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropLegacyApiKeyColumns removes the plaintext key columns from before keys were hashed.
+// Keys stored that way cannot be verified any more, so they are revoked and have to be issued again,
+// starting with an admin key from "api keys create -admin".
+func dropLegacyApiKeyColumns(tx *gorm.DB) error {
+	for _, column := range []string{"api_key", "client_secret", "client_id"} {
+		if !tx.Migrator().HasColumn("api_keys", column) {
+			continue
+		}
+		err := tx.Table("api_keys").Where("key_hash IS NULL OR key_hash = ''").UpdateColumn("revoked_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Migrator().DropColumn("api_keys", column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unique indexes that only cover records that are not deleted, so a record in the trash does
+// not block creating it again.
+var softDeleteUniqueIndexes = []struct {
+	table string
+	name  string
+}{
+	{"caregivers", "idx_caregiver_pair"},
+	{"log_retention_policies", "idx_log_retention_scope"},
+}
+
+// dropFullUniqueIndexes drops the softDeleteUniqueIndexes created before they were partial,
+// AutoMigrate then creates them again with the deleted_at condition.
+func dropFullUniqueIndexes(tx *gorm.DB) error {
+	if tx.Dialector.Name() != "sqlite" {
+		// Databases from before were always SQLite
+		return nil
+	}
+	for _, index := range softDeleteUniqueIndexes {
+		var definition string
+		err := tx.Raw("SELECT sql FROM sqlite_master WHERE type = 'index' AND name = ?", index.name).Scan(&definition).Error
+		if err != nil {
+			return err
+		}
+		if definition == "" || strings.Contains(strings.ToUpper(definition), "WHERE") {
+			continue
+		}
+		if err := tx.Migrator().DropIndex(index.table, index.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// marketItemImagesTable is the market_item_images table as migration 3 created it.
+func marketItemImagesTable() any {
+	type BaseModel struct {
+		ID        int `gorm:"primaryKey"`
+		CreatedAt time.Time
+		UpdatedAt time.Time
+		DeletedAt gorm.DeletedAt `gorm:"index"`
+	}
+	type MarketItemImage struct {
+		BaseModel
+		MarketItemId uint   `gorm:"index"`
+		FileName     string `gorm:"size:255"`
+		ContentType  string `gorm:"size:32"`
+		Size         int64
+		Width        int
+		Height       int
+		Position     int
+	}
+	return &MarketItemImage{}
+}
+
+// marketItemLifecycleTables are the columns migration 4 added to market_items and the
+// market_item_price_changes table it created.
+func marketItemLifecycleTables() (item any, priceChange any) {
+	type MarketItem struct {
+		ID            int    `gorm:"primaryKey"`
+		Status        string `gorm:"size:16;index"`
+		ReservedById  *uint
+		ReservedUntil *time.Time
+		PublishedAt   *time.Time
+		SoldAt        *time.Time
+	}
+	type MarketItemPriceChange struct {
+		ID           uint64 `gorm:"primaryKey"`
+		MarketItemId uint   `gorm:"index"`
+		OldPrice     *float32
+		Price        float32
+		ChangedAt    time.Time
+	}
+	return &MarketItem{}, &MarketItemPriceChange{}
+}
+
+// categoryHierarchyTable is the columns migration 5 added to categories, with or without the
+// unique index on the slug.
+func categoryHierarchyTable(slugIndex bool) any {
+	if slugIndex {
+		type Category struct {
+			ID       int    `gorm:"primaryKey"`
+			Slug     string `gorm:"size:100;uniqueIndex:idx_category_slug,where:deleted_at IS NULL"`
+			ParentId *uint  `gorm:"index"`
+			Icon     string `gorm:"size:64"`
+			Color    string `gorm:"size:7"`
+		}
+		return &Category{}
+	}
+	type Category struct {
+		ID       int    `gorm:"primaryKey"`
+		Slug     string `gorm:"size:100"`
+		ParentId *uint  `gorm:"index"`
+		Icon     string `gorm:"size:64"`
+		Color    string `gorm:"size:7"`
+	}
+	return &Category{}
+}
+
+var nonSlugCharacters = regexp.MustCompile(`[^a-z0-9]+`)
+
+// backfillCategorySlugs derives a slug from the title of every category like services.Slugify
+// did at the time, numbered when titles repeat. Deleted categories get one too, so they can be restored.
+func backfillCategorySlugs(tx *gorm.DB) error {
+	var categories []struct {
+		ID    int
+		Title string
+	}
+	if err := tx.Table("categories").Select("id", "title").Order("id").Find(&categories).Error; err != nil {
+		return err
+	}
+	taken := map[string]bool{}
+	for _, category := range categories {
+		var plain strings.Builder
+		for _, r := range norm.NFD.String(strings.ToLower(category.Title)) {
+			if !unicode.Is(unicode.Mn, r) {
+				plain.WriteRune(r)
+			}
+		}
+		base := strings.Trim(nonSlugCharacters.ReplaceAllString(plain.String(), "-"), "-")
+		if base == "" {
+			base = "category"
+		}
+		// Room for the number within the 100 characters of the column
+		if len(base) > 90 {
+			base = strings.TrimRight(base[:90], "-")
+		}
+		slug := base
+		for n := 2; taken[slug]; n++ {
+			slug = fmt.Sprintf("%s-%d", base, n)
+		}
+		taken[slug] = true
+		if err := tx.Table("categories").Where("id = ?", category.ID).Update("slug", slug).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Migration is one versioned step of the schema. Up and Down each run in a transaction and
+// Down undoes Up. Migrations must not use the structs in models, which keep changing after
+// the migration was written; they declare the tables as they were at that version instead.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// schemaMigration is a row of schema_migrations, one per applied migration.
+type schemaMigration struct {
+	Version   int `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// MigrationState is a migration known to this binary, applied to the database or both.
+type MigrationState struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+	// Known is false for migrations applied by a newer binary
+	Known bool
+}
+
+// ErrDatabaseAhead is returned when the database has migrations applied this binary does not know.
+var ErrDatabaseAhead = errors.New("database schema is newer than this binary")
+
+// LatestMigration is the version the schema has after all migrations of this binary.
+func LatestMigration() int {
+	return migrations[len(migrations)-1].Version
+}
+
+func findMigration(version int) (Migration, bool) {
+	for _, migration := range migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+// appliedMigrations lists the applied migrations, creating the table that records them first.
+func appliedMigrations(db *gorm.DB) ([]schemaMigration, error) {
+	if err := db.AutoMigrate(&schemaMigration{}); err != nil {
+		return nil, err
+	}
+	return readAppliedMigrations(db)
+}
+
+// readAppliedMigrations lists the applied migrations without touching the schema, none
+// when the table that records them doesn't exist yet.
+func readAppliedMigrations(db *gorm.DB) ([]schemaMigration, error) {
+	var applied []schemaMigration
+	if !db.Migrator().HasTable(&schemaMigration{}) {
+		return applied, nil
+	}
+	err := db.Order("version").Find(&applied).Error
+	return applied, err
+}
+
+// MigrationStatus lists the migrations of this binary and those applied to the database, by version.
+func MigrationStatus() ([]MigrationState, error) {
+	applied, err := appliedMigrations(DB)
+	if err != nil {
+		return nil, err
+	}
+	var states []MigrationState
+	for _, migration := range migrations {
+		states = append(states, MigrationState{Version: migration.Version, Name: migration.Name, Known: true})
+	}
+	for _, row := range applied {
+		i := slices.IndexFunc(states, func(state MigrationState) bool { return state.Version == row.Version })
+		if i < 0 {
+			states = append(states, MigrationState{Version: row.Version, Name: row.Name})
+			i = len(states) - 1
+		}
+		appliedAt := row.AppliedAt
+		states[i].AppliedAt = &appliedAt
+	}
+	slices.SortFunc(states, func(a, b MigrationState) int { return a.Version - b.Version })
+	return states, nil
+}
+
+// checkNotAhead fails with ErrDatabaseAhead when a migration was applied that this binary does not know.
+func checkNotAhead(applied []schemaMigration) error {
+	for _, row := range applied {
+		if _, ok := findMigration(row.Version); !ok {
+			return fmt.Errorf("%w: migration %d (%s) is applied, this binary only knows up to %d",
+				ErrDatabaseAhead, row.Version, row.Name, LatestMigration())
+		}
+	}
+	return nil
+}
+
+// MigrateUp applies all pending migrations.
+func MigrateUp(dryRun io.Writer) error {
+	return MigrateTo(LatestMigration(), dryRun)
+}
+
+// MigrateDown reverts the last steps applied migrations.
+func MigrateDown(steps int, dryRun io.Writer) error {
+	// Only reads, a dry run must not even create the migrations table
+	applied, err := readAppliedMigrations(DB)
+	if err != nil {
+		return err
+	}
+	if steps < 1 || steps > len(applied) {
+		return fmt.Errorf("can revert between 1 and %d migrations", len(applied))
+	}
+	target := 0
+	if steps < len(applied) {
+		target = applied[len(applied)-steps-1].Version
+	}
+	return MigrateTo(target, dryRun)
+}
+
+// MigrateTo applies or reverts migrations until version is the last one applied, 0 reverts
+// everything. With dryRun set nothing is changed; the SQL that would run is written to dryRun.
+func MigrateTo(version int, dryRun io.Writer) error {
+	if _, ok := findMigration(version); !ok && version != 0 {
+		return fmt.Errorf("unknown migration %d", version)
+	}
+	db := DB
+	if dryRun != nil {
+		if !ActiveDialect.TransactionalDDL {
+			return fmt.Errorf("dry-run is not supported on %s, schema changes there can't be rolled back", ActiveDialect.Name)
+		}
+		// Run everything in one transaction that is rolled back, so later steps see the earlier ones
+		db = DB.Session(&gorm.Session{Logger: sqlPrinter{Interface: DB.Logger, out: dryRun}}).Begin()
+		if db.Error != nil {
+			return db.Error
+		}
+		defer db.Rollback()
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return err
+	}
+	if err := checkNotAhead(applied); err != nil {
+		return err
+	}
+	isApplied := map[int]bool{}
+	for _, row := range applied {
+		isApplied[row.Version] = true
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		migration := migrations[i]
+		if migration.Version <= version || !isApplied[migration.Version] {
+			continue
+		}
+		if err := runMigration(db, migration, "down", dryRun); err != nil {
+			return err
+		}
+	}
+	for _, migration := range migrations {
+		if migration.Version > version || isApplied[migration.Version] {
+			continue
+		}
+		if err := runMigration(db, migration, "up", dryRun); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runMigration(db *gorm.DB, migration Migration, direction string, dryRun io.Writer) error {
+	if dryRun != nil {
+		fmt.Fprintf(dryRun, "-- %s %d %s\n", direction, migration.Version, migration.Name)
+	} else {
+		log.Printf("Migrating %s %d %s", direction, migration.Version, migration.Name)
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if direction == "down" {
+			if err := migration.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&schemaMigration{}, migration.Version).Error
+		}
+		if err := migration.Up(tx); err != nil {
+			return err
+		}
+		return tx.Create(&schemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migration %d %s %s: %w", migration.Version, migration.Name, direction, err)
+	}
+	return nil
+}
+
+// sqlPrinter writes the statements that change the database, leaving out the queries the
+// migrator uses to look at the schema.
+type sqlPrinter struct {
+	logger.Interface
+	out io.Writer
+}
+
+func (p sqlPrinter) LogMode(level logger.LogLevel) logger.Interface {
+	return sqlPrinter{Interface: p.Interface.LogMode(level), out: p.out}
+}
+
+func (p sqlPrinter) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	sql, _ := fc()
+	keyword, _, _ := strings.Cut(strings.TrimSpace(sql), " ")
+	switch strings.ToUpper(keyword) {
+	case "SELECT", "PRAGMA", "SAVEPOINT", "RELEASE", "ROLLBACK":
+		return
+	}
+	fmt.Fprintln(p.out, sql+";")
+}
@@ -9,29 +9,26 @@ import (
 func setupModelTracking() {
 	// Register callbacks for all models
 	DB.Callback().Create().After("gorm:create").Register("track_model_updates", func(db *gorm.DB) {
-		if db.Error == nil {
-			modelName := db.Statement.Schema.Name
-			if modelName != "ModelUpdates" {
-				updateModelTracking(db, modelName, "create")
-			}
+		if changeLogTracked(db) {
+			updateModelTracking(db, db.Statement.Schema.Name, "create")
+			recordChanges(db, "create")
 		}
 	})
 
+	// Updates and deletes need the rows as they were before to know what changed
+	DB.Callback().Update().Before("gorm:update").Register("capture_changed_rows", captureChangedRows)
 	DB.Callback().Update().After("gorm:update").Register("track_model_updates", func(db *gorm.DB) {
-		if db.Error == nil {
-			modelName := db.Statement.Schema.Name
-			if modelName != "ModelUpdates" {
-				updateModelTracking(db, modelName, "update")
-			}
+		if changeLogTracked(db) {
+			updateModelTracking(db, db.Statement.Schema.Name, "update")
+			recordChanges(db, "update")
 		}
-	})	
+	})
 
+	DB.Callback().Delete().Before("gorm:delete").Register("capture_changed_rows", captureChangedRows)
 	DB.Callback().Delete().After("gorm:delete").Register("track_model_updates", func(db *gorm.DB) {
-		if db.Error == nil {
-				modelName := db.Statement.Schema.Name
-			if modelName != "ModelUpdates" {
-				updateModelTracking(db, modelName, "delete")
-			}
+		if changeLogTracked(db) {
+			updateModelTracking(db, db.Statement.Schema.Name, "delete")
+			recordChanges(db, "delete")
 		}
 	})
 }
@@ -41,9 +38,9 @@ func updateModelTracking(db *gorm.DB, modelName, method string) {
 		ModelName: modelName,
 		Method:    method,
 	}
-	
-	// Upsert the model update record
-	db.Where(models.ModelUpdates{ModelName: modelName}).
+
+	// Upsert the model update record on a fresh statement, db still holds the tracked model's statement
+	db.Session(&gorm.Session{NewDB: true}).Where(models.ModelUpdates{ModelName: modelName}).
 		Assign(models.ModelUpdates{Method: method}).
 		FirstOrCreate(&modelUpdate)
-}
\ No newline at end of file
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// slowQueryLimit is how many of the most recent slow queries are kept
+const slowQueryLimit = 50
+
+// SlowQuery is a query that took at least ActiveConnectionConfig.SlowQuery.
+type SlowQuery struct {
+	SQL      string
+	Duration time.Duration
+	Rows     int64
+	Error    string
+	At       time.Time
+}
+
+// slowQueryLog keeps the most recent slow queries in memory, newest last.
+type slowQueryLog struct {
+	mu      sync.Mutex
+	queries []SlowQuery
+	total   int64
+}
+
+var slowQueries slowQueryLog
+
+func (l *slowQueryLog) add(query SlowQuery) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.total++
+	if len(l.queries) == slowQueryLimit {
+		l.queries = append(l.queries[:0], l.queries[1:]...)
+	}
+	l.queries = append(l.queries, query)
+}
+
+// SlowQueries returns the most recent slow queries, newest first, and how many there were since start.
+func SlowQueries() ([]SlowQuery, int64) {
+	slowQueries.mu.Lock()
+	defer slowQueries.mu.Unlock()
+	queries := make([]SlowQuery, 0, len(slowQueries.queries))
+	for i := len(slowQueries.queries) - 1; i >= 0; i-- {
+		queries = append(queries, slowQueries.queries[i])
+	}
+	return queries, slowQueries.total
+}
+
+const slowQueryStartKey = "slow_queries:start"
+
+// setupSlowQueryLog times every statement with callbacks around all others. A logger wrapping
+// the default one would work too, but the default logger would then report the wrapper as
+// the caller of every query it logs.
+func setupSlowQueryLog(threshold time.Duration) {
+	start := func(db *gorm.DB) {
+		db.InstanceSet(slowQueryStartKey, time.Now())
+	}
+	record := func(db *gorm.DB) {
+		value, ok := db.InstanceGet(slowQueryStartKey)
+		if !ok {
+			return
+		}
+		begin := value.(time.Time)
+		elapsed := time.Since(begin)
+		if elapsed < threshold || db.Statement.SQL.Len() == 0 {
+			return
+		}
+		query := SlowQuery{
+			SQL:      db.Dialector.Explain(db.Statement.SQL.String(), db.Statement.Vars...),
+			Duration: elapsed,
+			Rows:     db.RowsAffected,
+			At:       begin,
+		}
+		if db.Error != nil {
+			query.Error = db.Error.Error()
+		}
+		slowQueries.add(query)
+	}
+	callbacks := DB.Callback()
+	callbacks.Create().Before("*").Register("slow_queries:start", start)
+	callbacks.Create().After("*").Register("slow_queries:record", record)
+	callbacks.Query().Before("*").Register("slow_queries:start", start)
+	callbacks.Query().After("*").Register("slow_queries:record", record)
+	callbacks.Update().Before("*").Register("slow_queries:start", start)
+	callbacks.Update().After("*").Register("slow_queries:record", record)
+	callbacks.Delete().Before("*").Register("slow_queries:start", start)
+	callbacks.Delete().After("*").Register("slow_queries:record", record)
+	callbacks.Row().Before("*").Register("slow_queries:start", start)
+	callbacks.Row().After("*").Register("slow_queries:record", record)
+	callbacks.Raw().Before("*").Register("slow_queries:start", start)
+	callbacks.Raw().After("*").Register("slow_queries:record", record)
+}
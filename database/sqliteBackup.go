@@ -0,0 +1,157 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// restoreBusyTimeout is how long a restore waits for other connections to finish writing.
+const restoreBusyTimeout = 30 * time.Second
+
+// sqliteHeader starts every SQLite database file.
+const sqliteHeader = "SQLite format 3\x00"
+
+// ErrNotSQLite is returned by the SQLite file operations on other databases, which have their own backup tools.
+var ErrNotSQLite = errors.New("backups are only supported on SQLite")
+
+// VacuumInto writes a consistent copy of the live database to path while it stays in use.
+func VacuumInto(path string) error {
+	if ActiveDialect.Name != "sqlite" {
+		return ErrNotSQLite
+	}
+	return DB.Exec("VACUUM INTO ?", path).Error
+}
+
+// CheckDatabaseFile opens the SQLite file at path read-only and makes sure it passes
+// PRAGMA integrity_check and was not migrated by a newer binary.
+func CheckDatabaseFile(path string) error {
+	// SQLite treats an empty or missing file as a new database, which passes every check
+	if !hasSQLiteHeader(path) {
+		return errors.New("not a SQLite database")
+	}
+	file, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	rows, err := file.Query("PRAGMA integrity_check")
+	if err != nil {
+		return fmt.Errorf("not a SQLite database: %w", err)
+	}
+	var problems []string
+	for rows.Next() {
+		var message string
+		if err := rows.Scan(&message); err != nil {
+			rows.Close()
+			return err
+		}
+		if message != "ok" {
+			problems = append(problems, message)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		if len(problems) > 5 {
+			problems = append(problems[:5], fmt.Sprintf("and %d more", len(problems)-5))
+		}
+		return fmt.Errorf("integrity check failed: %s", strings.Join(problems, "; "))
+	}
+
+	// Snapshots from before versioned migrations have no schema_migrations, they are migrated after the restore
+	var applied []schemaMigration
+	versions, err := file.Query("SELECT version, name FROM schema_migrations")
+	if err != nil {
+		return nil
+	}
+	defer versions.Close()
+	for versions.Next() {
+		var row schemaMigration
+		if err := versions.Scan(&row.Version, &row.Name); err != nil {
+			return err
+		}
+		applied = append(applied, row)
+	}
+	return checkNotAhead(applied)
+}
+
+func hasSQLiteHeader(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	header := make([]byte, len(sqliteHeader))
+	_, err = io.ReadFull(file, header)
+	return err == nil && string(header) == sqliteHeader
+}
+
+// RestoreFrom replaces the content of the live database with the SQLite file at path, using
+// SQLite's backup API so open connections see the restored data, and then migrates it.
+func RestoreFrom(path string) error {
+	if ActiveDialect.Name != "sqlite" {
+		return ErrNotSQLite
+	}
+	ctx := context.Background()
+	source, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	sourceConn, err := source.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer sourceConn.Close()
+	live, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	liveConn, err := live.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer liveConn.Close()
+
+	err = liveConn.Raw(func(destination any) error {
+		return sourceConn.Raw(func(src any) error {
+			backup, err := destination.(*sqlite3.SQLiteConn).Backup("main", src.(*sqlite3.SQLiteConn), "main")
+			if err != nil {
+				return err
+			}
+			deadline := time.Now().Add(restoreBusyTimeout)
+			for {
+				done, err := backup.Step(-1)
+				if done {
+					break
+				}
+				var sqliteErr sqlite3.Error
+				if errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked) && time.Now().Before(deadline) {
+					// Another connection is writing, the copy starts over once it is done
+					time.Sleep(100 * time.Millisecond)
+					continue
+				}
+				if err != nil {
+					backup.Finish()
+					return err
+				}
+			}
+			return backup.Finish()
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return MigrateUp(nil)
+}
@@ -15,126 +15,223 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/api_key": {
+        "/api/admin/backups": {
             "get": {
-                "description": "Get a list of all ApiKeys",
+                "description": "List the gzipped database snapshots, newest first",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "ApiKey"
+                    "Backup"
                 ],
-                "summary": "Get a list of ApiKeys",
+                "summary": "List database backups",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/models.ApiKey"
+                                "$ref": "#/definitions/dtos.BackupInfo"
                             }
                         }
                     }
                 }
             },
             "post": {
-                "description": "Create a new API key",
-                "consumes": [
+                "description": "Take a snapshot of the database now instead of waiting for the scheduled one. The oldest snapshots beyond the retention count are removed.",
+                "produces": [
                     "application/json"
                 ],
+                "tags": [
+                    "Backup"
+                ],
+                "summary": "Back up the database",
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.BackupInfo"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/backups/restore": {
+            "post": {
+                "description": "Replace the database with an uploaded snapshot, gzipped or not. The snapshot must pass PRAGMA integrity_check\nand must not come from a newer version of the API, and be at most BACKUP_MAX_RESTORE_MB once decompressed.\nThe current database is backed up first. Sync clients get reset on their next pull and pull everything again.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "ApiKey"
+                    "Backup"
                 ],
-                "summary": "Create a new API KEY",
+                "summary": "Restore the database",
                 "parameters": [
                     {
-                        "description": "ApiKey object",
-                        "name": "api_key",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/dtos.CreateApiKeyRequest"
-                        }
+                        "type": "file",
+                        "description": "Database snapshot",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.ApiKey"
+                            "$ref": "#/definitions/dtos.BackupRestoreResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     }
                 }
             }
         },
-        "/api/api_key/{id}": {
-            "put": {
-                "description": "Update an existing APi key by ID",
-                "consumes": [
-                    "application/json"
-                ],
+        "/api/admin/backups/{name}": {
+            "get": {
+                "description": "Download a gzipped database snapshot",
                 "produces": [
-                    "application/json"
+                    "application/gzip"
                 ],
                 "tags": [
-                    "ApiKey"
+                    "Backup"
                 ],
-                "summary": "Update an API key",
+                "summary": "Download a database backup",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "API Key ID",
-                        "name": "id",
+                        "type": "string",
+                        "description": "Backup name",
+                        "name": "name",
                         "in": "path",
                         "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
                     },
-                    {
-                        "description": "Updated user object",
-                        "name": "user",
-                        "in": "body",
-                        "required": true,
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/dtos.CreateApiKeyRequest"
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     }
+                }
+            }
+        },
+        "/api/admin/db": {
+            "get": {
+                "description": "Connection pool usage and the most recent slow queries. On SQLite also the page count, free pages,\nwrite-ahead log size and the journal mode, synchronous level and busy timeout in effect.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Database"
                 ],
+                "summary": "Get database stats",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.ApiKey"
+                            "$ref": "#/definitions/dtos.DatabaseStatsResponse"
                         }
                     }
                 }
             }
         },
-        "/api/blood_pressure": {
+        "/api/alert_rules": {
             "get": {
-                "description": "Get a list of all blood pressure recordings",
+                "description": "Get the alert rules managed by the current user",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "BloodPressure"
+                    "AlertRule"
+                ],
+                "summary": "Get alert rules",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Include records in the trash, admins only",
+                        "name": "includeDeleted",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Get a list of blood pressures",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/models.BloodPressure"
+                                "$ref": "#/definitions/models.AlertRule"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
                             }
                         }
                     }
                 }
             },
             "post": {
-                "description": "Create a new Blood Pressure report",
+                "description": "Create a rule that is evaluated on every new blood pressure reading (threshold, count) or on a schedule (missing).\nFirings are written to the log book and sent to the notifier: webhook (URL), smtp (e-mail address) or ntfy (topic URL).",
                 "consumes": [
                     "application/json"
                 ],
@@ -142,112 +239,140 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "BloodPressure"
+                    "AlertRule"
                 ],
-                "summary": "Create a new Blood Pressure report",
+                "summary": "Create an alert rule",
                 "parameters": [
                     {
-                        "description": "BloodPressure object",
-                        "name": "user",
+                        "description": "AlertRule object",
+                        "name": "rule",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/dtos.CreateBloodPressure"
+                            "$ref": "#/definitions/dtos.CreateAlertRuleRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/models.BloodPressure"
+                            "$ref": "#/definitions/models.AlertRule"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.ValidationErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/api/category": {
-            "get": {
-                "description": "Get a list of all market categories",
+        "/api/alert_rules/{id}": {
+            "put": {
+                "description": "Replace an alert rule",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Category"
+                    "AlertRule"
+                ],
+                "summary": "Update an alert rule",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "AlertRule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "AlertRule object",
+                        "name": "rule",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dtos.CreateAlertRuleRequest"
+                        }
+                    }
                 ],
-                "summary": "Get a list of market categories",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.Category"
-                            }
+                            "$ref": "#/definitions/models.AlertRule"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.ValidationErrorResponse"
                         }
                     }
                 }
             },
-            "post": {
-                "description": "Create a new market Category",
-                "consumes": [
-                    "application/json"
-                ],
+            "delete": {
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Category"
+                    "AlertRule"
                 ],
-                "summary": "Create a new market Category",
+                "summary": "Delete an alert rule",
                 "parameters": [
                     {
-                        "description": "MarketCategory object",
-                        "name": "user",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/dtos.CreateCategory"
-                        }
+                        "type": "integer",
+                        "description": "AlertRule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Category"
+                            "$ref": "#/definitions/models.AlertRule"
                         }
                     }
                 }
             }
         },
-        "/api/chat": {
+        "/api/alert_rules/{id}/test": {
             "post": {
-                "description": "Sends a message to OpenAI with MCP tools; no thread history.",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Send a test alert through the notifier of the rule, without touching the log book",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Chat"
+                    "AlertRule"
                 ],
-                "summary": "Chat with LLM using MCP tools (single turn)",
+                "summary": "Test an alert rule",
                 "parameters": [
                     {
-                        "description": "Chat message",
-                        "name": "body",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/dtos.ChatRequest"
-                        }
+                        "type": "integer",
+                        "description": "AlertRule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "reply",
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -258,28 +383,47 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/chat/threads": {
+        "/api/api_keys": {
             "get": {
+                "description": "Get a list of ApiKeys. Secrets are never returned; admins see the keys of all users.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Chat"
+                    "ApiKey"
+                ],
+                "summary": "Get a list of ApiKeys",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Include records in the trash, admins only",
+                        "name": "includeDeleted",
+                        "in": "query"
+                    }
                 ],
-                "summary": "List chat threads",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/dtos.ChatThreadResponse"
+                                "$ref": "#/definitions/dtos.ApiKeyResponse"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
                             }
                         }
                     }
                 }
             },
             "post": {
+                "description": "Generate a new API key. The full key is only included in this response, store it safely.",
                 "consumes": [
                     "application/json"
                 ],
@@ -287,17 +431,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Chat"
+                    "ApiKey"
                 ],
-                "summary": "Create a chat thread",
+                "summary": "Create a new API KEY",
                 "parameters": [
                     {
-                        "description": "Optional title",
-                        "name": "body",
+                        "description": "ApiKey object",
+                        "name": "api_key",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/dtos.CreateThreadRequest"
+                            "$ref": "#/definitions/dtos.CreateApiKeyRequest"
                         }
                     }
                 ],
@@ -305,25 +449,26 @@ const docTemplate = `{
                     "201": {
                         "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/dtos.ChatThreadResponse"
+                            "$ref": "#/definitions/dtos.IssuedApiKeyResponse"
                         }
                     }
                 }
             }
         },
-        "/api/chat/threads/{id}": {
+        "/api/api_keys/{id}": {
             "get": {
+                "description": "Get a single ApiKey by ID, without its secret",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Chat"
+                    "ApiKey"
                 ],
-                "summary": "Get a chat thread with messages",
+                "summary": "Get an ApiKey",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Thread ID",
+                        "description": "API Key ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -333,14 +478,13 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/dtos.ChatThreadResponse"
+                            "$ref": "#/definitions/dtos.ApiKeyResponse"
                         }
                     }
                 }
-            }
-        },
-        "/api/chat/threads/{id}/messages": {
-            "post": {
+            },
+            "put": {
+                "description": "Update the name, scopes or expiry of an existing API key by ID",
                 "consumes": [
                     "application/json"
                 ],
@@ -348,24 +492,24 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Chat"
+                    "ApiKey"
                 ],
-                "summary": "Send a message in a thread and get reply",
+                "summary": "Update an API key",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Thread ID",
+                        "description": "API Key ID",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Message",
-                        "name": "body",
+                        "description": "Updated api key object",
+                        "name": "api_key",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/dtos.AddMessageRequest"
+                            "$ref": "#/definitions/dtos.UpdateApiKeyRequest"
                         }
                     }
                 ],
@@ -373,84 +517,55 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/dtos.AddMessageResponse"
+                            "$ref": "#/definitions/dtos.ApiKeyResponse"
                         }
                     }
                 }
             }
         },
-        "/api/log_book": {
-            "get": {
-                "description": "Get a list of all LogBookEntries",
+        "/api/api_keys/{id}/revoke": {
+            "post": {
+                "description": "Revoke an API key. Revoked keys are kept for auditing but can no longer be used.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "LogBookEntry"
-                ],
-                "summary": "Get a list of LogBookEntries",
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.LogBookEntry"
-                            }
-                        }
-                    }
-                }
-            },
-            "post": {
-                "description": "Create a new LogBookEntry",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "LogBookEntry"
+                    "ApiKey"
                 ],
-                "summary": "Create a new LogBookEntry",
+                "summary": "Revoke an API key",
                 "parameters": [
                     {
-                        "description": "LogBookEntry object",
-                        "name": "log_book",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/dtos.CreateLogBookEntryRequest"
-                        }
+                        "type": "integer",
+                        "description": "API Key ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.LogBookEntry"
+                            "$ref": "#/definitions/dtos.ApiKeyResponse"
                         }
                     }
                 }
             }
         },
-        "/api/log_book/{id}": {
-            "delete": {
-                "description": "Delete a LogBookEntry by ID",
-                "consumes": [
-                    "application/json"
-                ],
+        "/api/api_keys/{id}/rotate": {
+            "post": {
+                "description": "Replace the secret of an API key. The old key stops working immediately and the new key is only included in this response.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "LogBookEntry"
+                    "ApiKey"
                 ],
-                "summary": "Delete a LogBookEntry",
+                "summary": "Rotate an API key",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "LogBookEntry ID",
+                        "description": "API Key ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -460,36 +575,59 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.LogBookEntry"
+                            "$ref": "#/definitions/dtos.IssuedApiKeyResponse"
                         }
                     }
                 }
             }
         },
-        "/api/marketItem": {
+        "/api/blood_pressure": {
             "get": {
-                "description": "Get a list of all market items",
+                "description": "Get a list of the blood pressure recordings of the current user, or of userId for caregivers",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "MarketItem"
+                    "BloodPressure"
+                ],
+                "summary": "Get a list of blood pressures",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User whose readings to list",
+                        "name": "userId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include records in the trash, admins only",
+                        "name": "includeDeleted",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Get a list of market items",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/models.MarketItem"
+                                "$ref": "#/definitions/models.BloodPressure"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
                             }
                         }
                     }
                 }
             },
             "post": {
-                "description": "Create a new market item",
+                "description": "Create a new Blood Pressure report. measuredAt defaults to now.",
                 "consumes": [
                     "application/json"
                 ],
@@ -497,141 +635,303 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "MarketItem"
+                    "BloodPressure"
                 ],
-                "summary": "Create a new market item",
+                "summary": "Create a new Blood Pressure report",
                 "parameters": [
                     {
-                        "description": "MarketItem object",
+                        "description": "BloodPressure object",
                         "name": "user",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/dtos.CreateMarketItemRequest"
+                            "$ref": "#/definitions/dtos.CreateBloodPressure"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.BloodPressure"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.ValidationErrorResponse"
                         }
                     }
+                }
+            }
+        },
+        "/api/blood_pressure/export": {
+            "get": {
+                "description": "Stream all readings in a date range as CSV (for spreadsheets) or JSON, oldest first.",
+                "produces": [
+                    "text/csv",
+                    "application/json"
+                ],
+                "tags": [
+                    "BloodPressure"
+                ],
+                "summary": "Export blood pressure history",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "csv (default) or json",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start of the range (RFC 3339 or YYYY-MM-DD)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End of the range (RFC 3339 or YYYY-MM-DD, inclusive)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "User whose readings to export",
+                        "name": "userId",
+                        "in": "query"
+                    }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.MarketItem"
+                            "type": "file"
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "Delete a market item",
+            }
+        },
+        "/api/blood_pressure/import": {
+            "post": {
+                "description": "Import readings from a CSV file exported by another app. Every row is validated like a new reading,\nrows with a timestamp that already has a reading are skipped as duplicates. The import runs in one transaction.",
                 "consumes": [
-                    "application/json"
+                    "multipart/form-data"
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "MarketItem"
+                    "BloodPressure"
                 ],
-                "summary": "Delete a market item",
+                "summary": "Import blood pressure history",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Market item ID",
-                        "name": "id",
-                        "in": "path",
+                        "type": "file",
+                        "description": "CSV file with a header row",
+                        "name": "file",
+                        "in": "formData",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "JSON object mapping systolic, diastolic, pulse, medicine and measuredAt to column headers",
+                        "name": "mapping",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Go time layout of the measuredAt column, e.g. 2006-01-02 15:04",
+                        "name": "timeFormat",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Column delimiter (default ,)",
+                        "name": "delimiter",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Import nothing when any row is invalid",
+                        "name": "strict",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "User to import the readings for (admins only)",
+                        "name": "userId",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.MarketItem"
+                            "$ref": "#/definitions/dtos.BloodPressureImportReport"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.BloodPressureImportReport"
                         }
                     }
                 }
             }
         },
-        "/api/modelUpdates": {
+        "/api/blood_pressure/report.pdf": {
             "get": {
-                "description": "Get a list of all market items",
+                "description": "A PDF for doctor visits with a summary table, morning/evening averages, a trend chart,\nmedication periods and all hypertensive crisis readings in the chosen period.",
                 "produces": [
-                    "application/json"
+                    "application/pdf"
                 ],
                 "tags": [
-                    "ModelUpdates"
+                    "BloodPressure"
+                ],
+                "summary": "Printable blood pressure report",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Start of the range (RFC 3339 or YYYY-MM-DD)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End of the range (RFC 3339 or YYYY-MM-DD, inclusive)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "User whose readings to report",
+                        "name": "userId",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Get Model updates",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.ModelUpdates"
-                            }
+                            "type": "file"
                         }
                     }
                 }
             }
         },
-        "/api/users": {
+        "/api/blood_pressure/stats": {
             "get": {
-                "description": "Get a list of all users",
+                "description": "Min/max/mean/median, weekly and monthly buckets, a moving average and the share of readings per hypertension category.\nUse medicine to only include readings taken on a medication, or groupBy=medicine to compare medications.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "User"
+                    "BloodPressure"
+                ],
+                "summary": "Get blood pressure statistics",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Start of the range (RFC 3339 or YYYY-MM-DD)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End of the range (RFC 3339 or YYYY-MM-DD, inclusive)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only include readings with this medicine",
+                        "name": "medicine",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Set to medicine to also get statistics per medicine",
+                        "name": "groupBy",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Categorisation guideline, aha (default) or esc",
+                        "name": "guideline",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of readings in the moving average (default 7)",
+                        "name": "window",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "User whose readings to analyse",
+                        "name": "userId",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Get a list of users",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.User"
+                            "$ref": "#/definitions/dtos.BloodPressureStatsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
                             }
                         }
                     }
                 }
-            },
-            "post": {
-                "description": "Create a new user with the given name",
-                "consumes": [
-                    "application/json"
-                ],
+            }
+        },
+        "/api/blood_pressure/{id}": {
+            "get": {
+                "description": "Get a single Blood Pressure report by ID",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "User"
+                    "BloodPressure"
                 ],
-                "summary": "Create a new user",
+                "summary": "Get a Blood Pressure report",
                 "parameters": [
                     {
-                        "description": "User object",
-                        "name": "user",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/dtos.CreateUserRequest"
-                        }
+                        "type": "integer",
+                        "description": "BloodPressure ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/dtos.UserResponse"
+                            "$ref": "#/definitions/models.BloodPressure"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     }
                 }
-            }
-        },
-        "/api/users/{id}": {
+            },
             "put": {
-                "description": "Update an existing user by ID",
+                "description": "Replace all fields of a Blood Pressure report. Only the owner may change a reading.",
                 "consumes": [
                     "application/json"
                 ],
@@ -639,24 +939,24 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "User"
+                    "BloodPressure"
                 ],
-                "summary": "Update a user",
+                "summary": "Replace a Blood Pressure report",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "User ID",
+                        "description": "BloodPressure ID",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Updated user object",
-                        "name": "user",
+                        "description": "BloodPressure object",
+                        "name": "bloodPressure",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/dtos.UpdateUserRequest"
+                            "$ref": "#/definitions/dtos.CreateBloodPressure"
                         }
                     }
                 ],
@@ -664,124 +964,4022 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/dtos.UserResponse"
+                            "$ref": "#/definitions/models.BloodPressure"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.ValidationErrorResponse"
                         }
                     }
                 }
+            },
+            "delete": {
+                "description": "Soft delete a Blood Pressure report. Only the owner may delete a reading.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "BloodPressure"
+                ],
+                "summary": "Delete a Blood Pressure report",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "BloodPressure ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.BloodPressure"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Change some fields of a Blood Pressure report. Only the owner may change a reading.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "BloodPressure"
+                ],
+                "summary": "Update a Blood Pressure report",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "BloodPressure ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Fields to change",
+                        "name": "bloodPressure",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dtos.PatchBloodPressure"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.BloodPressure"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.ValidationErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/caregivers": {
+            "get": {
+                "description": "Get the caregiver relationships the current user is part of, either as patient or as caregiver",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Caregiver"
+                ],
+                "summary": "Get caregiver relationships",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Include records in the trash, admins only",
+                        "name": "includeDeleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Caregiver"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Allow another user to read the health records and log book of the patient (the current user by default)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Caregiver"
+                ],
+                "summary": "Add a caregiver",
+                "parameters": [
+                    {
+                        "description": "Caregiver object",
+                        "name": "caregiver",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dtos.CreateCaregiverRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Caregiver"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/caregivers/{id}": {
+            "delete": {
+                "description": "Remove a caregiver relationship. Both the patient and the caregiver may end it.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Caregiver"
+                ],
+                "summary": "Remove a caregiver",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Caregiver ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Caregiver"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/categories": {
+            "get": {
+                "description": "Get all market categories, ordered by title. Use the tree for how they nest.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Category"
+                ],
+                "summary": "Get a list of market categories",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Include records in the trash, admins only",
+                        "name": "includeDeleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Category"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Create a new market category, below parentId or at the top without one. The slug is made from the\ntitle when it is left out.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Category"
+                ],
+                "summary": "Create a new market Category",
+                "parameters": [
+                    {
+                        "description": "MarketCategory object",
+                        "name": "category",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dtos.CreateCategory"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Category"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.ValidationErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/categories/tree": {
+            "get": {
+                "description": "Get the market categories nested below their parents, ordered by title, with the number of active\nmarket items in each category and in everything below it",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Category"
+                ],
+                "summary": "Get the category tree",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/dtos.CategoryTreeNode"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/categories/{id}": {
+            "get": {
+                "description": "Get a market category by ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Category"
+                ],
+                "summary": "Get a market category",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Category ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Category"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replace all fields of a market category. A null parentId moves it to the top, it can't move below itself.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Category"
+                ],
+                "summary": "Replace a market category",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Category ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "MarketCategory object",
+                        "name": "category",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dtos.UpdateCategory"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Category"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.ValidationErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Move a market category to the trash. A category with market items or categories below it is only\ndeleted when reassignTo says where they go: another category, or 0 to leave the items without a\ncategory and move the categories below it to the top.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Category"
+                ],
+                "summary": "Delete a market category",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Category ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Category to move the items and subcategories to, 0 for none",
+                        "name": "reassignTo",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Category"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.ValidationErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/changes": {
+            "get": {
+                "description": "Get creates, updates and deletes in the order they happened. For incremental sync pass the cursor of the\nprevious page as since until hasMore is false. Callers see changes of their own records, of the users\nthey are a caregiver of and of shared records, only of the models the API key has the read scope of;\nadmins see everything. Market items of other sellers are only in sync.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ChangeLog"
+                ],
+                "summary": "Get the change log",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Comma separated model names, e.g. BloodPressure,MarketItem",
+                        "name": "model",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Change id cursor (exclusive), RFC 3339 timestamp or YYYY-MM-DD date",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Changes per page, at most 1000",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.ChangeLogPage"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/chat": {
+            "post": {
+                "description": "Sends a message to OpenAI with MCP tools; no thread history.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Chat"
+                ],
+                "summary": "Chat with LLM using MCP tools (single turn)",
+                "parameters": [
+                    {
+                        "description": "Chat message",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dtos.ChatRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "reply",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/chat/threads": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Chat"
+                ],
+                "summary": "List chat threads",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Include records in the trash, admins only",
+                        "name": "includeDeleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/dtos.ChatThreadResponse"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Chat"
+                ],
+                "summary": "Create a chat thread",
+                "parameters": [
+                    {
+                        "description": "Optional title",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dtos.CreateThreadRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.ChatThreadResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/chat/threads/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Chat"
+                ],
+                "summary": "Get a chat thread with messages",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Thread ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.ChatThreadResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/chat/threads/{id}/messages": {
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Chat"
+                ],
+                "summary": "Send a message in a thread and get reply",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Thread ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Message",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dtos.AddMessageRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.AddMessageResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/log_book": {
+            "get": {
+                "description": "Get a page of the LogBookEntries of the current user, or of userId for caregivers, together with system entries. Newest first.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LogBookEntry"
+                ],
+                "summary": "Get a list of LogBookEntries",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User whose entries to list",
+                        "name": "userId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated levels (debug, info, warn, error)",
+                        "name": "level",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated categories",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Earliest timestamp, RFC 3339 or YYYY-MM-DD",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Latest timestamp, RFC 3339 or YYYY-MM-DD",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Words that must all appear in the message",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number, starting at 1",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Entries per page, at most 500",
+                        "name": "pageSize",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include records in the trash, admins only",
+                        "name": "includeDeleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.LogBookEntryPage"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Create a new LogBookEntry. level defaults to info and timestamp to now.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LogBookEntry"
+                ],
+                "summary": "Create a new LogBookEntry",
+                "parameters": [
+                    {
+                        "description": "LogBookEntry object",
+                        "name": "log_book",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dtos.CreateLogBookEntryRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.LogBookEntry"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.ValidationErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/log_book/stream": {
+            "get": {
+                "description": "Server-Sent Events stream of LogBookEntries as they are created, as \"log_book_entry\" events with the entry id as event id.\nA reconnecting client sends the Last-Event-ID header (or lastEventId query) and first gets the entries it missed.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "LogBookEntry"
+                ],
+                "summary": "Stream new LogBookEntries",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User whose entries to stream",
+                        "name": "userId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated levels (debug, info, warn, error)",
+                        "name": "level",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated categories",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Id of the last entry received",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Id of the last entry received, for clients that cannot set headers",
+                        "name": "lastEventId",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "text/event-stream",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/log_book/{id}": {
+            "get": {
+                "description": "Get a LogBookEntry by ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LogBookEntry"
+                ],
+                "summary": "Get a LogBookEntry",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "LogBookEntry ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.LogBookEntry"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Update the fields that are set in the request",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LogBookEntry"
+                ],
+                "summary": "Update a LogBookEntry",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "LogBookEntry ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Changed fields",
+                        "name": "log_book",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dtos.UpdateLogBookEntryRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.LogBookEntry"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.ValidationErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a LogBookEntry by ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LogBookEntry"
+                ],
+                "summary": "Delete a LogBookEntry",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "LogBookEntry ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.LogBookEntry"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/log_retention/policies": {
+            "get": {
+                "description": "Get the policies that decide how long LogBookEntries are kept. Entries no policy matches are kept forever.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LogRetention"
+                ],
+                "summary": "Get log retention policies",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Include records in the trash, admins only",
+                        "name": "includeDeleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.LogRetentionPolicy"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Keep entries of level and/or category (empty matches any) for maxAgeDays. The most specific matching policy wins:\nlevel and category, then category, then level, then the default policy with both empty.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LogRetention"
+                ],
+                "summary": "Create a log retention policy",
+                "parameters": [
+                    {
+                        "description": "Policy",
+                        "name": "policy",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dtos.LogRetentionPolicyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.LogRetentionPolicy"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.ValidationErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/log_retention/policies/{id}": {
+            "put": {
+                "description": "Replace a log retention policy",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LogRetention"
+                ],
+                "summary": "Update a log retention policy",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Policy ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Policy",
+                        "name": "policy",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dtos.LogRetentionPolicyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.LogRetentionPolicy"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.ValidationErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a log retention policy. Entries it covered fall back to a less specific policy.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LogRetention"
+                ],
+                "summary": "Delete a log retention policy",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Policy ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.LogRetentionPolicy"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/log_retention/purge": {
+            "post": {
+                "description": "Enforce the retention policies now instead of waiting for the next scheduled purge",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LogRetention"
+                ],
+                "summary": "Purge expired log entries",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.LogPurgeResult"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.LogPurgeResult"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/log_retention/status": {
+            "get": {
+                "description": "Get the row count (and on SQLite the size) of every table, the next scheduled purge and the result of the last one",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LogRetention"
+                ],
+                "summary": "Get log retention status",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.LogRetentionStatusResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/marketitem": {
+            "get": {
+                "description": "Search market items, with their category, seller and images. Pass the returned nextCursor as cursor to get the\nnext page, with the same filters and sort; total counts every matching item. Only active and reserved items\nare listed unless status says otherwise, and drafts only for their seller.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MarketItem"
+                ],
+                "summary": "Get a list of market items",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Words that must all appear in the title or description",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Lowest price",
+                        "name": "minPrice",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Highest price",
+                        "name": "maxPrice",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated category ids, each including the categories below it",
+                        "name": "categoryId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated user ids of sellers",
+                        "name": "sellerId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "active,reserved",
+                        "description": "Comma separated statuses: draft, active, reserved, sold, withdrawn",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "newest",
+                            "oldest",
+                            "price_asc",
+                            "price_desc",
+                            "title"
+                        ],
+                        "type": "string",
+                        "default": "newest",
+                        "description": "Order of the items",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "nextCursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Items per page, at most 500",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include records in the trash, admins only",
+                        "name": "includeDeleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MarketItemPage"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Create a new market item, sold by the current user. Only admins may list items for another user.\nThe item is listed right away, unless draft is set.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MarketItem"
+                ],
+                "summary": "Create a new market item",
+                "parameters": [
+                    {
+                        "description": "MarketItem object",
+                        "name": "marketItem",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dtos.CreateMarketItemRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MarketItemResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.ValidationErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/marketitem/stats": {
+            "get": {
+                "description": "How many items were sold and how long they were listed before, from publishing to selling",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MarketItem"
+                ],
+                "summary": "Get market sales statistics",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Comma separated user ids of sellers",
+                        "name": "sellerId",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MarketSalesStatsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/marketitem/{id}": {
+            "get": {
+                "description": "Get a market item by ID, with its category, seller and images. Drafts are only found for their seller.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MarketItem"
+                ],
+                "summary": "Get a market item",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Market item ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MarketItemResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replace all fields of a market item. Only the seller may change an item, and only admins may move it to another seller.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MarketItem"
+                ],
+                "summary": "Replace a market item",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Market item ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Market item object",
+                        "name": "marketItem",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dtos.UpdateMarketItemRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MarketItemResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.ValidationErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Move a market item to the trash. Only the seller may delete an item. Its images are kept until it is purged from the trash,\nwhich happens by itself after MARKET_TRASH_RETENTION (30 days by default).",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MarketItem"
+                ],
+                "summary": "Delete a market item",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Market item ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MarketItemResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Change some fields of a market item. Only the seller may change an item, and only admins may move it to another seller.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MarketItem"
+                ],
+                "summary": "Update a market item",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Market item ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Fields to change",
+                        "name": "marketItem",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dtos.PatchMarketItemRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MarketItemResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.ValidationErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/marketitem/{id}/images": {
+            "post": {
+                "description": "Add photos to a market item, after the ones it has. JPEG, PNG, GIF and WebP are accepted, recognized\nby their content. Only the seller may add images. Either all uploaded images are added or none.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MarketItem"
+                ],
+                "summary": "Upload market item images",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Market item ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "One or more images",
+                        "name": "images",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/dtos.MarketItemImageResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/marketitem/{id}/images/{imageId}": {
+            "get": {
+                "description": "Get an image as it was uploaded",
+                "produces": [
+                    "image/jpeg",
+                    "image/png",
+                    "image/gif",
+                    "image/webp"
+                ],
+                "tags": [
+                    "MarketItem"
+                ],
+                "summary": "Get a market item image",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Market item ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Image ID",
+                        "name": "imageId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Remove an image and its files. Only the seller may remove images.",
+                "tags": [
+                    "MarketItem"
+                ],
+                "summary": "Delete a market item image",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Market item ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Image ID",
+                        "name": "imageId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/marketitem/{id}/images/{imageId}/thumbnail": {
+            "get": {
+                "description": "Get a JPEG of the image scaled down to at most 320 pixels on the longest side",
+                "produces": [
+                    "image/jpeg"
+                ],
+                "tags": [
+                    "MarketItem"
+                ],
+                "summary": "Get a market item thumbnail",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Market item ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Image ID",
+                        "name": "imageId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/marketitem/{id}/price_history": {
+            "get": {
+                "description": "The price the item was listed with and every change since, oldest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MarketItem"
+                ],
+                "summary": "Get the price history of a market item",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Market item ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/dtos.MarketItemPriceChangeResponse"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/marketitem/{id}/publish": {
+            "post": {
+                "description": "List a draft or withdrawn item. Only the seller may publish an item.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MarketItem"
+                ],
+                "summary": "Publish a market item",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Market item ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MarketItemResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/marketitem/{id}/release": {
+            "post": {
+                "description": "End the reservation of an item, which is active again. The seller and the buyer it is reserved for may release it.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MarketItem"
+                ],
+                "summary": "Release a market item",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Market item ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MarketItemResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/marketitem/{id}/reserve": {
+            "post": {
+                "description": "Hold an active item for a buyer, until it is released, sold or the reservation expires. Users reserve items\nfor themselves, only the seller may reserve an item for someone else.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MarketItem"
+                ],
+                "summary": "Reserve a market item",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Market item ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Buyer to reserve the item for",
+                        "name": "reservation",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.ReserveMarketItemRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MarketItemResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.ValidationErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/marketitem/{id}/sell": {
+            "post": {
+                "description": "Mark an active or reserved item as sold. Only the seller may sell an item.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MarketItem"
+                ],
+                "summary": "Sell a market item",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Market item ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MarketItemResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/marketitem/{id}/withdraw": {
+            "post": {
+                "description": "Take an item that is not sold off the market, it can be published again later. Only the seller may withdraw an item.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MarketItem"
+                ],
+                "summary": "Withdraw a market item",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Market item ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.MarketItemResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/model_updates": {
+            "get": {
+                "description": "Get the time and method (create, update, delete) of the last change of every model.\nResponses carry an ETag; send it as If-None-Match to get 304 Not Modified while nothing changed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ModelUpdates"
+                ],
+                "summary": "Get Model updates",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ETag of a previous response",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/dtos.ModelUpdateResponse"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified"
+                    }
+                }
+            }
+        },
+        "/api/model_updates/{model}": {
+            "get": {
+                "description": "Get the time and method of the last change of one model, e.g. MarketItem. Supports If-None-Match like the list.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ModelUpdates"
+                ],
+                "summary": "Get the last update of a model",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Model name",
+                        "name": "model",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of a previous response",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.ModelUpdateResponse"
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/sync": {
+            "get": {
+                "description": "Get the current state of every record created, updated or deleted after the cursor. Start with cursor 0\nand pass the returned cursor on the next pull until hasMore is false. Deleted records come back with\noperation \"delete\" and no record. Models the API key cannot read are left out. Market items of every\nseller are pulled, drafts only by their seller. After a database restore the pull comes back with reset\nset: drop the synced records and pull again from cursor 0.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Sync"
+                ],
+                "summary": "Pull changes for offline sync",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Cursor of the previous pull",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated model names, e.g. BloodPressure,LogBookEntry",
+                        "name": "models",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 500,
+                        "description": "Changes per pull, at most 5000",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.SyncPullResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Apply a batch of client changes. Every change is applied on its own: creates always apply when valid,\nupdates and deletes only when the server record still has the updatedAt the client based the change on.\nOtherwise the result is a conflict carrying the server version. BloodPressure, MarketItem and\nLogBookEntry can be pushed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Sync"
+                ],
+                "summary": "Push offline changes",
+                "parameters": [
+                    {
+                        "description": "Client changes",
+                        "name": "changes",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dtos.SyncPushRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.SyncPushResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/trash": {
+            "get": {
+                "description": "Get the number of deleted records per model the caller can see in the trash",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Trash"
+                ],
+                "summary": "Get the trash",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/dtos.TrashSummary"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/trash/{model}": {
+            "get": {
+                "description": "Get a page of the deleted records of a model, most recently deleted first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Trash"
+                ],
+                "summary": "Get the deleted records of a model",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Model name, e.g. BloodPressure",
+                        "name": "model",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number, starting at 1",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Records per page, at most 500",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.TrashPage"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/trash/{model}/{id}": {
+            "delete": {
+                "description": "Permanently delete a record from the trash, with what belongs to it outside the database such as the images of a MarketItem.\nRecords that are not deleted have to be deleted first.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Trash"
+                ],
+                "summary": "Purge a deleted record",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Model name, e.g. BloodPressure",
+                        "name": "model",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Record ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/trash/{model}/{id}/restore": {
+            "post": {
+                "description": "Move a record out of the trash again",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Trash"
+                ],
+                "summary": "Restore a deleted record",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Model name, e.g. BloodPressure",
+                        "name": "model",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Record ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users": {
+            "get": {
+                "description": "Get a list of all users",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Get a list of users",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Include records in the trash, admins only",
+                        "name": "includeDeleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.User"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Create a new user with the given name",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Create a new user",
+                "parameters": [
+                    {
+                        "description": "User object",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dtos.CreateUserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.UserResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/{id}": {
+            "put": {
+                "description": "Update an existing user by ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Update a user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Updated user object",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dtos.UpdateUserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dtos.UserResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "dtos.AddMessageRequest": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "dtos.AddMessageResponse": {
+            "type": "object",
+            "properties": {
+                "reply": {
+                    "type": "string"
+                }
+            }
+        },
+        "dtos.ApiKeyResponse": {
+            "type": "object",
+            "properties": {
+                "api_type": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "lastUsedAt": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "prefix": {
+                    "type": "string"
+                },
+                "revokedAt": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dtos.BackupInfo": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Name identifies the backup for download",
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dtos.BackupRestoreResult": {
+            "type": "object",
+            "properties": {
+                "restoredAt": {
+                    "type": "string"
+                },
+                "safetyBackup": {
+                    "description": "SafetyBackup is the database as it was before the restore",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/dtos.BackupInfo"
+                        }
+                    ]
+                }
+            }
+        },
+        "dtos.BloodPressureBucket": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "diastolicMean": {
+                    "type": "number"
+                },
+                "period": {
+                    "type": "string"
+                },
+                "pulseMean": {
+                    "type": "number"
+                },
+                "start": {
+                    "type": "string"
+                },
+                "systolicMean": {
+                    "type": "number"
+                }
+            }
+        },
+        "dtos.BloodPressureImportReport": {
+            "type": "object",
+            "properties": {
+                "duplicates": {
+                    "type": "integer"
+                },
+                "imported": {
+                    "type": "integer"
+                },
+                "invalid": {
+                    "type": "integer"
+                },
+                "rows": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dtos.BloodPressureImportRow"
+                    }
+                }
+            }
+        },
+        "dtos.BloodPressureImportRow": {
+            "type": "object",
+            "properties": {
+                "errors": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "row": {
+                    "type": "integer"
+                },
+                "status": {
+                    "description": "\"imported\" | \"duplicate\" | \"invalid\", or \"valid\" when a strict import was rolled back",
+                    "type": "string"
+                }
+            }
+        },
+        "dtos.BloodPressureStats": {
+            "type": "object",
+            "properties": {
+                "medicine": {
+                    "type": "string"
+                },
+                "monthly": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dtos.BloodPressureBucket"
+                    }
+                },
+                "movingAverage": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dtos.MovingAveragePoint"
+                    }
+                },
+                "summary": {
+                    "$ref": "#/definitions/dtos.BloodPressureSummary"
+                },
+                "weekly": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dtos.BloodPressureBucket"
+                    }
+                }
+            }
+        },
+        "dtos.BloodPressureStatsResponse": {
+            "type": "object",
+            "properties": {
+                "byMedicine": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dtos.BloodPressureStats"
+                    }
+                },
+                "from": {
+                    "type": "string"
+                },
+                "guideline": {
+                    "type": "string"
+                },
+                "medicine": {
+                    "type": "string"
+                },
+                "monthly": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dtos.BloodPressureBucket"
+                    }
+                },
+                "movingAverage": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dtos.MovingAveragePoint"
+                    }
+                },
+                "summary": {
+                    "$ref": "#/definitions/dtos.BloodPressureSummary"
+                },
+                "to": {
+                    "type": "string"
+                },
+                "weekly": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dtos.BloodPressureBucket"
+                    }
+                },
+                "window": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dtos.BloodPressureSummary": {
+            "type": "object",
+            "properties": {
+                "categories": {
+                    "description": "Categories holds the share (0-1) of readings in each hypertension category",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "number",
+                        "format": "float64"
+                    }
+                },
+                "count": {
+                    "type": "integer"
+                },
+                "diastolic": {
+                    "$ref": "#/definitions/dtos.MeasurementStats"
+                },
+                "pulse": {
+                    "$ref": "#/definitions/dtos.MeasurementStats"
+                },
+                "systolic": {
+                    "$ref": "#/definitions/dtos.MeasurementStats"
+                }
+            }
+        },
+        "dtos.CategorySummary": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer"
+                },
+                "slug": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "dtos.CategoryTreeNode": {
+            "type": "object",
+            "properties": {
+                "children": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dtos.CategoryTreeNode"
+                    }
+                },
+                "color": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "deletedAt": {
+                    "description": "DeletedAt is set when the record was moved to the trash, deleted records are left out of queries unless Unscoped",
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "icon": {
+                    "description": "Icon is an emoji or icon name for clients to show, Color a hex colour like #a0522d",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "itemCount": {
+                    "description": "ItemCount counts the active market items in the category itself, TotalItemCount those\nin the categories below it too",
+                    "type": "integer"
+                },
+                "parentId": {
+                    "type": "integer"
+                },
+                "slug": {
+                    "description": "Slug names the category in URLs, it is unique among the categories that are not deleted",
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "totalItemCount": {
+                    "type": "integer"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "dtos.ChangeLogPage": {
+            "type": "object",
+            "properties": {
+                "changes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ChangeLogEntry"
+                    }
+                },
+                "cursor": {
+                    "description": "Cursor is the id of the last change returned; pass it as since to get the next page",
+                    "type": "integer"
+                },
+                "hasMore": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "dtos.ChatMessageResponse": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "role": {
+                    "type": "string"
+                }
+            }
+        },
+        "dtos.ChatRequest": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "dtos.ChatThreadResponse": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "messages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dtos.ChatMessageResponse"
+                    }
+                },
+                "title": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "dtos.CreateAlertRuleRequest": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "field": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "notifier": {
+                    "type": "string"
+                },
+                "operator": {
+                    "type": "string"
+                },
+                "target": {
+                    "type": "string"
+                },
+                "threshold": {
+                    "type": "number"
+                },
+                "type": {
+                    "type": "string"
+                },
+                "userId": {
+                    "type": "integer"
+                },
+                "windowHours": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dtos.CreateApiKeyRequest": {
+            "type": "object",
+            "properties": {
+                "api_type": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dtos.CreateBloodPressure": {
+            "type": "object",
+            "properties": {
+                "diastolic": {
+                    "type": "integer"
+                },
+                "measuredAt": {
+                    "type": "string"
+                },
+                "medicine": {
+                    "type": "string"
+                },
+                "pulse": {
+                    "type": "integer"
+                },
+                "systolic": {
+                    "type": "integer"
+                },
+                "userId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dtos.CreateCaregiverRequest": {
+            "type": "object",
+            "properties": {
+                "caregiverId": {
+                    "type": "integer"
+                },
+                "patientId": {
+                    "description": "PatientId defaults to the current user, only admins may set it to someone else",
+                    "type": "integer"
+                }
+            }
+        },
+        "dtos.CreateCategory": {
+            "type": "object",
+            "properties": {
+                "color": {
+                    "type": "string"
+                },
+                "icon": {
+                    "type": "string"
+                },
+                "parentId": {
+                    "type": "integer"
+                },
+                "slug": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "dtos.CreateLogBookEntryRequest": {
+            "type": "object",
+            "properties": {
+                "category": {
+                    "type": "string"
+                },
+                "level": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "description": "Timestamp defaults to now",
+                    "type": "string"
+                },
+                "userId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dtos.CreateMarketItemRequest": {
+            "type": "object",
+            "properties": {
+                "categoryId": {
+                    "type": "integer"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "draft": {
+                    "description": "Draft keeps the item unlisted until it is published, otherwise it is listed right away",
+                    "type": "boolean"
+                },
+                "price": {
+                    "type": "number"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "userId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dtos.CreateThreadRequest": {
+            "type": "object",
+            "properties": {
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "dtos.CreateUserRequest": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "phoneNumber": {
+                    "type": "string"
+                }
+            }
+        },
+        "dtos.DatabasePool": {
+            "type": "object",
+            "properties": {
+                "idle": {
+                    "type": "integer"
+                },
+                "inUse": {
+                    "type": "integer"
+                },
+                "maxOpen": {
+                    "description": "MaxOpen is 0 for no limit",
+                    "type": "integer"
+                },
+                "open": {
+                    "type": "integer"
+                },
+                "waitCount": {
+                    "description": "WaitCount and WaitMs are how often and how long requests waited for a free connection",
+                    "type": "integer"
+                },
+                "waitMs": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dtos.DatabaseStatsResponse": {
+            "type": "object",
+            "properties": {
+                "driver": {
+                    "type": "string"
+                },
+                "pool": {
+                    "$ref": "#/definitions/dtos.DatabasePool"
+                },
+                "slowQueries": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dtos.SlowQuery"
+                    }
+                },
+                "slowQueryCount": {
+                    "description": "SlowQueryCount counts the slow queries since the server started, SlowQueries only holds the most recent",
+                    "type": "integer"
+                },
+                "slowQueryThresholdMs": {
+                    "description": "SlowQueryThresholdMs is how long a query takes before it counts as slow",
+                    "type": "integer"
+                },
+                "storage": {
+                    "description": "Storage is only reported for SQLite",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/dtos.DatabaseStorage"
+                        }
+                    ]
+                }
+            }
+        },
+        "dtos.DatabaseStorage": {
+            "type": "object",
+            "properties": {
+                "busyTimeoutMs": {
+                    "type": "integer"
+                },
+                "databaseBytes": {
+                    "type": "integer"
+                },
+                "freelistCount": {
+                    "type": "integer"
+                },
+                "journalMode": {
+                    "type": "string"
+                },
+                "pageCount": {
+                    "type": "integer"
+                },
+                "pageSize": {
+                    "type": "integer"
+                },
+                "synchronous": {
+                    "type": "string"
+                },
+                "walBytes": {
+                    "description": "WalBytes is omitted when there is no write-ahead log",
+                    "type": "integer"
+                }
+            }
+        },
+        "dtos.IssuedApiKeyResponse": {
+            "type": "object",
+            "properties": {
+                "api_type": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "key": {
+                    "type": "string"
+                },
+                "lastUsedAt": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "prefix": {
+                    "type": "string"
+                },
+                "revokedAt": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dtos.LogBookEntryPage": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.LogBookEntry"
+                    }
+                },
+                "page": {
+                    "type": "integer"
+                },
+                "pageSize": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dtos.LogPurgeResult": {
+            "type": "object",
+            "properties": {
+                "archiveFile": {
+                    "type": "string"
+                },
+                "archived": {
+                    "type": "integer"
+                },
+                "changeLogCompacted": {
+                    "description": "ChangeLogCompacted counts the change log entries dropped because a newer one replaces them",
+                    "type": "integer"
+                },
+                "deleted": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "startedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "dtos.LogRetentionPolicyRequest": {
+            "type": "object",
+            "properties": {
+                "archive": {
+                    "type": "boolean"
+                },
+                "category": {
+                    "type": "string"
+                },
+                "level": {
+                    "type": "string"
+                },
+                "maxAgeDays": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dtos.LogRetentionStatusResponse": {
+            "type": "object",
+            "properties": {
+                "databaseBytes": {
+                    "type": "integer"
+                },
+                "lastPurge": {
+                    "$ref": "#/definitions/dtos.LogPurgeResult"
+                },
+                "nextPurgeAt": {
+                    "type": "string"
+                },
+                "tables": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dtos.TableSize"
+                    }
+                }
+            }
+        },
+        "dtos.MarketItemImageResponse": {
+            "type": "object",
+            "properties": {
+                "contentType": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "height": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "position": {
+                    "type": "integer"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "thumbnailUrl": {
+                    "description": "ThumbnailURL is a JPEG of at most 320 pixels on the longest side",
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                },
+                "width": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dtos.MarketItemPage": {
+            "type": "object",
+            "properties": {
+                "hasMore": {
+                    "type": "boolean"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dtos.MarketItemResponse"
+                    }
+                },
+                "nextCursor": {
+                    "description": "NextCursor is passed as cursor to get the next page, empty on the last page",
+                    "type": "string"
+                },
+                "total": {
+                    "description": "Total counts all items matching the filters, not only this page",
+                    "type": "integer"
+                }
+            }
+        },
+        "dtos.MarketItemPriceChangeResponse": {
+            "type": "object",
+            "properties": {
+                "changedAt": {
+                    "type": "string"
+                },
+                "oldPrice": {
+                    "description": "OldPrice is omitted for the price the item was listed with",
+                    "type": "number"
+                },
+                "price": {
+                    "type": "number"
+                }
+            }
+        },
+        "dtos.MarketItemResponse": {
+            "type": "object",
+            "properties": {
+                "category": {
+                    "description": "Category and Seller are omitted when they don't exist (any more)",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/dtos.CategorySummary"
+                        }
+                    ]
+                },
+                "categoryId": {
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "deletedAt": {
+                    "description": "DeletedAt is only set for items in the trash, listed with includeDeleted",
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "images": {
+                    "description": "Images are in display order, the first one is the cover photo",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dtos.MarketItemImageResponse"
+                    }
+                },
+                "price": {
+                    "type": "number"
+                },
+                "publishedAt": {
+                    "type": "string"
+                },
+                "reducedFrom": {
+                    "description": "ReducedFrom is the price before the last change, when that change lowered the price",
+                    "type": "number"
+                },
+                "reservedById": {
+                    "description": "ReservedById and ReservedUntil are only set while the item is reserved",
+                    "type": "integer"
+                },
+                "reservedUntil": {
+                    "type": "string"
+                },
+                "seller": {
+                    "$ref": "#/definitions/dtos.SellerSummary"
+                },
+                "soldAt": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "draft",
+                        "active",
+                        "reserved",
+                        "sold",
+                        "withdrawn"
+                    ]
+                },
+                "title": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "userId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dtos.MarketSalesStatsResponse": {
+            "type": "object",
+            "properties": {
+                "averageHoursToSell": {
+                    "description": "Time from publishing to selling, zero when nothing was sold",
+                    "type": "number"
+                },
+                "medianHoursToSell": {
+                    "type": "number"
+                },
+                "sold": {
+                    "type": "integer"
+                },
+                "soldAfterReduction": {
+                    "description": "SoldAfterReduction counts the sold items whose price was lowered while listed",
+                    "type": "integer"
+                }
+            }
+        },
+        "dtos.MeasurementStats": {
+            "type": "object",
+            "properties": {
+                "max": {
+                    "type": "integer"
+                },
+                "mean": {
+                    "type": "number"
+                },
+                "median": {
+                    "type": "number"
+                },
+                "min": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dtos.ModelUpdateResponse": {
+            "type": "object",
+            "properties": {
+                "method": {
+                    "description": "Method of the last change: create, update or delete",
+                    "type": "string"
+                },
+                "model": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "dtos.MovingAveragePoint": {
+            "type": "object",
+            "properties": {
+                "diastolic": {
+                    "type": "number"
+                },
+                "pulse": {
+                    "type": "number"
+                },
+                "systolic": {
+                    "type": "number"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "dtos.PatchBloodPressure": {
+            "type": "object",
+            "properties": {
+                "diastolic": {
+                    "type": "integer"
+                },
+                "measuredAt": {
+                    "type": "string"
+                },
+                "medicine": {
+                    "type": "string"
+                },
+                "pulse": {
+                    "type": "integer"
+                },
+                "systolic": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dtos.PatchMarketItemRequest": {
+            "type": "object",
+            "properties": {
+                "categoryId": {
+                    "type": "integer"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "number"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "userId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dtos.ReserveMarketItemRequest": {
+            "type": "object",
+            "properties": {
+                "userId": {
+                    "type": "integer"
+                }
             }
-        }
-    },
-    "definitions": {
-        "dtos.AddMessageRequest": {
+        },
+        "dtos.SellerSummary": {
             "type": "object",
             "properties": {
-                "message": {
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
                     "type": "string"
                 }
             }
         },
-        "dtos.AddMessageResponse": {
+        "dtos.SlowQuery": {
             "type": "object",
             "properties": {
-                "reply": {
+                "at": {
+                    "type": "string"
+                },
+                "durationMs": {
+                    "type": "number"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "rows": {
+                    "type": "integer"
+                },
+                "sql": {
                     "type": "string"
                 }
             }
         },
-        "dtos.ChatMessageResponse": {
+        "dtos.SyncChange": {
             "type": "object",
             "properties": {
-                "content": {
+                "id": {
                     "type": "string"
                 },
-                "createdAt": {
+                "model": {
                     "type": "string"
                 },
-                "id": {
-                    "type": "integer"
+                "operation": {
+                    "description": "Operation is \"upsert\" for created or updated records and \"delete\" for deleted ones",
+                    "type": "string"
                 },
-                "role": {
+                "record": {
+                    "description": "Record is the record as the REST API returns it, omitted for deletes",
+                    "type": "object"
+                },
+                "updatedAt": {
                     "type": "string"
                 }
             }
         },
-        "dtos.ChatRequest": {
+        "dtos.SyncPullResponse": {
             "type": "object",
             "properties": {
-                "message": {
-                    "type": "string"
+                "changes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dtos.SyncChange"
+                    }
+                },
+                "cursor": {
+                    "description": "Cursor is passed back on the next pull",
+                    "type": "integer"
+                },
+                "hasMore": {
+                    "type": "boolean"
+                },
+                "reset": {
+                    "description": "Reset means the database was restored from a backup since the cursor. The client drops what\nit synced and pulls again from cursor 0.",
+                    "type": "boolean"
                 }
             }
         },
-        "dtos.ChatThreadResponse": {
+        "dtos.SyncPushChange": {
             "type": "object",
             "properties": {
-                "createdAt": {
+                "baseUpdatedAt": {
+                    "description": "BaseUpdatedAt is the updatedAt of the server record the client changed. Required for update and delete.",
+                    "type": "string"
+                },
+                "clientId": {
+                    "description": "ClientId is the client's own id for the record, echoed back in the result",
                     "type": "string"
                 },
                 "id": {
+                    "description": "Id of the server record, 0 for create",
                     "type": "integer"
                 },
-                "messages": {
+                "model": {
+                    "type": "string"
+                },
+                "operation": {
+                    "description": "\"create\" | \"update\" | \"delete\"",
+                    "type": "string"
+                },
+                "record": {
+                    "type": "object"
+                }
+            }
+        },
+        "dtos.SyncPushRequest": {
+            "type": "object",
+            "properties": {
+                "changes": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/dtos.ChatMessageResponse"
+                        "$ref": "#/definitions/dtos.SyncPushChange"
+                    }
+                }
+            }
+        },
+        "dtos.SyncPushResponse": {
+            "type": "object",
+            "properties": {
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dtos.SyncPushResult"
                     }
+                }
+            }
+        },
+        "dtos.SyncPushResult": {
+            "type": "object",
+            "properties": {
+                "clientId": {
+                    "type": "string"
                 },
-                "title": {
+                "error": {
                     "type": "string"
                 },
-                "updatedAt": {
+                "fields": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "model": {
+                    "type": "string"
+                },
+                "record": {
+                    "description": "Record is the server version after applying, or the conflicting server version",
+                    "type": "object"
+                },
+                "status": {
+                    "description": "Status is \"applied\", \"conflict\" (the server record changed since BaseUpdatedAt) or \"rejected\"",
                     "type": "string"
                 }
             }
         },
-        "dtos.CreateApiKeyRequest": {
+        "dtos.TableSize": {
             "type": "object",
             "properties": {
-                "api_key": {
-                    "type": "string"
+                "bytes": {
+                    "description": "Bytes is only reported when the database can tell, otherwise omitted",
+                    "type": "integer"
                 },
-                "api_type": {
+                "name": {
                     "type": "string"
                 },
-                "client_id": {
-                    "type": "string"
+                "rows": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dtos.TrashPage": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "type": "object"
+                    }
                 },
-                "client_secret": {
+                "model": {
                     "type": "string"
                 },
-                "user_id": {
+                "page": {
+                    "type": "integer"
+                },
+                "pageSize": {
+                    "type": "integer"
+                },
+                "total": {
                     "type": "integer"
                 }
             }
         },
-        "dtos.CreateBloodPressure": {
+        "dtos.TrashSummary": {
             "type": "object",
             "properties": {
-                "diastolic": {
+                "count": {
                     "type": "integer"
                 },
-                "medicine": {
+                "model": {
+                    "type": "string"
+                }
+            }
+        },
+        "dtos.UpdateApiKeyRequest": {
+            "type": "object",
+            "properties": {
+                "expiresAt": {
                     "type": "string"
                 },
-                "pulse": {
-                    "type": "integer"
+                "name": {
+                    "type": "string"
                 },
-                "systolic": {
-                    "type": "integer"
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 }
             }
         },
-        "dtos.CreateCategory": {
+        "dtos.UpdateCategory": {
             "type": "object",
             "properties": {
+                "color": {
+                    "type": "string"
+                },
+                "icon": {
+                    "type": "string"
+                },
+                "parentId": {
+                    "type": "integer"
+                },
+                "slug": {
+                    "type": "string"
+                },
                 "title": {
                     "type": "string"
                 }
             }
         },
-        "dtos.CreateLogBookEntryRequest": {
+        "dtos.UpdateLogBookEntryRequest": {
             "type": "object",
             "properties": {
                 "category": {
@@ -792,10 +4990,13 @@ const docTemplate = `{
                 },
                 "message": {
                     "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
                 }
             }
         },
-        "dtos.CreateMarketItemRequest": {
+        "dtos.UpdateMarketItemRequest": {
             "type": "object",
             "properties": {
                 "categoryId": {
@@ -815,15 +5016,7 @@ const docTemplate = `{
                 }
             }
         },
-        "dtos.CreateThreadRequest": {
-            "type": "object",
-            "properties": {
-                "title": {
-                    "type": "string"
-                }
-            }
-        },
-        "dtos.CreateUserRequest": {
+        "dtos.UpdateUserRequest": {
             "type": "object",
             "properties": {
                 "email": {
@@ -837,9 +5030,12 @@ const docTemplate = `{
                 }
             }
         },
-        "dtos.UpdateUserRequest": {
+        "dtos.UserResponse": {
             "type": "object",
             "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
                 "email": {
                     "type": "string"
                 },
@@ -851,54 +5047,77 @@ const docTemplate = `{
                 }
             }
         },
-        "dtos.UserResponse": {
+        "dtos.ValidationErrorResponse": {
             "type": "object",
             "properties": {
-                "createdAt": {
-                    "type": "string"
-                },
-                "email": {
+                "error": {
                     "type": "string"
                 },
-                "name": {
-                    "type": "string"
-                },
-                "phoneNumber": {
-                    "type": "string"
+                "fields": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
                 }
             }
         },
-        "models.ApiKey": {
+        "models.AlertRule": {
             "type": "object",
             "properties": {
-                "api_key": {
+                "count": {
+                    "type": "integer"
+                },
+                "createdAt": {
                     "type": "string"
                 },
-                "api_type": {
+                "createdById": {
+                    "type": "integer"
+                },
+                "deletedAt": {
+                    "description": "DeletedAt is set when the record was moved to the trash, deleted records are left out of queries unless Unscoped",
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "field": {
                     "type": "string"
                 },
-                "client_id": {
+                "id": {
+                    "type": "integer"
+                },
+                "lastFiredAt": {
                     "type": "string"
                 },
-                "client_secret": {
+                "name": {
                     "type": "string"
                 },
-                "createdAt": {
+                "notifier": {
+                    "description": "\"\" (log book only) | \"webhook\" | \"smtp\" | \"ntfy\"",
                     "type": "string"
                 },
-                "deletedAt": {
+                "operator": {
                     "type": "string"
                 },
-                "id": {
-                    "type": "integer"
+                "target": {
+                    "description": "webhook URL, e-mail address or ntfy topic URL",
+                    "type": "string"
+                },
+                "threshold": {
+                    "type": "number"
+                },
+                "type": {
+                    "type": "string"
                 },
                 "updatedAt": {
                     "type": "string"
                 },
-                "user": {
-                    "$ref": "#/definitions/models.User"
+                "userId": {
+                    "description": "UserId is the user whose readings are watched, CreatedById the user that manages the rule",
+                    "type": "integer"
                 },
-                "user_id": {
+                "windowHours": {
                     "type": "integer"
                 }
             }
@@ -910,7 +5129,9 @@ const docTemplate = `{
                     "type": "string"
                 },
                 "deletedAt": {
-                    "type": "string"
+                    "description": "DeletedAt is set when the record was moved to the trash, deleted records are left out of queries unless Unscoped",
+                    "type": "string",
+                    "format": "date-time"
                 },
                 "diastolic": {
                     "type": "integer"
@@ -918,6 +5139,9 @@ const docTemplate = `{
                 "id": {
                     "type": "integer"
                 },
+                "measuredAt": {
+                    "type": "string"
+                },
                 "medicine": {
                     "type": "string"
                 },
@@ -929,51 +5153,72 @@ const docTemplate = `{
                 },
                 "updatedAt": {
                     "type": "string"
+                },
+                "userId": {
+                    "type": "integer"
                 }
             }
         },
-        "models.Category": {
+        "models.Caregiver": {
             "type": "object",
             "properties": {
+                "caregiver": {
+                    "$ref": "#/definitions/models.User"
+                },
+                "caregiverId": {
+                    "type": "integer"
+                },
                 "createdAt": {
                     "type": "string"
                 },
                 "deletedAt": {
-                    "type": "string"
+                    "description": "DeletedAt is set when the record was moved to the trash, deleted records are left out of queries unless Unscoped",
+                    "type": "string",
+                    "format": "date-time"
                 },
                 "id": {
                     "type": "integer"
                 },
-                "title": {
-                    "type": "string"
+                "patient": {
+                    "$ref": "#/definitions/models.User"
+                },
+                "patientId": {
+                    "type": "integer"
                 },
                 "updatedAt": {
                     "type": "string"
                 }
             }
         },
-        "models.LogBookEntry": {
+        "models.Category": {
             "type": "object",
             "properties": {
-                "category": {
+                "color": {
                     "type": "string"
                 },
                 "createdAt": {
                     "type": "string"
                 },
                 "deletedAt": {
+                    "description": "DeletedAt is set when the record was moved to the trash, deleted records are left out of queries unless Unscoped",
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "icon": {
+                    "description": "Icon is an emoji or icon name for clients to show, Color a hex colour like #a0522d",
                     "type": "string"
                 },
                 "id": {
                     "type": "integer"
                 },
-                "level": {
-                    "type": "string"
+                "parentId": {
+                    "type": "integer"
                 },
-                "message": {
+                "slug": {
+                    "description": "Slug names the category in URLs, it is unique among the categories that are not deleted",
                     "type": "string"
                 },
-                "timestamp": {
+                "title": {
                     "type": "string"
                 },
                 "updatedAt": {
@@ -981,62 +5226,102 @@ const docTemplate = `{
                 }
             }
         },
-        "models.MarketItem": {
+        "models.ChangeLogEntry": {
             "type": "object",
             "properties": {
-                "category": {
-                    "$ref": "#/definitions/models.Category"
+                "changes": {
+                    "description": "Changes maps each changed field to {\"old\": ..., \"new\": ...}",
+                    "type": "object"
                 },
-                "categoryId": {
+                "id": {
                     "type": "integer"
                 },
-                "createdAt": {
+                "model": {
                     "type": "string"
                 },
-                "deletedAt": {
+                "operation": {
+                    "description": "\"create\" | \"update\" | \"delete\", or \"reset\" with model \"*\" after a restore",
                     "type": "string"
                 },
-                "description": {
+                "ownerId": {
+                    "description": "OwnerId is the user the changed record belongs to, nil for shared records",
+                    "type": "integer"
+                },
+                "recordId": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "userId": {
+                    "description": "UserId is the acting user, nil for changes made by the system",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.LogBookEntry": {
+            "type": "object",
+            "properties": {
+                "category": {
+                    "type": "string"
+                },
+                "createdAt": {
                     "type": "string"
                 },
+                "deletedAt": {
+                    "description": "DeletedAt is set when the record was moved to the trash, deleted records are left out of queries unless Unscoped",
+                    "type": "string",
+                    "format": "date-time"
+                },
                 "id": {
                     "type": "integer"
                 },
-                "price": {
-                    "type": "number"
+                "level": {
+                    "description": "Level is one of debug, info, warn or error",
+                    "type": "string"
                 },
-                "title": {
+                "message": {
                     "type": "string"
                 },
-                "updatedAt": {
+                "timestamp": {
                     "type": "string"
                 },
-                "user": {
-                    "$ref": "#/definitions/models.User"
+                "updatedAt": {
+                    "type": "string"
                 },
                 "userId": {
+                    "description": "UserId is 0 for system entries, which are visible to every user",
                     "type": "integer"
                 }
             }
         },
-        "models.ModelUpdates": {
+        "models.LogRetentionPolicy": {
             "type": "object",
             "properties": {
-                "action": {
+                "archive": {
+                    "description": "Archive writes expired entries to a gzipped NDJSON file before deleting them",
+                    "type": "boolean"
+                },
+                "category": {
                     "type": "string"
                 },
                 "createdAt": {
                     "type": "string"
                 },
                 "deletedAt": {
-                    "type": "string"
+                    "description": "DeletedAt is set when the record was moved to the trash, deleted records are left out of queries unless Unscoped",
+                    "type": "string",
+                    "format": "date-time"
                 },
                 "id": {
                     "type": "integer"
                 },
-                "model_name": {
+                "level": {
                     "type": "string"
                 },
+                "maxAgeDays": {
+                    "type": "integer"
+                },
                 "updatedAt": {
                     "type": "string"
                 }
@@ -1049,7 +5334,9 @@ const docTemplate = `{
                     "type": "string"
                 },
                 "deletedAt": {
-                    "type": "string"
+                    "description": "DeletedAt is set when the record was moved to the trash, deleted records are left out of queries unless Unscoped",
+                    "type": "string",
+                    "format": "date-time"
                 },
                 "email": {
                     "type": "string"
@@ -0,0 +1,15 @@
+package dtos
+
+type CreateAlertRuleRequest struct {
+	Name        string  `json:"name"`
+	Type        string  `json:"type"`
+	Field       string  `json:"field"`
+	Operator    string  `json:"operator"`
+	Threshold   float64 `json:"threshold"`
+	Count       int     `json:"count"`
+	WindowHours int     `json:"windowHours"`
+	Notifier    string  `json:"notifier"`
+	Target      string  `json:"target"`
+	Enabled     *bool   `json:"enabled"`
+	UserId      uint    `json:"userId"`
+}
@@ -1,9 +1,36 @@
 package dtos
 
+import "time"
+
 type CreateApiKeyRequest struct {
-	ClientSecret string `json:"client_secret"`
-	ClientId     string `json:"client_id"`
-	ApiKey       string `json:"api_key"`
-	Type         string `json:"api_type"`
-	UserId       uint   `json:"user_id"`
-}
\ No newline at end of file
+	Name      string     `json:"name"`
+	Type      string     `json:"api_type"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+	UserId    uint       `json:"user_id"`
+}
+
+type UpdateApiKeyRequest struct {
+	Name      *string    `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+type ApiKeyResponse struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Type       string     `json:"api_type"`
+	Scopes     []string   `json:"scopes"`
+	UserId     uint       `json:"user_id"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+// IssuedApiKeyResponse is returned on creation and rotation. Key is only ever shown here.
+type IssuedApiKeyResponse struct {
+	ApiKeyResponse
+	Key string `json:"key"`
+}
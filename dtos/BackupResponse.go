@@ -0,0 +1,16 @@
+package dtos
+
+import "time"
+
+type BackupInfo struct {
+	// Name identifies the backup for download
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type BackupRestoreResult struct {
+	RestoredAt time.Time `json:"restoredAt"`
+	// SafetyBackup is the database as it was before the restore
+	SafetyBackup BackupInfo `json:"safetyBackup"`
+}
@@ -0,0 +1,15 @@
+package dtos
+
+type BloodPressureImportRow struct {
+	Row    int               `json:"row"`
+	Status string            `json:"status"` // "imported" | "duplicate" | "invalid", or "valid" when a strict import was rolled back
+	ID     int               `json:"id,omitempty"`
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+type BloodPressureImportReport struct {
+	Imported   int                      `json:"imported"`
+	Duplicates int                      `json:"duplicates"`
+	Invalid    int                      `json:"invalid"`
+	Rows       []BloodPressureImportRow `json:"rows"`
+}
@@ -1,8 +1,26 @@
 package dtos
 
+import "time"
+
 type CreateBloodPressure struct {
-	Systolic  int    `json:"systolic"`
-	Diastolic int    `json:"diastolic"`
-	Pulse     int    `json:"pulse"`
-	Medicine  string `json:"medicine"`
-}
\ No newline at end of file
+	Systolic   int        `json:"systolic"`
+	Diastolic  int        `json:"diastolic"`
+	Pulse      int        `json:"pulse"`
+	Medicine   string     `json:"medicine"`
+	MeasuredAt *time.Time `json:"measuredAt"`
+	UserId     uint       `json:"userId"`
+}
+
+// PatchBloodPressure only changes the fields that are set.
+type PatchBloodPressure struct {
+	Systolic   *int       `json:"systolic"`
+	Diastolic  *int       `json:"diastolic"`
+	Pulse      *int       `json:"pulse"`
+	Medicine   *string    `json:"medicine"`
+	MeasuredAt *time.Time `json:"measuredAt"`
+}
+
+type ValidationErrorResponse struct {
+	Error  string            `json:"error"`
+	Fields map[string]string `json:"fields"`
+}
@@ -0,0 +1,52 @@
+package dtos
+
+import "time"
+
+type MeasurementStats struct {
+	Min    int     `json:"min"`
+	Max    int     `json:"max"`
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+}
+
+type BloodPressureSummary struct {
+	Count     int              `json:"count"`
+	Systolic  MeasurementStats `json:"systolic"`
+	Diastolic MeasurementStats `json:"diastolic"`
+	Pulse     MeasurementStats `json:"pulse"`
+	// Categories holds the share (0-1) of readings in each hypertension category
+	Categories map[string]float64 `json:"categories"`
+}
+
+type BloodPressureBucket struct {
+	Period        string    `json:"period"`
+	Start         time.Time `json:"start"`
+	Count         int       `json:"count"`
+	SystolicMean  float64   `json:"systolicMean"`
+	DiastolicMean float64   `json:"diastolicMean"`
+	PulseMean     float64   `json:"pulseMean"`
+}
+
+type MovingAveragePoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Systolic  float64   `json:"systolic"`
+	Diastolic float64   `json:"diastolic"`
+	Pulse     float64   `json:"pulse"`
+}
+
+type BloodPressureStats struct {
+	Medicine      *string               `json:"medicine,omitempty"`
+	Summary       BloodPressureSummary  `json:"summary"`
+	Weekly        []BloodPressureBucket `json:"weekly"`
+	Monthly       []BloodPressureBucket `json:"monthly"`
+	MovingAverage []MovingAveragePoint  `json:"movingAverage"`
+}
+
+type BloodPressureStatsResponse struct {
+	BloodPressureStats
+	From       *time.Time           `json:"from,omitempty"`
+	To         *time.Time           `json:"to,omitempty"`
+	Guideline  string               `json:"guideline"`
+	Window     int                  `json:"window"`
+	ByMedicine []BloodPressureStats `json:"byMedicine,omitempty"`
+}
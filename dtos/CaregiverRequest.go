@@ -0,0 +1,7 @@
+package dtos
+
+type CreateCaregiverRequest struct {
+	CaregiverId uint `json:"caregiverId"`
+	// PatientId defaults to the current user, only admins may set it to someone else
+	PatientId uint `json:"patientId"`
+}
@@ -0,0 +1,12 @@
+package dtos
+
+import "api/models"
+
+type CategoryTreeNode struct {
+	models.Category
+	// ItemCount counts the active market items in the category itself, TotalItemCount those
+	// in the categories below it too
+	ItemCount      int64              `json:"itemCount"`
+	TotalItemCount int64              `json:"totalItemCount"`
+	Children       []CategoryTreeNode `json:"children"`
+}
@@ -0,0 +1,10 @@
+package dtos
+
+import "api/models"
+
+type ChangeLogPage struct {
+	Changes []models.ChangeLogEntry `json:"changes"`
+	// Cursor is the id of the last change returned; pass it as since to get the next page
+	Cursor  uint64 `json:"cursor"`
+	HasMore bool   `json:"hasMore"`
+}
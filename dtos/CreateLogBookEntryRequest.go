@@ -1,7 +1,30 @@
 package dtos
 
+import (
+	"api/models"
+	"time"
+)
+
 type CreateLogBookEntryRequest struct {
 	Message  string `json:"message"`
 	Level    string `json:"level"`
 	Category string `json:"category"`
+	// Timestamp defaults to now
+	Timestamp *time.Time `json:"timestamp"`
+	UserId    uint       `json:"userId"`
+}
+
+// UpdateLogBookEntryRequest only changes the fields that are set.
+type UpdateLogBookEntryRequest struct {
+	Message   *string    `json:"message"`
+	Level     *string    `json:"level"`
+	Category  *string    `json:"category"`
+	Timestamp *time.Time `json:"timestamp"`
+}
+
+type LogBookEntryPage struct {
+	Items    []models.LogBookEntry `json:"items"`
+	Total    int64                 `json:"total"`
+	Page     int                   `json:"page"`
+	PageSize int                   `json:"pageSize"`
 }
@@ -0,0 +1,46 @@
+package dtos
+
+import "time"
+
+type DatabaseStatsResponse struct {
+	Driver string `json:"driver"`
+	// Storage is only reported for SQLite
+	Storage *DatabaseStorage `json:"storage,omitempty"`
+	Pool    DatabasePool     `json:"pool"`
+	// SlowQueryThresholdMs is how long a query takes before it counts as slow
+	SlowQueryThresholdMs int64 `json:"slowQueryThresholdMs"`
+	// SlowQueryCount counts the slow queries since the server started, SlowQueries only holds the most recent
+	SlowQueryCount int64       `json:"slowQueryCount"`
+	SlowQueries    []SlowQuery `json:"slowQueries"`
+}
+
+type DatabaseStorage struct {
+	PageCount     int64 `json:"pageCount"`
+	PageSize      int64 `json:"pageSize"`
+	FreelistCount int64 `json:"freelistCount"`
+	DatabaseBytes int64 `json:"databaseBytes"`
+	// WalBytes is omitted when there is no write-ahead log
+	WalBytes      *int64 `json:"walBytes,omitempty"`
+	JournalMode   string `json:"journalMode"`
+	Synchronous   string `json:"synchronous"`
+	BusyTimeoutMs int64  `json:"busyTimeoutMs"`
+}
+
+type DatabasePool struct {
+	// MaxOpen is 0 for no limit
+	MaxOpen int `json:"maxOpen"`
+	Open    int `json:"open"`
+	InUse   int `json:"inUse"`
+	Idle    int `json:"idle"`
+	// WaitCount and WaitMs are how often and how long requests waited for a free connection
+	WaitCount int64 `json:"waitCount"`
+	WaitMs    int64 `json:"waitMs"`
+}
+
+type SlowQuery struct {
+	SQL        string    `json:"sql"`
+	DurationMs float64   `json:"durationMs"`
+	Rows       int64     `json:"rows"`
+	Error      string    `json:"error,omitempty"`
+	At         time.Time `json:"at"`
+}
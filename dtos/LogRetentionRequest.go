@@ -0,0 +1,34 @@
+package dtos
+
+import "time"
+
+type LogRetentionPolicyRequest struct {
+	Level      string `json:"level"`
+	Category   string `json:"category"`
+	MaxAgeDays int    `json:"maxAgeDays"`
+	Archive    bool   `json:"archive"`
+}
+
+type TableSize struct {
+	Name string `json:"name"`
+	Rows int64  `json:"rows"`
+	// Bytes is only reported when the database can tell, otherwise omitted
+	Bytes *int64 `json:"bytes,omitempty"`
+}
+
+type LogPurgeResult struct {
+	StartedAt   time.Time `json:"startedAt"`
+	Deleted     int64     `json:"deleted"`
+	Archived    int64     `json:"archived"`
+	ArchiveFile string    `json:"archiveFile,omitempty"`
+	// ChangeLogCompacted counts the change log entries dropped because a newer one replaces them
+	ChangeLogCompacted int64  `json:"changeLogCompacted"`
+	Error              string `json:"error,omitempty"`
+}
+
+type LogRetentionStatusResponse struct {
+	Tables        []TableSize     `json:"tables"`
+	DatabaseBytes *int64          `json:"databaseBytes,omitempty"`
+	NextPurgeAt   *time.Time      `json:"nextPurgeAt,omitempty"`
+	LastPurge     *LogPurgeResult `json:"lastPurge,omitempty"`
+}
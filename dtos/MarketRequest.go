@@ -1,15 +1,19 @@
 package dtos
 
+import "time"
+
 type CreateMarketItemRequest struct {
 	Description string  `json:"description"`
 	Price       float32 `json:"price"`
 	Title       string  `json:"title"`
 	CategoryId  uint    `json:"categoryId"`
 	UserId      uint    `json:"userId"`
+	// Draft keeps the item unlisted until it is published, otherwise it is listed right away
+	Draft bool `json:"draft"`
 }
 
+// UpdateMarketItemRequest replaces every field. userId 0 keeps the seller.
 type UpdateMarketItemRequest struct {
-	Id          string  `json:"id"`
 	Description string  `json:"description"`
 	Price       float32 `json:"price"`
 	Title       string  `json:"title"`
@@ -17,10 +21,112 @@ type UpdateMarketItemRequest struct {
 	UserId      uint    `json:"userId"`
 }
 
+// PatchMarketItemRequest only changes the fields that are set.
+type PatchMarketItemRequest struct {
+	Description *string  `json:"description"`
+	Price       *float32 `json:"price"`
+	Title       *string  `json:"title"`
+	CategoryId  *uint    `json:"categoryId"`
+	UserId      *uint    `json:"userId"`
+}
+
+// CreateCategory adds a category below parentId, or at the top without one. The slug is made from
+// the title when it is left out.
 type CreateCategory struct {
-	Title string `json:"title"`
+	Title    string `json:"title"`
+	Slug     string `json:"slug"`
+	ParentId *uint  `json:"parentId"`
+	Icon     string `json:"icon"`
+	Color    string `json:"color"`
 }
 
+// UpdateCategory replaces every field, a null parentId moves the category to the top.
 type UpdateCategory struct {
+	Title    string `json:"title"`
+	Slug     string `json:"slug"`
+	ParentId *uint  `json:"parentId"`
+	Icon     string `json:"icon"`
+	Color    string `json:"color"`
+}
+
+type CategorySummary struct {
+	ID    int    `json:"id"`
 	Title string `json:"title"`
-}
\ No newline at end of file
+	Slug  string `json:"slug"`
+}
+
+// SellerSummary leaves out the contact details of the seller.
+type SellerSummary struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type MarketItemResponse struct {
+	ID        int       `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// DeletedAt is only set for items in the trash, listed with includeDeleted
+	DeletedAt   *time.Time `json:"deletedAt,omitempty"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Price       float32    `json:"price"`
+	CategoryId  uint       `json:"categoryId"`
+	// Category and Seller are omitted when they don't exist (any more)
+	Category *CategorySummary `json:"category,omitempty"`
+	UserId   uint             `json:"userId"`
+	Seller   *SellerSummary   `json:"seller,omitempty"`
+	// Images are in display order, the first one is the cover photo
+	Images []MarketItemImageResponse `json:"images"`
+	Status string                    `json:"status" enums:"draft,active,reserved,sold,withdrawn"`
+	// ReducedFrom is the price before the last change, when that change lowered the price
+	ReducedFrom *float32 `json:"reducedFrom,omitempty"`
+	// ReservedById and ReservedUntil are only set while the item is reserved
+	ReservedById  *uint      `json:"reservedById,omitempty"`
+	ReservedUntil *time.Time `json:"reservedUntil,omitempty"`
+	PublishedAt   *time.Time `json:"publishedAt,omitempty"`
+	SoldAt        *time.Time `json:"soldAt,omitempty"`
+}
+
+// ReserveMarketItemRequest names the buyer to hold an item for. userId 0 reserves it for the
+// current user, only the seller may reserve it for someone else.
+type ReserveMarketItemRequest struct {
+	UserId uint `json:"userId"`
+}
+
+type MarketItemPriceChangeResponse struct {
+	// OldPrice is omitted for the price the item was listed with
+	OldPrice  *float32  `json:"oldPrice,omitempty"`
+	Price     float32   `json:"price"`
+	ChangedAt time.Time `json:"changedAt"`
+}
+
+type MarketSalesStatsResponse struct {
+	Sold int64 `json:"sold"`
+	// Time from publishing to selling, zero when nothing was sold
+	AverageHoursToSell float64 `json:"averageHoursToSell"`
+	MedianHoursToSell  float64 `json:"medianHoursToSell"`
+	// SoldAfterReduction counts the sold items whose price was lowered while listed
+	SoldAfterReduction int64 `json:"soldAfterReduction"`
+}
+
+type MarketItemImageResponse struct {
+	ID          int    `json:"id"`
+	Position    int    `json:"position"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	URL         string `json:"url"`
+	// ThumbnailURL is a JPEG of at most 320 pixels on the longest side
+	ThumbnailURL string    `json:"thumbnailUrl"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type MarketItemPage struct {
+	Items []MarketItemResponse `json:"items"`
+	// Total counts all items matching the filters, not only this page
+	Total int64 `json:"total"`
+	// NextCursor is passed as cursor to get the next page, empty on the last page
+	NextCursor string `json:"nextCursor,omitempty"`
+	HasMore    bool   `json:"hasMore"`
+}
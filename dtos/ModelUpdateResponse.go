@@ -0,0 +1,10 @@
+package dtos
+
+import "time"
+
+type ModelUpdateResponse struct {
+	Model string `json:"model"`
+	// Method of the last change: create, update or delete
+	Method    string    `json:"method"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
@@ -0,0 +1,59 @@
+package dtos
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// SyncChange is the current state of a record that changed after the cursor.
+type SyncChange struct {
+	Model string `json:"model"`
+	Id    string `json:"id"`
+	// Operation is "upsert" for created or updated records and "delete" for deleted ones
+	Operation string `json:"operation"`
+	// Record is the record as the REST API returns it, omitted for deletes
+	Record    any        `json:"record,omitempty" swaggertype:"object"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
+
+type SyncPullResponse struct {
+	Changes []SyncChange `json:"changes"`
+	// Cursor is passed back on the next pull
+	Cursor  uint64 `json:"cursor"`
+	HasMore bool   `json:"hasMore"`
+	// Reset means the database was restored from a backup since the cursor. The client drops what
+	// it synced and pulls again from cursor 0.
+	Reset bool `json:"reset"`
+}
+
+type SyncPushChange struct {
+	Model string `json:"model"`
+	// Id of the server record, 0 for create
+	Id int `json:"id"`
+	// ClientId is the client's own id for the record, echoed back in the result
+	ClientId  string `json:"clientId"`
+	Operation string `json:"operation"` // "create" | "update" | "delete"
+	// BaseUpdatedAt is the updatedAt of the server record the client changed. Required for update and delete.
+	BaseUpdatedAt *time.Time      `json:"baseUpdatedAt"`
+	Record        json.RawMessage `json:"record" swaggertype:"object"`
+}
+
+type SyncPushRequest struct {
+	Changes []SyncPushChange `json:"changes"`
+}
+
+type SyncPushResult struct {
+	Model    string `json:"model"`
+	Id       int    `json:"id"`
+	ClientId string `json:"clientId,omitempty"`
+	// Status is "applied", "conflict" (the server record changed since BaseUpdatedAt) or "rejected"
+	Status string            `json:"status"`
+	Error  string            `json:"error,omitempty"`
+	Fields map[string]string `json:"fields,omitempty"`
+	// Record is the server version after applying, or the conflicting server version
+	Record any `json:"record,omitempty" swaggertype:"object"`
+}
+
+type SyncPushResponse struct {
+	Results []SyncPushResult `json:"results"`
+}
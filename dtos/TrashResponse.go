@@ -0,0 +1,14 @@
+package dtos
+
+type TrashSummary struct {
+	Model string `json:"model"`
+	Count int64  `json:"count"`
+}
+
+type TrashPage struct {
+	Model    string `json:"model"`
+	Items    any    `json:"items" swaggertype:"array,object"`
+	Total    int64  `json:"total"`
+	Page     int    `json:"page"`
+	PageSize int    `json:"pageSize"`
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"api/database"
 	_ "api/docs"
 	"api/server"
+	"api/services"
 	"log"
+	"os"
 
 	"github.com/joho/godotenv"
 )
@@ -13,5 +16,18 @@ func main() {
 	if err != nil {
 		log.Println("Failed to load environment variables.")
 	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := database.RunMigrateCommand(os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	// The first admin key, while every route still needs one
+	if len(os.Args) > 1 && os.Args[1] == "keys" {
+		if err := services.RunKeysCommand(os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 	server.InitalizeServer()
 }
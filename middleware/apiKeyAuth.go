@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"api/database"
+	"api/models"
+	"api/services"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Keys used to store the authenticated caller in the Fiber context.
+const (
+	userLocalsKey   = "authUser"
+	apiKeyLocalsKey = "authApiKey"
+)
+
+// lastUsedResolution limits how often LastUsedAt is written, to spare the SD card.
+const lastUsedResolution = time.Minute
+
+// ApiKeyAuthConfig configures the API key middleware.
+type ApiKeyAuthConfig struct {
+	// Allowlist holds paths that are reachable without an API key.
+	// A trailing "*" matches any path with the given prefix, e.g. "/swagger/*".
+	Allowlist []string
+	// Disabled turns authentication off entirely. Only meant for local development.
+	Disabled bool
+}
+
+// DefaultApiKeyAuthConfig returns the config used by the server.
+// Env: API_AUTH_ALLOWLIST (comma separated, replaces the default allowlist), API_AUTH_DISABLED ("true" to disable).
+func DefaultApiKeyAuthConfig() ApiKeyAuthConfig {
+	config := ApiKeyAuthConfig{
+		Allowlist: []string{"/swagger/*", "/health"},
+		Disabled:  os.Getenv("API_AUTH_DISABLED") == "true",
+	}
+	if allowlist := os.Getenv("API_AUTH_ALLOWLIST"); allowlist != "" {
+		config.Allowlist = nil
+		for _, path := range strings.Split(allowlist, ",") {
+			if path = strings.TrimSpace(path); path != "" {
+				config.Allowlist = append(config.Allowlist, path)
+			}
+		}
+	}
+	return config
+}
+
+// NewApiKeyAuth returns a middleware that checks the X-API-Key or Authorization: Bearer header
+// against the stored API keys and attaches the owning user to the request context.
+func NewApiKeyAuth(config ApiKeyAuthConfig) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if config.Disabled || isAllowlisted(config.Allowlist, c.Path()) {
+			return c.Next()
+		}
+
+		key := extractApiKey(c)
+		if key == "" {
+			return unauthorized(c, "Missing API key")
+		}
+
+		prefix, ok := services.ApiKeyPrefix(key)
+		if !ok {
+			return unauthorized(c, "Invalid API key")
+		}
+		var apiKey models.ApiKey
+		if err := database.DB.Preload("User").Where("prefix = ?", prefix).First(&apiKey).Error; err != nil {
+			return unauthorized(c, "Invalid API key")
+		}
+		if !services.VerifyApiKey(key, &apiKey) {
+			return unauthorized(c, "Invalid API key")
+		}
+		now := time.Now()
+		if apiKey.RevokedAt != nil {
+			return unauthorized(c, "API key has been revoked")
+		}
+		if apiKey.ExpiresAt != nil && apiKey.ExpiresAt.Before(now) {
+			return unauthorized(c, "API key has expired")
+		}
+		if apiKey.UserId == 0 || apiKey.User.ID == 0 {
+			return forbidden(c, "API key is not assigned to a user")
+		}
+		if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) > lastUsedResolution {
+			database.WithoutChangeLog(database.DB).Model(&apiKey).UpdateColumn("last_used_at", now)
+		}
+
+		c.Locals(apiKeyLocalsKey, &apiKey)
+		c.Locals(userLocalsKey, &apiKey.User)
+		// Changes written with c.UserContext() are attributed to the user in the change log
+		c.SetUserContext(database.WithActingUser(c.UserContext(), uint(apiKey.User.ID)))
+		return c.Next()
+	}
+}
+
+// CurrentUser returns the user that owns the API key of the request, or nil when the
+// request was not authenticated (allowlisted path or authentication disabled).
+func CurrentUser(c *fiber.Ctx) *models.User {
+	user, _ := c.Locals(userLocalsKey).(*models.User)
+	return user
+}
+
+// extractApiKey reads the key from X-API-Key, falling back to a Bearer token.
+func extractApiKey(c *fiber.Ctx) string {
+	if key := strings.TrimSpace(c.Get("X-API-Key")); key != "" {
+		return key
+	}
+	auth := c.Get(fiber.HeaderAuthorization)
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+func isAllowlisted(allowlist []string, path string) bool {
+	for _, allowed := range allowlist {
+		if prefix, ok := strings.CutSuffix(allowed, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		} else if path == allowed || path == allowed+"/" {
+			return true
+		}
+	}
+	return false
+}
+
+func unauthorized(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+		"error": message,
+	})
+}
+
+func forbidden(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"error": message,
+	})
+}
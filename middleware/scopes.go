@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"api/models"
+	"api/services"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Scopes that can be granted to an API key. A key may also hold "<resource>:*" to get
+// every scope of a resource, or "*" for everything.
+var KnownScopes = []string{
+	"admin",
+	"users:read", "users:write",
+	"categories:read", "categories:write",
+	"marketitem:read", "marketitem:write",
+	"api_keys:read", "api_keys:write",
+	"bloodpressure:read", "bloodpressure:write",
+	"caregivers:read", "caregivers:write",
+	"alerts:read", "alerts:write",
+	"changes:read", "model_updates:read",
+	"logbook:read", "logbook:write",
+	"chat:read", "chat:write",
+	"mcp:use",
+}
+
+// IsKnownScope reports whether scope can be granted, including wildcards.
+func IsKnownScope(scope string) bool {
+	if scope == "*" {
+		return true
+	}
+	if resource, ok := strings.CutSuffix(scope, ":*"); ok {
+		for _, known := range KnownScopes {
+			if strings.HasPrefix(known, resource+":") {
+				return true
+			}
+		}
+		return false
+	}
+	for _, known := range KnownScopes {
+		if known == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireScope rejects requests whose API key does not grant scope with 403.
+// Requests without a key (authentication disabled) are let through.
+func RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		apiKey := currentApiKey(c)
+		if apiKey == nil || hasScope(apiKey, scope) {
+			return c.Next()
+		}
+		return forbidden(c, "API key is missing scope "+scope)
+	}
+}
+
+// HasScope reports whether the request may use scope. Unauthenticated requests
+// only get through when authentication is disabled, so they are granted everything.
+func HasScope(c *fiber.Ctx, scope string) bool {
+	apiKey := currentApiKey(c)
+	return apiKey == nil || hasScope(apiKey, scope)
+}
+
+// IsAdmin reports whether the request carries the admin scope.
+func IsAdmin(c *fiber.Ctx) bool {
+	return HasScope(c, "admin")
+}
+
+func currentApiKey(c *fiber.Ctx) *models.ApiKey {
+	apiKey, _ := c.Locals(apiKeyLocalsKey).(*models.ApiKey)
+	return apiKey
+}
+
+func hasScope(apiKey *models.ApiKey, required string) bool {
+	resource, _, _ := strings.Cut(required, ":")
+	for _, granted := range services.ParseScopes(apiKey.Scopes) {
+		if granted == "*" || granted == required || granted == resource+":*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// AlertRule is a user defined condition on blood pressure readings.
+//
+//	threshold: a single reading where Field Operator Threshold, e.g. systolic > 180
+//	count:     at least Count readings matching Field Operator Threshold within WindowHours
+//	missing:   no reading at all for WindowHours
+type AlertRule struct {
+	BaseModel
+	Name        string     `json:"name"`
+	Type        string     `json:"type"`
+	Field       string     `json:"field"`
+	Operator    string     `json:"operator"`
+	Threshold   float64    `json:"threshold"`
+	Count       int        `json:"count"`
+	WindowHours int        `json:"windowHours"`
+	Notifier    string     `json:"notifier"` // "" (log book only) | "webhook" | "smtp" | "ntfy"
+	Target      string     `json:"target"`   // webhook URL, e-mail address or ntfy topic URL
+	Enabled     bool       `json:"enabled"`
+	LastFiredAt *time.Time `json:"lastFiredAt,omitempty"`
+	// UserId is the user whose readings are watched, CreatedById the user that manages the rule
+	UserId      uint `json:"userId" gorm:"index"`
+	CreatedById uint `json:"createdById"`
+}
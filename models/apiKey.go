@@ -1,11 +1,20 @@
 package models
 
+import "time"
+
+// ApiKey is a server issued key. Only a salted hash of the secret is stored;
+// the visible Prefix is used to look the key up.
 type ApiKey struct {
 	BaseModel
-	ClientSecret string `json:"client_secret"`
-	ClientId     string `json:"client_id"`
-	ApiKey       string `json:"api_key"`
-	Type         string `json:"api_type"`
-	UserId       uint   `json:"user_id"`
-	User         User   `json:"user"`
-}
\ No newline at end of file
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix" gorm:"size:32;uniqueIndex"`
+	KeyHash    string     `json:"-"`
+	Salt       string     `json:"-"`
+	Scopes     string     `json:"scopes"` // comma separated, e.g. "bloodpressure:read,chat:write"
+	Type       string     `json:"api_type"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	UserId     uint       `json:"user_id"`
+	User       User       `json:"user"`
+}
@@ -1,10 +1,15 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type BaseModel struct {
-	ID        int        `json:"id" gorm:"primaryKey"`
-	CreatedAt time.Time  `json:"createdAt"`
-	UpdatedAt time.Time  `json:"updatedAt"`
-	DeletedAt *time.Time `json:"deletedAt,omitempty" gorm:"index"`
+	ID        int       `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// DeletedAt is set when the record was moved to the trash, deleted records are left out of queries unless Unscoped
+	DeletedAt gorm.DeletedAt `json:"deletedAt" gorm:"index" swaggertype:"string" format:"date-time"`
 }
@@ -1,9 +1,13 @@
 package models
 
+import "time"
+
 type BloodPressure struct {
 	BaseModel
-	Systolic  int    `json:"systolic"`
-	Diastolic int    `json:"diastolic"`
-	Pulse     int    `json:"pulse"`
-	Medicine  string `json:"medicine"`
-}
\ No newline at end of file
+	Systolic   int       `json:"systolic"`
+	Diastolic  int       `json:"diastolic"`
+	Pulse      int       `json:"pulse"`
+	Medicine   string    `json:"medicine"`
+	MeasuredAt time.Time `json:"measuredAt" gorm:"index"`
+	UserId     uint      `json:"userId" gorm:"index"`
+}
@@ -0,0 +1,10 @@
+package models
+
+// Caregiver lets CaregiverId read the health records and log book of PatientId.
+type Caregiver struct {
+	BaseModel
+	PatientId   uint `json:"patientId" gorm:"uniqueIndex:idx_caregiver_pair,where:deleted_at IS NULL"`
+	Patient     User `json:"patient" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	CaregiverId uint `json:"caregiverId" gorm:"uniqueIndex:idx_caregiver_pair;index"`
+	Caregiver   User `json:"caregiver" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ChangeLogEntry records one create, update or delete of a row. Rows are only ever appended,
+// so the ID doubles as a cursor for incremental sync.
+type ChangeLogEntry struct {
+	ID        uint64 `json:"id" gorm:"primaryKey"`
+	ModelName string `json:"model" gorm:"index:idx_change_log_record"`
+	RecordId  string `json:"recordId" gorm:"index:idx_change_log_record"`
+	Operation string `json:"operation"` // "create" | "update" | "delete", or "reset" with model "*" after a restore
+	// Changes maps each changed field to {"old": ..., "new": ...}
+	Changes json.RawMessage `json:"changes" gorm:"type:text" swaggertype:"object"`
+	// UserId is the acting user, nil for changes made by the system
+	UserId *uint `json:"userId,omitempty"`
+	// OwnerId is the user the changed record belongs to, nil for shared records
+	OwnerId   *uint     `json:"ownerId,omitempty" gorm:"index"`
+	Timestamp time.Time `json:"timestamp" gorm:"index"`
+}
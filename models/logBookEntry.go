@@ -4,8 +4,11 @@ import "time"
 
 type LogBookEntry struct {
 	BaseModel
-	Message   string    `json:"message"`
-	Level     string    `json:"level"`
-	Category  string    `json:"category"`
-	Timestamp time.Time `json:"timestamp"`
+	Message string `json:"message"`
+	// Level is one of debug, info, warn or error
+	Level     string    `json:"level" gorm:"index"`
+	Category  string    `json:"category" gorm:"index"`
+	Timestamp time.Time `json:"timestamp" gorm:"index"`
+	// UserId is 0 for system entries, which are visible to every user
+	UserId uint `json:"userId" gorm:"index"`
 }
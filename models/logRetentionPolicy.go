@@ -0,0 +1,14 @@
+package models
+
+// LogRetentionPolicy keeps LogBookEntries of a Level and/or Category for MaxAgeDays.
+// Empty Level or Category match any value; when several policies match an entry the most
+// specific one wins: level and category, then category only, then level only, then the
+// default policy with both empty. Entries no policy matches are kept forever.
+type LogRetentionPolicy struct {
+	BaseModel
+	Level      string `json:"level" gorm:"size:16;uniqueIndex:idx_log_retention_scope,where:deleted_at IS NULL"`
+	Category   string `json:"category" gorm:"size:100;uniqueIndex:idx_log_retention_scope"`
+	MaxAgeDays int    `json:"maxAgeDays"`
+	// Archive writes expired entries to a gzipped NDJSON file before deleting them
+	Archive bool `json:"archive"`
+}
@@ -1,6 +1,13 @@
 package models
 
+// Category groups market items. Categories form a tree, a category without a parent is at the top.
 type Category struct {
 	BaseModel
 	Title string `json:"title"`
-}
\ No newline at end of file
+	// Slug names the category in URLs, it is unique among the categories that are not deleted
+	Slug     string `json:"slug" gorm:"size:100;uniqueIndex:idx_category_slug,where:deleted_at IS NULL"`
+	ParentId *uint  `json:"parentId" gorm:"index"`
+	// Icon is an emoji or icon name for clients to show, Color a hex colour like #a0522d
+	Icon  string `json:"icon" gorm:"size:64"`
+	Color string `json:"color" gorm:"size:7"`
+}
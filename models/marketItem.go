@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type MarketItem struct {
 	BaseModel
 	Description string  `json:"description"`
@@ -9,4 +11,16 @@ type MarketItem struct {
 	Category    Category `json:"category" gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
 	UserId      uint
 	User        User `json:"user"     gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
-}
\ No newline at end of file
+	// Status is draft, active, reserved, sold or withdrawn, it only changes through the lifecycle endpoints
+	Status string `json:"status" gorm:"size:16;index"`
+	// ReservedById and ReservedUntil are only set while the item is reserved
+	ReservedById  *uint      `json:"reservedById"`
+	ReservedUntil *time.Time `json:"reservedUntil"`
+	// PublishedAt is when the item was first listed, SoldAt when it was sold
+	PublishedAt *time.Time `json:"publishedAt"`
+	SoldAt      *time.Time `json:"soldAt"`
+	// Images are ordered by Position
+	Images []MarketItemImage `json:"images,omitempty"`
+	// PriceChanges are ordered by ID
+	PriceChanges []MarketItemPriceChange `json:"-"`
+}
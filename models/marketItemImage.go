@@ -0,0 +1,16 @@
+package models
+
+// MarketItemImage is a photo of a MarketItem. The file and its thumbnail are stored on disk,
+// under the market image directory.
+type MarketItemImage struct {
+	BaseModel
+	MarketItemId uint `json:"marketItemId" gorm:"index"`
+	// FileName is the path of the image relative to the image directory, the thumbnail is next to it
+	FileName    string `json:"-" gorm:"size:255"`
+	ContentType string `json:"contentType" gorm:"size:32"`
+	Size        int64  `json:"size"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	// Position orders the images of an item, the first one is the cover photo
+	Position int `json:"position"`
+}
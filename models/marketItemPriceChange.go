@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// MarketItemPriceChange records the price of a MarketItem when it was created and every time
+// it changed. Rows are only ever appended.
+type MarketItemPriceChange struct {
+	ID           uint64 `json:"id" gorm:"primaryKey"`
+	MarketItemId uint   `json:"marketItemId" gorm:"index"`
+	// OldPrice is nil for the price the item was created with
+	OldPrice  *float32  `json:"oldPrice"`
+	Price     float32   `json:"price"`
+	ChangedAt time.Time `json:"changedAt"`
+}
@@ -3,6 +3,6 @@ package models
 // ModelUpdates tracks the last update method for each model in the system
 type ModelUpdates struct {
 	BaseModel
-	ModelName string `json:"model_name" gorm:"uniqueIndex"`
+	ModelName string `json:"model_name" gorm:"size:64;uniqueIndex"`
 	Method    string `json:"action"`
 }
@@ -4,9 +4,13 @@ import (
 	"api/controllers"
 	"api/database"
 	"api/mcpServer"
+	"api/middleware"
 	"api/services"
+	"context"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/gofiber/adaptor/v2"
 	"github.com/gofiber/fiber/v2"
@@ -30,6 +34,11 @@ import (
 // @host localhost:8081
 // @BasePath /api
 // @schemes http
+
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+// @security ApiKeyAuth
 var App *fiber.App
 var Api fiber.Router
 var Port = ""
@@ -37,18 +46,49 @@ var Port = ""
 func InitalizeServer() {
 	// Create a new Fiber app
 	Port = os.Getenv("GOPORT")
-	App = fiber.New()
+	App = fiber.New(fiber.Config{
+		// Database restores are uploaded whole
+		BodyLimit: bodyLimit(),
+	})
 	// Add CORS middleware
 	App.Use(cors.New(cors.Config{
 		AllowOrigins: "*", // Allows all origins
 	}))
 	database.InitDB()
+	// Every route except the allowlist (swagger, health) requires an API key
+	App.Use(middleware.NewApiKeyAuth(middleware.DefaultApiKeyAuthConfig()))
+	App.Get("/health", healthCheck)
 	mcpSrv := mcpServer.NewServer()
 	mcpHTTP := mcp.NewStreamableHTTPServer(mcpSrv, mcp.WithEndpointPath("/mcp"))
-	App.All("/mcp/*", adaptor.HTTPHandler(mcpHTTP))
+	App.All("/mcp/*", middleware.RequireScope("mcp:use"), adaptor.HTTPHandler(mcpHTTP))
 	Api = App.Group("/api")
 	chatService := services.NewChatService(mcpSrv)
-	SetupRoutes(&Api, chatService)
+	// Every new log book entry, however it is created, is pushed to /log_book/stream
+	logBookStream := services.NewLogBookStream()
+	if err := logBookStream.Register(database.DB); err != nil {
+		log.Println("Failed to set up the log book stream:", err)
+	}
+	// Alert rules are evaluated for every reading written, however it is written
+	alertService := services.NewAlertService()
+	if err := alertService.Register(database.DB); err != nil {
+		log.Println("Failed to set up alert evaluation:", err)
+	}
+	alertService.Start(context.Background(), alertCheckInterval())
+	// System logs (syslog, a file, the journal) end up in the log book when configured
+	if err := services.NewLogIngester(services.DefaultLogIngestConfig()).Start(context.Background()); err != nil {
+		log.Println("Failed to start log ingestion:", err)
+	}
+	retentionService := services.NewLogRetentionService()
+	retentionService.Start(context.Background(), logRetentionInterval())
+	backupService := services.NewBackupService()
+	backupService.Start(context.Background(), backupInterval())
+	// Every price a market item had is kept, however it is changed
+	marketLifecycle := services.NewMarketLifecycleService()
+	if err := marketLifecycle.Register(database.DB); err != nil {
+		log.Println("Failed to set up the market price history:", err)
+	}
+	marketLifecycle.Start(context.Background(), reservationCheckInterval())
+	SetupRoutes(&Api, chatService, alertService, retentionService, logBookStream, backupService, marketLifecycle)
 	// Serve Swagger UI
 	App.Get("/swagger/*", fiberSwagger.WrapHandler)
 	log.Println("Registered Routes:")
@@ -61,14 +101,75 @@ func InitalizeServer() {
 	App.Listen(":" + Port)
 }
 
+// healthCheck reports that the server is up. It is reachable without an API key.
+func healthCheck(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"status": "ok"})
+}
+
+// alertCheckInterval is how often "no reading" alert rules are checked. Env: ALERT_CHECK_INTERVAL (Go duration, default 15m).
+func alertCheckInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("ALERT_CHECK_INTERVAL"))
+	if err != nil || interval <= 0 {
+		return 15 * time.Minute
+	}
+	return interval
+}
+
+// logRetentionInterval is how often expired log book entries are purged. Env: LOG_RETENTION_INTERVAL (Go duration, default 24h).
+func logRetentionInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("LOG_RETENTION_INTERVAL"))
+	if err != nil || interval <= 0 {
+		return 24 * time.Hour
+	}
+	return interval
+}
+
+// backupInterval is how often the database is backed up. Env: BACKUP_INTERVAL (Go duration, default 24h).
+func backupInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("BACKUP_INTERVAL"))
+	if err != nil || interval <= 0 {
+		return 24 * time.Hour
+	}
+	return interval
+}
+
+// reservationCheckInterval is how often expired market item reservations are released. Env: MARKET_RESERVATION_CHECK_INTERVAL (Go duration, default 1m).
+func reservationCheckInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("MARKET_RESERVATION_CHECK_INTERVAL"))
+	if err != nil || interval <= 0 {
+		return time.Minute
+	}
+	return interval
+}
+
+// bodyLimit is the largest request body accepted. Env: MAX_BODY_MB (default 64).
+func bodyLimit() int {
+	megabytes, err := strconv.Atoi(os.Getenv("MAX_BODY_MB"))
+	if err != nil || megabytes <= 0 {
+		megabytes = 64
+	}
+	return megabytes << 20
+}
+
 // SetupRoutes automatically registers controllers
-func SetupRoutes(app *fiber.Router, chatService *services.ChatService) {
+func SetupRoutes(app *fiber.Router, chatService *services.ChatService, alertService *services.AlertService, retentionService *services.LogRetentionService, logBookStream *services.LogBookStream, backupService *services.BackupService, marketLifecycle *services.MarketLifecycleService) {
 	controllersList := []controllers.Controller{
 		&controllers.UserController{},
 		&controllers.CategoryController{},
-		&controllers.MarketItemController{},
+		controllers.NewMarketItemController(marketLifecycle),
+		controllers.NewMarketItemImageController(services.NewMarketImageService()),
 		&controllers.ApiKeyController{},
-		&controllers.BloodPressureController{},
+		controllers.NewBloodPressureController(alertService),
+		&controllers.CaregiverController{},
+		controllers.NewLogBookEntryController(logBookStream),
+		controllers.NewLogRetentionController(retentionService),
+		controllers.NewAlertRuleController(alertService),
+		&controllers.ChangeLogController{},
+		&controllers.ModelUpdatesController{},
+		&controllers.SyncController{},
+		&controllers.TrashController{},
+		controllers.NewBackupController(backupService),
+		&controllers.DatabaseController{},
 		controllers.NewChatController(chatService),
 	}
 
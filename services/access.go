@@ -0,0 +1,29 @@
+package services
+
+import (
+	"api/database"
+	"api/models"
+)
+
+// CanReadUserRecords reports whether viewerId may read the health records and log book of ownerId,
+// either because they are the same user or because viewerId is a caregiver of ownerId.
+func CanReadUserRecords(viewerId, ownerId uint) (bool, error) {
+	if viewerId == ownerId {
+		return true, nil
+	}
+	var count int64
+	err := database.DB.Model(&models.Caregiver{}).
+		Where("caregiver_id = ? AND patient_id = ?", viewerId, ownerId).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ReadableUserIds returns viewerId and every user viewerId is a caregiver of.
+func ReadableUserIds(viewerId uint) ([]uint, error) {
+	ids := []uint{viewerId}
+	var patients []uint
+	if err := database.DB.Model(&models.Caregiver{}).Where("caregiver_id = ?", viewerId).Pluck("patient_id", &patients).Error; err != nil {
+		return nil, err
+	}
+	return append(ids, patients...), nil
+}
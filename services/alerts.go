@@ -0,0 +1,326 @@
+package services
+
+import (
+	"api/database"
+	"api/models"
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// Rule types, fields and operators accepted in an AlertRule.
+var (
+	AlertRuleTypes     = []string{"threshold", "count", "missing"}
+	AlertRuleFields    = []string{"systolic", "diastolic", "pulse"}
+	AlertRuleOperators = []string{">", ">=", "<", "<="}
+)
+
+const alertNotifyTimeout = 30 * time.Second
+
+// AlertService evaluates alert rules against blood pressure readings, records firings
+// in the log book and hands them to the rule's notifier.
+type AlertService struct {
+	// NewNotifier builds the notifier of a rule. Replaceable to point alerts at a stand-in.
+	NewNotifier func(kind, target string) (Notifier, error)
+}
+
+func NewAlertService() *AlertService {
+	return &AlertService{NewNotifier: NewNotifier}
+}
+
+// ValidateAlertRule returns a message per invalid field, or nil when the rule is valid.
+func ValidateAlertRule(rule models.AlertRule) map[string]string {
+	fields := map[string]string{}
+	if rule.Name == "" {
+		fields["name"] = "is required"
+	} else if strings.ContainsFunc(rule.Name, unicode.IsControl) {
+		// The name ends up in e-mail headers
+		fields["name"] = "must not contain control characters like line breaks"
+	}
+	if !slices.Contains(AlertRuleTypes, rule.Type) {
+		fields["type"] = "must be threshold, count or missing"
+	}
+	if rule.Type == "threshold" || rule.Type == "count" {
+		if !slices.Contains(AlertRuleFields, rule.Field) {
+			fields["field"] = "must be systolic, diastolic or pulse"
+		}
+		if !slices.Contains(AlertRuleOperators, rule.Operator) {
+			fields["operator"] = "must be >, >=, < or <="
+		}
+	}
+	if rule.Type == "count" && rule.Count < 1 {
+		fields["count"] = "must be at least 1"
+	}
+	if (rule.Type == "count" || rule.Type == "missing") && rule.WindowHours < 1 {
+		fields["windowHours"] = "must be at least 1"
+	}
+	if _, err := NewNotifier(rule.Notifier, rule.Target); err != nil {
+		fields["notifier"] = err.Error()
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// EvaluateReading checks the threshold and count rules of the reading's user.
+func (s *AlertService) EvaluateReading(reading models.BloodPressure) {
+	var rules []models.AlertRule
+	err := database.DB.Where("user_id = ? AND enabled = ? AND type IN ?", reading.UserId, true, []string{"threshold", "count"}).Find(&rules).Error
+	if err != nil {
+		log.Println("Failed to load alert rules:", err)
+		return
+	}
+
+	at := BloodPressureReadingTime(reading)
+	for _, rule := range rules {
+		if !matchesRule(rule, reading) {
+			continue
+		}
+		switch rule.Type {
+		case "threshold":
+			s.Fire(rule, "error", fmt.Sprintf("Blood pressure %d/%d, pulse %d at %s: %s %s %g",
+				reading.Systolic, reading.Diastolic, reading.Pulse, at.Local().Format(reportDateTimeFormat), rule.Field, rule.Operator, rule.Threshold))
+		case "count":
+			windowStart := at.Add(-time.Duration(rule.WindowHours) * time.Hour)
+			// Fire once per window, not for every further reading in it
+			if rule.LastFiredAt != nil && rule.LastFiredAt.After(windowStart) {
+				continue
+			}
+			var recent []models.BloodPressure
+			err := database.DB.Where("user_id = ? AND measured_at > ? AND measured_at <= ?", reading.UserId, windowStart, at).Find(&recent).Error
+			if err != nil {
+				log.Println("Failed to load readings for alert rule:", err)
+				continue
+			}
+			matching := 0
+			for _, r := range recent {
+				if matchesRule(rule, r) {
+					matching++
+				}
+			}
+			if matching >= rule.Count {
+				s.Fire(rule, "warn", fmt.Sprintf("%d readings with %s %s %g within %dh, latest %d/%d at %s",
+					matching, rule.Field, rule.Operator, rule.Threshold, rule.WindowHours, reading.Systolic, reading.Diastolic, at.Local().Format(reportDateTimeFormat)))
+			}
+		}
+	}
+}
+
+// EvaluateReadings evaluates the readings with ids, for writes Register leaves out.
+func (s *AlertService) EvaluateReadings(ids []int) {
+	if len(ids) == 0 {
+		return
+	}
+	var readings []models.BloodPressure
+	if err := database.DB.Where("id IN ?", ids).Order("measured_at, id").Find(&readings).Error; err != nil {
+		log.Println("Failed to load readings for alert rules:", err)
+		return
+	}
+	for _, reading := range readings {
+		s.EvaluateReading(reading)
+	}
+}
+
+// Register evaluates every blood pressure reading created with db once it is committed,
+// whether it comes from the API, sync or anything else, and every reading an update changed
+// the measurement of. Writes inside a transaction of the caller are left out: the transaction
+// still holds the database, so the caller evaluates them with EvaluateReadings after committing.
+func (s *AlertService) Register(db *gorm.DB) error {
+	applies := func(db *gorm.DB) bool {
+		if db.Error != nil || db.Statement.Schema == nil || db.Statement.Schema.Name != "BloodPressure" {
+			return false
+		}
+		_, inTransaction := db.Statement.ConnPool.(gorm.TxCommitter)
+		return !inTransaction
+	}
+	callbacks := db.Callback()
+	err := callbacks.Create().After("gorm:commit_or_rollback_transaction").Register("alerts:create", func(db *gorm.DB) {
+		if !applies(db) {
+			return
+		}
+		for _, reading := range bloodPressuresOf(db.Statement.ReflectValue) {
+			s.EvaluateReading(reading)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return callbacks.Update().After("gorm:commit_or_rollback_transaction").Register("alerts:update", func(db *gorm.DB) {
+		if !applies(db) {
+			return
+		}
+		// Editing the medicine or restoring a reading from the trash must not fire its alerts again
+		before, ok := database.RowsBeforeChange(db)
+		if !ok {
+			for _, reading := range bloodPressuresOf(db.Statement.ReflectValue) {
+				s.EvaluateReading(reading)
+			}
+			return
+		}
+		old := map[int]models.BloodPressure{}
+		var ids []int
+		for _, reading := range bloodPressuresOf(before) {
+			old[reading.ID] = reading
+			ids = append(ids, reading.ID)
+		}
+		if len(ids) == 0 {
+			return
+		}
+		var readings []models.BloodPressure
+		if err := db.Session(&gorm.Session{NewDB: true}).Where("id IN ?", ids).Order("measured_at, id").Find(&readings).Error; err != nil {
+			log.Println("Failed to load updated readings for alert rules:", err)
+			return
+		}
+		for _, reading := range readings {
+			if measurementChanged(old[reading.ID], reading) {
+				s.EvaluateReading(reading)
+			}
+		}
+	})
+}
+
+// measurementChanged reports whether the values the alert rules look at differ between the readings.
+func measurementChanged(a, b models.BloodPressure) bool {
+	return a.Systolic != b.Systolic || a.Diastolic != b.Diastolic || a.Pulse != b.Pulse || !a.MeasuredAt.Equal(b.MeasuredAt)
+}
+
+// bloodPressuresOf returns the readings in the value of a statement, which may be a single reading or a batch.
+func bloodPressuresOf(value reflect.Value) []models.BloodPressure {
+	var readings []models.BloodPressure
+	add := func(v reflect.Value) {
+		for v.Kind() == reflect.Pointer && !v.IsNil() {
+			v = v.Elem()
+		}
+		if reading, ok := v.Interface().(models.BloodPressure); ok && reading.ID != 0 {
+			readings = append(readings, reading)
+		}
+	}
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			add(value.Index(i))
+		}
+	case reflect.Struct, reflect.Pointer:
+		add(value)
+	}
+	return readings
+}
+
+// CheckMissingReadings fires missing rules of users without a reading for the rule's window.
+// Each gap fires once; a new reading re-arms the rule.
+func (s *AlertService) CheckMissingReadings() {
+	var rules []models.AlertRule
+	if err := database.DB.Where("enabled = ? AND type = ?", true, "missing").Find(&rules).Error; err != nil {
+		log.Println("Failed to load alert rules:", err)
+		return
+	}
+
+	now := time.Now()
+	for _, rule := range rules {
+		var latest models.BloodPressure
+		since := rule.CreatedAt
+		result := database.DB.Where("user_id = ?", rule.UserId).Order("measured_at DESC").Limit(1).Find(&latest)
+		if result.Error != nil {
+			log.Println("Failed to load latest reading for alert rule:", result.Error)
+			continue
+		}
+		if result.RowsAffected > 0 && BloodPressureReadingTime(latest).After(since) {
+			since = BloodPressureReadingTime(latest)
+		}
+		if now.Sub(since) < time.Duration(rule.WindowHours)*time.Hour {
+			continue
+		}
+		if rule.LastFiredAt != nil && rule.LastFiredAt.After(since) {
+			continue
+		}
+		s.Fire(rule, "warn", fmt.Sprintf("No blood pressure reading for %dh, last one at %s", rule.WindowHours, since.Local().Format(reportDateTimeFormat)))
+	}
+}
+
+// Start checks the missing rules every interval until ctx is done.
+func (s *AlertService) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.CheckMissingReadings()
+			}
+		}
+	}()
+}
+
+// Fire records the alert in the log book and sends it to the rule's notifier in the background.
+func (s *AlertService) Fire(rule models.AlertRule, level, message string) {
+	now := time.Now()
+	entry := models.LogBookEntry{
+		Message:   rule.Name + ": " + message,
+		Level:     level,
+		Category:  "alert",
+		Timestamp: now,
+		UserId:    rule.UserId,
+	}
+	if err := database.DB.Create(&entry).Error; err != nil {
+		log.Println("Failed to write alert to log book:", err)
+	}
+	if rule.ID != 0 {
+		database.DB.Model(&models.AlertRule{}).Where("id = ?", rule.ID).UpdateColumn("last_fired_at", now)
+	}
+
+	alert := Alert{RuleId: rule.ID, RuleName: rule.Name, UserId: rule.UserId, Level: level, Message: message, FiredAt: now}
+	go func() {
+		if err := s.Notify(rule, alert); err != nil {
+			log.Printf("Failed to notify alert rule %d: %v", rule.ID, err)
+		}
+	}()
+}
+
+// Notify sends alert through the notifier of rule.
+func (s *AlertService) Notify(rule models.AlertRule, alert Alert) error {
+	notifier, err := s.NewNotifier(rule.Notifier, rule.Target)
+	if err != nil || notifier == nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), alertNotifyTimeout)
+	defer cancel()
+	return notifier.Notify(ctx, alert)
+}
+
+func matchesRule(rule models.AlertRule, reading models.BloodPressure) bool {
+	var value float64
+	switch rule.Field {
+	case "systolic":
+		value = float64(reading.Systolic)
+	case "diastolic":
+		value = float64(reading.Diastolic)
+	case "pulse":
+		if reading.Pulse == 0 {
+			return false
+		}
+		value = float64(reading.Pulse)
+	default:
+		return false
+	}
+	switch rule.Operator {
+	case ">":
+		return value > rule.Threshold
+	case ">=":
+		return value >= rule.Threshold
+	case "<":
+		return value < rule.Threshold
+	case "<=":
+		return value <= rule.Threshold
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"api/database"
+	"api/models"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+)
+
+const keysUsage = `Usage: api keys create -admin [-name <name>] [-user <id>]
+
+Issues an API key with every scope, for the first admin or when every admin key is lost.
+The key belongs to the given user, or to the first user, who is created when there is none.
+Keys with fewer scopes are issued with this one through POST /api/api_keys.
+`
+
+// RunKeysCommand runs the keys subcommand with the arguments after "keys" and prints the new key to out.
+func RunKeysCommand(args []string, out io.Writer) error {
+	if len(args) == 0 || args[0] != "create" {
+		fmt.Fprint(out, keysUsage)
+		return errors.New("unknown keys command")
+	}
+	flags := flag.NewFlagSet("keys create", flag.ContinueOnError)
+	flags.SetOutput(out)
+	flags.Usage = func() { fmt.Fprint(out, keysUsage) }
+	admin := flags.Bool("admin", false, "grant every scope")
+	name := flags.String("name", "admin", "name of the key")
+	userId := flags.Uint("user", 0, "user the key belongs to")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if !*admin {
+		flags.Usage()
+		return errors.New("only admin keys are created here, pass -admin")
+	}
+
+	database.InitDB()
+	user, err := keyOwner(*userId)
+	if err != nil {
+		return err
+	}
+	apiKey := models.ApiKey{Name: *name, Scopes: "*", UserId: uint(user.ID)}
+	key, err := IssueApiKey(&apiKey)
+	if err != nil {
+		return err
+	}
+	if err := database.DB.Create(&apiKey).Error; err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Issued admin key %d for user %d (%s). Store it safely, it is not shown again:\n%s\n", apiKey.ID, user.ID, user.Name, key)
+	return nil
+}
+
+// keyOwner finds the user with id, or the first user when id is 0. Without any users an
+// "admin" user is created, so a fresh install can be set up from the command line.
+func keyOwner(id uint) (models.User, error) {
+	var user models.User
+	if id != 0 {
+		if err := database.DB.First(&user, id).Error; err != nil {
+			return user, fmt.Errorf("user %d not found", id)
+		}
+		return user, nil
+	}
+	result := database.DB.Order("id").Limit(1).Find(&user)
+	if result.Error != nil || result.RowsAffected > 0 {
+		return user, result.Error
+	}
+	user = models.User{Name: "admin"}
+	return user, database.DB.Create(&user).Error
+}
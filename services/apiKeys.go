@@ -0,0 +1,89 @@
+package services
+
+import (
+	"api/models"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+)
+
+// Issued keys look like "rpi_<prefix>_<secret>". The prefix is stored in clear text so the
+// key can be found, the full key is only stored as a salted SHA-256 hash.
+const (
+	apiKeyTag         = "rpi"
+	apiKeyPrefixBytes = 4
+	apiKeySecretBytes = 32
+	apiKeySaltBytes   = 16
+)
+
+// IssueApiKey generates a new secret for apiKey, sets its Prefix, Salt and KeyHash
+// and returns the full key. The returned key cannot be recovered later.
+func IssueApiKey(apiKey *models.ApiKey) (string, error) {
+	prefix, err := randomHex(apiKeyPrefixBytes)
+	if err != nil {
+		return "", err
+	}
+	secret, err := randomHex(apiKeySecretBytes)
+	if err != nil {
+		return "", err
+	}
+	salt, err := randomHex(apiKeySaltBytes)
+	if err != nil {
+		return "", err
+	}
+	key := apiKeyTag + "_" + prefix + "_" + secret
+
+	apiKey.Prefix = prefix
+	apiKey.Salt = salt
+	apiKey.KeyHash = hashApiKey(key, salt)
+	return key, nil
+}
+
+// ApiKeyPrefix extracts the lookup prefix from a presented key.
+func ApiKeyPrefix(key string) (string, bool) {
+	parts := strings.Split(key, "_")
+	if len(parts) != 3 || parts[0] != apiKeyTag || parts[1] == "" || parts[2] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// VerifyApiKey reports whether key matches the stored hash of apiKey.
+func VerifyApiKey(key string, apiKey *models.ApiKey) bool {
+	if apiKey.KeyHash == "" {
+		return false
+	}
+	hash := hashApiKey(key, apiKey.Salt)
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(apiKey.KeyHash)) == 1
+}
+
+// ParseScopes splits the stored comma separated scope list.
+func ParseScopes(scopes string) []string {
+	out := []string{}
+	for _, scope := range strings.Split(scopes, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			out = append(out, scope)
+		}
+	}
+	return out
+}
+
+// JoinScopes is the inverse of ParseScopes.
+func JoinScopes(scopes []string) string {
+	return strings.Join(scopes, ",")
+}
+
+func hashApiKey(key, salt string) string {
+	sum := sha256.Sum256([]byte(salt + key))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"api/database"
+	"api/dtos"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const backupTimeFormat = "20060102T150405.000Z"
+
+var backupNamePattern = regexp.MustCompile(`^database-\d{8}T\d{6}\.\d{3}Z\.db\.gz$`)
+
+// ErrBackupNotFound is returned for a backup name that is not in the backup directory.
+var ErrBackupNotFound = errors.New("backup not found")
+
+// ErrInvalidSnapshot is returned by Restore for uploads that are not an intact SQLite database.
+var ErrInvalidSnapshot = errors.New("invalid database snapshot")
+
+// ErrSnapshotTooLarge is returned by Restore for snapshots larger than MaxRestoreSize once decompressed.
+var ErrSnapshotTooLarge = errors.New("database snapshot is too large")
+
+// BackupService writes gzipped snapshots of the SQLite database to Dir, keeping the newest Keep of them.
+type BackupService struct {
+	Dir  string
+	Keep int
+	// MaxRestoreSize is the most bytes a restored snapshot may have, after decompressing it
+	MaxRestoreSize int64
+
+	// mu makes sure only one backup or restore runs at a time
+	mu sync.Mutex
+}
+
+// NewBackupService writes to BACKUP_DIR (./backups by default), keeps BACKUP_KEEP snapshots (7 by default)
+// and restores snapshots of up to BACKUP_MAX_RESTORE_MB megabytes (1024 by default).
+func NewBackupService() *BackupService {
+	dir := os.Getenv("BACKUP_DIR")
+	if dir == "" {
+		dir = "./backups"
+	}
+	keep, err := strconv.Atoi(os.Getenv("BACKUP_KEEP"))
+	if err != nil || keep < 1 {
+		keep = 7
+	}
+	maxRestoreMB, err := strconv.ParseInt(os.Getenv("BACKUP_MAX_RESTORE_MB"), 10, 64)
+	if err != nil || maxRestoreMB < 1 {
+		maxRestoreMB = 1024
+	}
+	return &BackupService{Dir: dir, Keep: keep, MaxRestoreSize: maxRestoreMB << 20}
+}
+
+// Start takes a backup every interval until ctx is done. The first one is taken right away
+// when the newest backup is already older than interval, as a Pi may not stay up that long.
+func (s *BackupService) Start(ctx context.Context, interval time.Duration) {
+	if database.ActiveDialect.Name != "sqlite" {
+		log.Println("Scheduled backups are off,", database.ErrNotSQLite)
+		return
+	}
+	go func() {
+		wait := interval
+		if backups, err := s.List(); err == nil && (len(backups) == 0 || time.Since(backups[0].CreatedAt) >= interval) {
+			wait = 0
+		}
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+			if _, err := s.Backup(); err != nil {
+				log.Println("Scheduled backup failed:", err)
+			}
+			timer.Reset(interval)
+		}
+	}()
+}
+
+// Backup writes a new snapshot and removes the oldest ones beyond Keep.
+func (s *BackupService) Backup() (dtos.BackupInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.backup()
+}
+
+func (s *BackupService) backup() (dtos.BackupInfo, error) {
+	if err := os.MkdirAll(s.Dir, 0o700); err != nil {
+		return dtos.BackupInfo{}, err
+	}
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	name := "database-" + now.Format(backupTimeFormat) + ".db.gz"
+	snapshot := filepath.Join(s.Dir, "."+name+".db")
+	defer os.Remove(snapshot)
+	// VACUUM INTO refuses to overwrite, a snapshot left behind by a crash is in the way
+	os.Remove(snapshot)
+	if err := database.VacuumInto(snapshot); err != nil {
+		return dtos.BackupInfo{}, err
+	}
+	if err := gzipFile(snapshot, filepath.Join(s.Dir, name)); err != nil {
+		return dtos.BackupInfo{}, err
+	}
+	s.prune()
+
+	info, err := os.Stat(filepath.Join(s.Dir, name))
+	if err != nil {
+		return dtos.BackupInfo{}, err
+	}
+	log.Println("Database backed up to", filepath.Join(s.Dir, name))
+	return dtos.BackupInfo{Name: name, Size: info.Size(), CreatedAt: now}, nil
+}
+
+// gzipFile compresses source to target. target only appears once it is completely on disk.
+func gzipFile(source, target string) (err error) {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	partial := target + ".partial"
+	out, err := os.OpenFile(partial, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			out.Close()
+			os.Remove(partial)
+		}
+	}()
+	writer := gzip.NewWriter(out)
+	if _, err = io.Copy(writer, in); err != nil {
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	if err = out.Sync(); err != nil {
+		return err
+	}
+	if err = out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(partial, target)
+}
+
+// prune removes the oldest backups beyond Keep.
+func (s *BackupService) prune() {
+	backups, err := s.List()
+	if err != nil {
+		log.Println("Failed to list backups:", err)
+		return
+	}
+	for i := s.Keep; i < len(backups); i++ {
+		if err := os.Remove(filepath.Join(s.Dir, backups[i].Name)); err != nil {
+			log.Println("Failed to remove old backup:", err)
+		}
+	}
+}
+
+// List returns the backups, newest first.
+func (s *BackupService) List() ([]dtos.BackupInfo, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []dtos.BackupInfo{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	backups := []dtos.BackupInfo{}
+	for _, entry := range entries {
+		if entry.IsDir() || !backupNamePattern.MatchString(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		stamp := entry.Name()[len("database-") : len(entry.Name())-len(".db.gz")]
+		createdAt, err := time.Parse(backupTimeFormat, stamp)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, dtos.BackupInfo{Name: entry.Name(), Size: info.Size(), CreatedAt: createdAt})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].CreatedAt.After(backups[j].CreatedAt) })
+	return backups, nil
+}
+
+// Path returns the file of the backup called name. Only names of actual backups are accepted,
+// so a name can never point outside Dir.
+func (s *BackupService) Path(name string) (string, error) {
+	if !backupNamePattern.MatchString(name) {
+		return "", ErrBackupNotFound
+	}
+	path := filepath.Join(s.Dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", ErrBackupNotFound
+	}
+	return path, nil
+}
+
+// Restore replaces the database with the snapshot in upload, gzipped or not. The snapshot has
+// to pass an integrity check first, and the current database is backed up before it is replaced.
+// The change log goes back to the snapshot as well, so sync clients are told to pull everything
+// again, see ResetSync.
+func (s *BackupService) Restore(upload io.Reader) (dtos.BackupRestoreResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.Dir, 0o700); err != nil {
+		return dtos.BackupRestoreResult{}, err
+	}
+	snapshot, err := os.CreateTemp(s.Dir, ".restore-*.db")
+	if err != nil {
+		return dtos.BackupRestoreResult{}, err
+	}
+	defer os.Remove(snapshot.Name())
+	err = copySnapshot(snapshot, upload, s.MaxRestoreSize)
+	if closeErr := snapshot.Close(); err == nil {
+		err = closeErr
+	}
+	if errors.Is(err, ErrSnapshotTooLarge) {
+		return dtos.BackupRestoreResult{}, err
+	}
+	if err != nil {
+		return dtos.BackupRestoreResult{}, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	if err := database.CheckDatabaseFile(snapshot.Name()); err != nil {
+		return dtos.BackupRestoreResult{}, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+
+	safety, err := s.backup()
+	if err != nil {
+		return dtos.BackupRestoreResult{}, fmt.Errorf("backing up the current database: %w", err)
+	}
+	lastChange, err := LastChangeId()
+	if err != nil {
+		return dtos.BackupRestoreResult{}, err
+	}
+	if err := database.RestoreFrom(snapshot.Name()); err != nil {
+		return dtos.BackupRestoreResult{}, err
+	}
+	log.Println("Database restored, the previous one is in backup", safety.Name)
+	if err := ResetSync(lastChange); err != nil {
+		log.Println("Failed to tell sync clients about the restore:", err)
+	}
+	return dtos.BackupRestoreResult{RestoredAt: time.Now(), SafetyBackup: safety}, nil
+}
+
+// copySnapshot writes upload to file, decompressing it when it is gzipped. It stops with
+// ErrSnapshotTooLarge after maxSize bytes, as a small gzip can unpack to fill the disk.
+func copySnapshot(file *os.File, upload io.Reader, maxSize int64) error {
+	reader := bufio.NewReader(upload)
+	magic, _ := reader.Peek(2)
+	var source io.Reader = reader
+	if bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		unzipped, err := gzip.NewReader(reader)
+		if err != nil {
+			return err
+		}
+		defer unzipped.Close()
+		source = unzipped
+	}
+	written, err := io.Copy(file, io.LimitReader(source, maxSize+1))
+	if err != nil {
+		return err
+	}
+	if written > maxSize {
+		return ErrSnapshotTooLarge
+	}
+	return file.Sync()
+}
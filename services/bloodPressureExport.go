@@ -0,0 +1,71 @@
+package services
+
+import (
+	"api/models"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+var bloodPressureCSVHeader = []string{"id", "measuredAt", "systolic", "diastolic", "pulse", "medicine", "category"}
+
+// BloodPressureCSVWriter writes readings as CSV, starting with a header row.
+type BloodPressureCSVWriter struct {
+	writer *csv.Writer
+}
+
+func NewBloodPressureCSVWriter(w io.Writer) (*BloodPressureCSVWriter, error) {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(bloodPressureCSVHeader); err != nil {
+		return nil, err
+	}
+	return &BloodPressureCSVWriter{writer: writer}, nil
+}
+
+func (w *BloodPressureCSVWriter) Write(reading models.BloodPressure) error {
+	return w.writer.Write([]string{
+		strconv.Itoa(reading.ID),
+		BloodPressureReadingTime(reading).Format(time.RFC3339),
+		strconv.Itoa(reading.Systolic),
+		strconv.Itoa(reading.Diastolic),
+		strconv.Itoa(reading.Pulse),
+		reading.Medicine,
+		ClassifyBloodPressure(GuidelineAHA, reading.Systolic, reading.Diastolic),
+	})
+}
+
+func (w *BloodPressureCSVWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// BloodPressureJSONWriter writes readings as one JSON array without holding them all in memory.
+type BloodPressureJSONWriter struct {
+	w       io.Writer
+	encoder *json.Encoder
+	count   int
+}
+
+func NewBloodPressureJSONWriter(w io.Writer) (*BloodPressureJSONWriter, error) {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return nil, err
+	}
+	return &BloodPressureJSONWriter{w: w, encoder: json.NewEncoder(w)}, nil
+}
+
+func (w *BloodPressureJSONWriter) Write(reading models.BloodPressure) error {
+	if w.count > 0 {
+		if _, err := io.WriteString(w.w, ","); err != nil {
+			return err
+		}
+	}
+	w.count++
+	return w.encoder.Encode(reading)
+}
+
+func (w *BloodPressureJSONWriter) Flush() error {
+	_, err := io.WriteString(w.w, "]\n")
+	return err
+}
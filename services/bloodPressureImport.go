@@ -0,0 +1,210 @@
+package services
+
+import (
+	"api/dtos"
+	"api/models"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// BloodPressureImportOptions describes how to read a CSV export of another app.
+type BloodPressureImportOptions struct {
+	// Mapping maps reading fields (systolic, diastolic, pulse, medicine, measuredAt) to CSV column headers.
+	// Fields without a mapping are looked up by their own name.
+	Mapping map[string]string
+	// TimeFormat is a Go time layout for measuredAt. When empty a few common formats are tried.
+	TimeFormat string
+	Delimiter  rune
+	UserId     uint
+	// Strict rolls back the whole import when any row is invalid
+	Strict bool
+}
+
+var ErrInvalidImportRows = errors.New("import contains invalid rows")
+
+var importTimeFormats = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"02.01.2006 15:04",
+	"01/02/2006 15:04",
+}
+
+var importFields = []string{"systolic", "diastolic", "pulse", "medicine", "measuredAt"}
+
+// ImportBloodPressureCSV validates every row of r and creates the valid ones through tx.
+// Rows with a measuredAt the user already has a reading for, in the database or earlier in the file, are skipped.
+func ImportBloodPressureCSV(tx *gorm.DB, r io.Reader, options BloodPressureImportOptions) (dtos.BloodPressureImportReport, error) {
+	report := dtos.BloodPressureImportReport{Rows: []dtos.BloodPressureImportRow{}}
+
+	reader := csv.NewReader(r)
+	if options.Delimiter != 0 {
+		reader.Comma = options.Delimiter
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return report, fmt.Errorf("cannot read header: %w", err)
+	}
+	columns, err := importColumns(header, options.Mapping)
+	if err != nil {
+		return report, err
+	}
+
+	seen, err := existingMeasurementTimes(tx, options.UserId)
+	if err != nil {
+		return report, err
+	}
+
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return report, fmt.Errorf("row %d: %w", row, err)
+		}
+
+		reading, fieldErrors := parseImportRecord(record, columns, options.TimeFormat)
+		if fieldErrors == nil {
+			fieldErrors = ValidateBloodPressure(reading.Systolic, reading.Diastolic, reading.Pulse, reading.Medicine, reading.MeasuredAt)
+		}
+		if fieldErrors != nil {
+			report.Invalid++
+			report.Rows = append(report.Rows, dtos.BloodPressureImportRow{Row: row, Status: "invalid", Errors: fieldErrors})
+			continue
+		}
+
+		key := reading.MeasuredAt.Unix()
+		if seen[key] {
+			report.Duplicates++
+			report.Rows = append(report.Rows, dtos.BloodPressureImportRow{Row: row, Status: "duplicate"})
+			continue
+		}
+		seen[key] = true
+
+		reading.UserId = options.UserId
+		if err := tx.Create(&reading).Error; err != nil {
+			return report, fmt.Errorf("row %d: %w", row, err)
+		}
+		report.Imported++
+		report.Rows = append(report.Rows, dtos.BloodPressureImportRow{Row: row, Status: "imported", ID: reading.ID})
+	}
+
+	if options.Strict && report.Invalid > 0 {
+		return report, ErrInvalidImportRows
+	}
+	return report, nil
+}
+
+// importColumns resolves the index of every mapped field in the header.
+func importColumns(header []string, mapping map[string]string) (map[string]int, error) {
+	index := map[string]int{}
+	for i, name := range header {
+		index[strings.TrimSpace(strings.TrimPrefix(name, "\uFEFF"))] = i
+	}
+
+	columns := map[string]int{}
+	for field := range mapping {
+		if !isImportField(field) {
+			return nil, fmt.Errorf("unknown field %q in mapping", field)
+		}
+	}
+	for _, field := range importFields {
+		column, mapped := mapping[field]
+		if !mapped {
+			column = field
+		}
+		i, ok := index[column]
+		if !ok {
+			if mapped || field != "medicine" {
+				return nil, fmt.Errorf("column %q for %s not found in header", column, field)
+			}
+			continue
+		}
+		columns[field] = i
+	}
+	return columns, nil
+}
+
+func isImportField(field string) bool {
+	for _, known := range importFields {
+		if known == field {
+			return true
+		}
+	}
+	return false
+}
+
+func parseImportRecord(record []string, columns map[string]int, timeFormat string) (models.BloodPressure, map[string]string) {
+	fieldErrors := map[string]string{}
+	value := func(field string) string {
+		i, ok := columns[field]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	number := func(field string) int {
+		n, err := strconv.Atoi(value(field))
+		if err != nil {
+			fieldErrors[field] = "must be a whole number"
+		}
+		return n
+	}
+
+	reading := models.BloodPressure{
+		Systolic:  number("systolic"),
+		Diastolic: number("diastolic"),
+		Pulse:     number("pulse"),
+		Medicine:  value("medicine"),
+	}
+	measuredAt, err := parseImportTime(value("measuredAt"), timeFormat)
+	if err != nil {
+		fieldErrors["measuredAt"] = "unrecognised date/time"
+	}
+	reading.MeasuredAt = measuredAt
+
+	if len(fieldErrors) > 0 {
+		return reading, fieldErrors
+	}
+	return reading, nil
+}
+
+func parseImportTime(value, format string) (time.Time, error) {
+	if format != "" {
+		return time.ParseInLocation(format, value, time.Local)
+	}
+	for _, layout := range importTimeFormats {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised time %q", value)
+}
+
+func existingMeasurementTimes(tx *gorm.DB, userId uint) (map[int64]bool, error) {
+	var times []time.Time
+	err := tx.Model(&models.BloodPressure{}).
+		Where("user_id = ?", userId).
+		Pluck("measured_at", &times).Error
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[int64]bool, len(times))
+	for _, t := range times {
+		seen[t.Unix()] = true
+	}
+	return seen, nil
+}
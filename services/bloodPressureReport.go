@@ -0,0 +1,342 @@
+package services
+
+import (
+	"api/dtos"
+	"api/models"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// BloodPressureReport holds what goes into the printable report for a doctor visit.
+type BloodPressureReport struct {
+	PatientName string
+	From        *time.Time
+	To          *time.Time
+	Readings    []models.BloodPressure
+}
+
+// MedicationPeriod is a run of consecutive readings taken with the same medicine.
+type MedicationPeriod struct {
+	Medicine      string
+	From          time.Time
+	To            time.Time
+	Count         int
+	SystolicMean  float64
+	DiastolicMean float64
+}
+
+// Readings from 04:00 until noon count as morning, from 17:00 until midnight as evening.
+const (
+	morningStartHour = 4
+	morningEndHour   = 12
+	eveningStartHour = 17
+)
+
+const reportDateFormat = "2006-01-02"
+const reportDateTimeFormat = "2006-01-02 15:04"
+
+// IsHypertensiveCrisis reports whether a reading needs immediate medical attention.
+func IsHypertensiveCrisis(reading models.BloodPressure) bool {
+	return ClassifyBloodPressure(GuidelineAHA, reading.Systolic, reading.Diastolic) == "hypertensive_crisis"
+}
+
+// MedicationPeriods splits time sorted readings into periods of unchanged medicine.
+func MedicationPeriods(readings []models.BloodPressure) []MedicationPeriod {
+	periods := []MedicationPeriod{}
+	for _, reading := range readings {
+		at := BloodPressureReadingTime(reading)
+		if len(periods) == 0 || periods[len(periods)-1].Medicine != reading.Medicine {
+			periods = append(periods, MedicationPeriod{Medicine: reading.Medicine, From: at})
+		}
+		period := &periods[len(periods)-1]
+		period.To = at
+		period.SystolicMean = (period.SystolicMean*float64(period.Count) + float64(reading.Systolic)) / float64(period.Count+1)
+		period.DiastolicMean = (period.DiastolicMean*float64(period.Count) + float64(reading.Diastolic)) / float64(period.Count+1)
+		period.Count++
+	}
+	return periods
+}
+
+// WriteBloodPressureReport renders report as a PDF to w.
+func WriteBloodPressureReport(w io.Writer, report BloodPressureReport) error {
+	readings := make([]models.BloodPressure, len(report.Readings))
+	copy(readings, report.Readings)
+	sort.SliceStable(readings, func(i, j int) bool {
+		return BloodPressureReadingTime(readings[i]).Before(BloodPressureReadingTime(readings[j]))
+	})
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle("Blood pressure report", true)
+	pdf.SetCreator("Andreas API", true)
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 15)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.CellFormat(0, 5, fmt.Sprintf("Page %d/{nb}", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	pdf.AliasNbPages("")
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(0, 10, "Blood pressure report", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	if report.PatientName != "" {
+		pdf.CellFormat(0, 6, tr("Patient: "+report.PatientName), "", 1, "L", false, 0, "")
+	}
+	pdf.CellFormat(0, 6, "Period: "+reportPeriod(report, readings), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 6, "Generated: "+time.Now().Format(reportDateTimeFormat), "", 1, "L", false, 0, "")
+	pdf.Ln(4)
+
+	if len(readings) == 0 {
+		pdf.CellFormat(0, 8, "No readings in this period.", "", 1, "L", false, 0, "")
+		return pdf.Output(w)
+	}
+
+	stats := ComputeBloodPressureStats(readings, GuidelineAHA, 7)
+
+	reportHeading(pdf, "Summary")
+	summary := stats.Summary
+	reportTable(pdf, []string{"", "Min", "Max", "Mean", "Median"}, []float64{45, 30, 30, 30, 30}, [][]string{
+		{"Systolic (mmHg)", fmt.Sprint(summary.Systolic.Min), fmt.Sprint(summary.Systolic.Max), fmt.Sprintf("%.1f", summary.Systolic.Mean), fmt.Sprintf("%.1f", summary.Systolic.Median)},
+		{"Diastolic (mmHg)", fmt.Sprint(summary.Diastolic.Min), fmt.Sprint(summary.Diastolic.Max), fmt.Sprintf("%.1f", summary.Diastolic.Mean), fmt.Sprintf("%.1f", summary.Diastolic.Median)},
+		{"Pulse (bpm)", fmt.Sprint(summary.Pulse.Min), fmt.Sprint(summary.Pulse.Max), fmt.Sprintf("%.1f", summary.Pulse.Mean), fmt.Sprintf("%.1f", summary.Pulse.Median)},
+	})
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.CellFormat(0, 6, fmt.Sprintf("%d readings. Categories (AHA):", summary.Count), "", 1, "L", false, 0, "")
+	for _, category := range GuidelineCategories(GuidelineAHA) {
+		pdf.CellFormat(0, 5, fmt.Sprintf("    %s: %.0f%%", category, summary.Categories[category]*100), "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(3)
+
+	reportHeading(pdf, "Morning and evening averages")
+	rows := [][]string{}
+	for _, part := range []struct {
+		name     string
+		from, to int
+	}{{"Morning (04-12)", morningStartHour, morningEndHour}, {"Evening (17-24)", eveningStartHour, 24}} {
+		count, systolic, diastolic, pulse := averageBetweenHours(readings, part.from, part.to)
+		if count == 0 {
+			rows = append(rows, []string{part.name, "0", "-", "-", "-"})
+			continue
+		}
+		rows = append(rows, []string{part.name, fmt.Sprint(count), fmt.Sprintf("%.1f", systolic), fmt.Sprintf("%.1f", diastolic), fmt.Sprintf("%.1f", pulse)})
+	}
+	reportTable(pdf, []string{"", "Readings", "Systolic", "Diastolic", "Pulse"}, []float64{45, 30, 30, 30, 30}, rows)
+	pdf.Ln(3)
+
+	reportHeading(pdf, "Trend")
+	drawTrendChart(pdf, readings, stats.MovingAverage)
+	pdf.Ln(3)
+
+	reportHeading(pdf, "Medication periods")
+	rows = [][]string{}
+	for _, period := range MedicationPeriods(readings) {
+		medicine := period.Medicine
+		if medicine == "" {
+			medicine = "(none)"
+		}
+		rows = append(rows, []string{tr(medicine), period.From.Format(reportDateFormat), period.To.Format(reportDateFormat), fmt.Sprint(period.Count), fmt.Sprintf("%.0f/%.0f", period.SystolicMean, period.DiastolicMean)})
+	}
+	reportTable(pdf, []string{"Medicine", "From", "To", "Readings", "Mean"}, []float64{55, 30, 30, 25, 30}, rows)
+	pdf.Ln(3)
+
+	reportHeading(pdf, "Hypertensive crisis readings (>180 systolic or >120 diastolic)")
+	rows = [][]string{}
+	for _, reading := range readings {
+		if IsHypertensiveCrisis(reading) {
+			rows = append(rows, reportReadingRow(reading, tr))
+		}
+	}
+	if len(rows) == 0 {
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.CellFormat(0, 6, "None in this period.", "", 1, "L", false, 0, "")
+	} else {
+		pdf.SetTextColor(180, 0, 0)
+		reportTable(pdf, []string{"Measured", "Systolic", "Diastolic", "Pulse", "Medicine"}, []float64{40, 25, 25, 25, 55}, rows)
+		pdf.SetTextColor(0, 0, 0)
+	}
+	pdf.Ln(3)
+
+	reportHeading(pdf, "All readings")
+	rows = [][]string{}
+	for _, reading := range readings {
+		rows = append(rows, reportReadingRow(reading, tr))
+	}
+	reportTable(pdf, []string{"Measured", "Systolic", "Diastolic", "Pulse", "Medicine"}, []float64{40, 25, 25, 25, 55}, rows)
+
+	return pdf.Output(w)
+}
+
+func reportPeriod(report BloodPressureReport, readings []models.BloodPressure) string {
+	from, to := "", ""
+	if report.From != nil {
+		from = report.From.Format(reportDateFormat)
+	} else if len(readings) > 0 {
+		from = BloodPressureReadingTime(readings[0]).Format(reportDateFormat)
+	}
+	if report.To != nil {
+		to = report.To.Format(reportDateFormat)
+	} else if len(readings) > 0 {
+		to = BloodPressureReadingTime(readings[len(readings)-1]).Format(reportDateFormat)
+	}
+	if from == "" && to == "" {
+		return "all readings"
+	}
+	return from + " to " + to
+}
+
+func reportReadingRow(reading models.BloodPressure, tr func(string) string) []string {
+	pulse := "-"
+	if reading.Pulse > 0 {
+		pulse = fmt.Sprint(reading.Pulse)
+	}
+	return []string{
+		BloodPressureReadingTime(reading).Local().Format(reportDateTimeFormat),
+		fmt.Sprint(reading.Systolic),
+		fmt.Sprint(reading.Diastolic),
+		pulse,
+		tr(reading.Medicine),
+	}
+}
+
+// averageBetweenHours averages the readings taken from fromHour (inclusive) to toHour (exclusive), local time.
+func averageBetweenHours(readings []models.BloodPressure, fromHour, toHour int) (count int, systolic, diastolic, pulse float64) {
+	pulseCount := 0
+	for _, reading := range readings {
+		hour := BloodPressureReadingTime(reading).Local().Hour()
+		if hour < fromHour || hour >= toHour {
+			continue
+		}
+		count++
+		systolic += float64(reading.Systolic)
+		diastolic += float64(reading.Diastolic)
+		if reading.Pulse > 0 {
+			pulse += float64(reading.Pulse)
+			pulseCount++
+		}
+	}
+	if count > 0 {
+		systolic /= float64(count)
+		diastolic /= float64(count)
+	}
+	if pulseCount > 0 {
+		pulse /= float64(pulseCount)
+	}
+	return count, systolic, diastolic, pulse
+}
+
+func reportHeading(pdf *fpdf.Fpdf, title string) {
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.CellFormat(0, 8, title, "", 1, "L", false, 0, "")
+}
+
+func reportTable(pdf *fpdf.Fpdf, header []string, widths []float64, rows [][]string) {
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.SetFillColor(230, 230, 230)
+	for i, title := range header {
+		pdf.CellFormat(widths[i], 6, title, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetFont("Helvetica", "", 9)
+	for _, row := range rows {
+		for i, value := range row {
+			align := "C"
+			if i == 0 || i == len(row)-1 && header[i] == "Medicine" {
+				align = "L"
+			}
+			pdf.CellFormat(widths[i], 5.5, value, "1", 0, align, false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+}
+
+// drawTrendChart plots systolic and diastolic readings with their moving averages and the 140/90 limits.
+func drawTrendChart(pdf *fpdf.Fpdf, readings []models.BloodPressure, movingAverage []dtos.MovingAveragePoint) {
+	const width, height = 170.0, 70.0
+	if _, pageHeight := pdf.GetPageSize(); pdf.GetY()+height+15 > pageHeight-15 {
+		pdf.AddPage()
+	}
+	left, top := pdf.GetX()+10, pdf.GetY()
+	chartWidth := width - 10
+
+	minValue, maxValue := 40.0, 200.0
+	for _, reading := range readings {
+		minValue = math.Min(minValue, float64(reading.Diastolic)-10)
+		maxValue = math.Max(maxValue, float64(reading.Systolic)+10)
+	}
+	first := BloodPressureReadingTime(readings[0])
+	span := BloodPressureReadingTime(readings[len(readings)-1]).Sub(first).Seconds()
+	x := func(t time.Time) float64 {
+		if span == 0 {
+			return left + chartWidth/2
+		}
+		return left + t.Sub(first).Seconds()/span*chartWidth
+	}
+	y := func(value float64) float64 {
+		return top + height - (value-minValue)/(maxValue-minValue)*height
+	}
+
+	// Axes and grid
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.SetLineWidth(0.2)
+	pdf.Rect(left, top, chartWidth, height, "D")
+	pdf.SetFont("Helvetica", "", 7)
+	pdf.SetDrawColor(220, 220, 220)
+	for value := math.Ceil(minValue/20) * 20; value <= maxValue; value += 20 {
+		pdf.Line(left, y(value), left+chartWidth, y(value))
+		pdf.Text(left-8, y(value)+1, fmt.Sprint(value))
+	}
+	pdf.Text(left, top+height+4, first.Local().Format(reportDateFormat))
+	last := BloodPressureReadingTime(readings[len(readings)-1]).Local().Format(reportDateFormat)
+	pdf.Text(left+chartWidth-pdf.GetStringWidth(last), top+height+4, last)
+
+	// Hypertension limits
+	pdf.SetDrawColor(200, 120, 0)
+	pdf.SetDashPattern([]float64{1, 1}, 0)
+	pdf.Line(left, y(140), left+chartWidth, y(140))
+	pdf.Line(left, y(90), left+chartWidth, y(90))
+	pdf.SetDashPattern([]float64{}, 0)
+
+	plot := func(r, g, b int, lineWidth float64, points []fpdf.PointType) {
+		pdf.SetDrawColor(r, g, b)
+		pdf.SetLineWidth(lineWidth)
+		for i := 1; i < len(points); i++ {
+			pdf.Line(points[i-1].X, points[i-1].Y, points[i].X, points[i].Y)
+		}
+		for _, p := range points {
+			pdf.Circle(p.X, p.Y, 0.4, "D")
+		}
+	}
+	systolic := make([]fpdf.PointType, 0, len(readings))
+	diastolic := make([]fpdf.PointType, 0, len(readings))
+	for _, reading := range readings {
+		at := BloodPressureReadingTime(reading)
+		systolic = append(systolic, fpdf.PointType{X: x(at), Y: y(float64(reading.Systolic))})
+		diastolic = append(diastolic, fpdf.PointType{X: x(at), Y: y(float64(reading.Diastolic))})
+	}
+	plot(230, 150, 150, 0.2, systolic)
+	plot(150, 150, 230, 0.2, diastolic)
+
+	systolicAverage := make([]fpdf.PointType, 0, len(movingAverage))
+	diastolicAverage := make([]fpdf.PointType, 0, len(movingAverage))
+	for _, point := range movingAverage {
+		systolicAverage = append(systolicAverage, fpdf.PointType{X: x(point.Timestamp), Y: y(point.Systolic)})
+		diastolicAverage = append(diastolicAverage, fpdf.PointType{X: x(point.Timestamp), Y: y(point.Diastolic)})
+	}
+	plot(200, 0, 0, 0.6, systolicAverage)
+	plot(0, 0, 200, 0.6, diastolicAverage)
+
+	pdf.SetLineWidth(0.2)
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.SetXY(left, top+height+6)
+	pdf.SetFont("Helvetica", "", 8)
+	pdf.CellFormat(0, 4, "Red: systolic, blue: diastolic. Thick lines are 7 reading moving averages, dashed lines the 140/90 limits.", "", 1, "L", false, 0, "")
+	pdf.SetX(left - 10)
+}
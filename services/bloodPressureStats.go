@@ -0,0 +1,210 @@
+package services
+
+import (
+	"api/dtos"
+	"api/models"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Blood pressure guidelines supported for categorisation.
+const (
+	GuidelineAHA = "aha"
+	GuidelineESC = "esc"
+)
+
+// ClassifyBloodPressure returns the category of a reading. When systolic and diastolic
+// fall in different categories the higher one wins, as both guidelines prescribe.
+func ClassifyBloodPressure(guideline string, systolic, diastolic int) string {
+	if guideline == GuidelineESC {
+		switch {
+		case systolic >= 180 || diastolic >= 110:
+			return "grade_3_hypertension"
+		case systolic >= 160 || diastolic >= 100:
+			return "grade_2_hypertension"
+		case systolic >= 140 || diastolic >= 90:
+			return "grade_1_hypertension"
+		case systolic >= 130 || diastolic >= 85:
+			return "high_normal"
+		case systolic >= 120 || diastolic >= 80:
+			return "normal"
+		default:
+			return "optimal"
+		}
+	}
+	switch {
+	case systolic > 180 || diastolic > 120:
+		return "hypertensive_crisis"
+	case systolic >= 140 || diastolic >= 90:
+		return "hypertension_stage_2"
+	case systolic >= 130 || diastolic >= 80:
+		return "hypertension_stage_1"
+	case systolic >= 120:
+		return "elevated"
+	default:
+		return "normal"
+	}
+}
+
+// GuidelineCategories lists the categories of a guideline from lowest to highest.
+func GuidelineCategories(guideline string) []string {
+	if guideline == GuidelineESC {
+		return []string{"optimal", "normal", "high_normal", "grade_1_hypertension", "grade_2_hypertension", "grade_3_hypertension"}
+	}
+	return []string{"normal", "elevated", "hypertension_stage_1", "hypertension_stage_2", "hypertensive_crisis"}
+}
+
+// BloodPressureReadingTime is the point in time a reading was taken.
+func BloodPressureReadingTime(reading models.BloodPressure) time.Time {
+	if reading.MeasuredAt.IsZero() {
+		return reading.CreatedAt
+	}
+	return reading.MeasuredAt
+}
+
+// ComputeBloodPressureStats summarises readings. window is the number of readings in the moving average.
+func ComputeBloodPressureStats(readings []models.BloodPressure, guideline string, window int) dtos.BloodPressureStats {
+	sorted := make([]models.BloodPressure, len(readings))
+	copy(sorted, readings)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return BloodPressureReadingTime(sorted[i]).Before(BloodPressureReadingTime(sorted[j]))
+	})
+
+	return dtos.BloodPressureStats{
+		Summary: summarizeBloodPressure(sorted, guideline),
+		Weekly: bucketBloodPressure(sorted, func(t time.Time) (string, time.Time) {
+			year, week := t.ISOWeek()
+			day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+			weekday := (int(day.Weekday()) + 6) % 7 // Monday is the first day of an ISO week
+			return fmt.Sprintf("%d-W%02d", year, week), day.AddDate(0, 0, -weekday)
+		}),
+		Monthly: bucketBloodPressure(sorted, func(t time.Time) (string, time.Time) {
+			return t.Format("2006-01"), time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+		}),
+		MovingAverage: movingAverage(sorted, window),
+	}
+}
+
+func summarizeBloodPressure(readings []models.BloodPressure, guideline string) dtos.BloodPressureSummary {
+	systolic := make([]int, 0, len(readings))
+	diastolic := make([]int, 0, len(readings))
+	pulse := make([]int, 0, len(readings))
+	counts := map[string]int{}
+	for _, reading := range readings {
+		systolic = append(systolic, reading.Systolic)
+		diastolic = append(diastolic, reading.Diastolic)
+		// A pulse of 0 means it was not recorded
+		if reading.Pulse > 0 {
+			pulse = append(pulse, reading.Pulse)
+		}
+		counts[ClassifyBloodPressure(guideline, reading.Systolic, reading.Diastolic)]++
+	}
+
+	categories := map[string]float64{}
+	for _, category := range GuidelineCategories(guideline) {
+		categories[category] = 0
+		if len(readings) > 0 {
+			categories[category] = float64(counts[category]) / float64(len(readings))
+		}
+	}
+
+	return dtos.BloodPressureSummary{
+		Count:      len(readings),
+		Systolic:   measurementStats(systolic),
+		Diastolic:  measurementStats(diastolic),
+		Pulse:      measurementStats(pulse),
+		Categories: categories,
+	}
+}
+
+func measurementStats(values []int) dtos.MeasurementStats {
+	if len(values) == 0 {
+		return dtos.MeasurementStats{}
+	}
+	sorted := make([]int, len(values))
+	copy(sorted, values)
+	sort.Ints(sorted)
+
+	sum := 0
+	for _, v := range sorted {
+		sum += v
+	}
+	median := float64(sorted[len(sorted)/2])
+	if len(sorted)%2 == 0 {
+		median = float64(sorted[len(sorted)/2-1]+sorted[len(sorted)/2]) / 2
+	}
+	return dtos.MeasurementStats{
+		Min:    sorted[0],
+		Max:    sorted[len(sorted)-1],
+		Mean:   float64(sum) / float64(len(sorted)),
+		Median: median,
+	}
+}
+
+// bucketBloodPressure groups time sorted readings by the period returned from key.
+func bucketBloodPressure(readings []models.BloodPressure, key func(time.Time) (string, time.Time)) []dtos.BloodPressureBucket {
+	buckets := []dtos.BloodPressureBucket{}
+	var systolic, diastolic, pulse, pulseCount int
+	flush := func() {
+		last := &buckets[len(buckets)-1]
+		last.SystolicMean = float64(systolic) / float64(last.Count)
+		last.DiastolicMean = float64(diastolic) / float64(last.Count)
+		if pulseCount > 0 {
+			last.PulseMean = float64(pulse) / float64(pulseCount)
+		}
+		systolic, diastolic, pulse, pulseCount = 0, 0, 0, 0
+	}
+
+	for _, reading := range readings {
+		period, start := key(BloodPressureReadingTime(reading))
+		if len(buckets) == 0 || buckets[len(buckets)-1].Period != period {
+			if len(buckets) > 0 {
+				flush()
+			}
+			buckets = append(buckets, dtos.BloodPressureBucket{Period: period, Start: start})
+		}
+		buckets[len(buckets)-1].Count++
+		systolic += reading.Systolic
+		diastolic += reading.Diastolic
+		if reading.Pulse > 0 {
+			pulse += reading.Pulse
+			pulseCount++
+		}
+	}
+	if len(buckets) > 0 {
+		flush()
+	}
+	return buckets
+}
+
+// movingAverage returns, for every reading, the mean of it and up to window-1 preceding readings.
+func movingAverage(readings []models.BloodPressure, window int) []dtos.MovingAveragePoint {
+	if window < 1 {
+		window = 1
+	}
+	points := make([]dtos.MovingAveragePoint, 0, len(readings))
+	for i := range readings {
+		start := max(0, i-window+1)
+		var systolic, diastolic, pulse, pulseCount int
+		for _, reading := range readings[start : i+1] {
+			systolic += reading.Systolic
+			diastolic += reading.Diastolic
+			if reading.Pulse > 0 {
+				pulse += reading.Pulse
+				pulseCount++
+			}
+		}
+		n := float64(i + 1 - start)
+		point := dtos.MovingAveragePoint{
+			Timestamp: BloodPressureReadingTime(readings[i]),
+			Systolic:  float64(systolic) / n,
+			Diastolic: float64(diastolic) / n,
+		}
+		if pulseCount > 0 {
+			point.Pulse = float64(pulse) / float64(pulseCount)
+		}
+		points = append(points, point)
+	}
+	return points
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"fmt"
+	"time"
+)
+
+// Physiologically plausible ranges. Anything outside is almost certainly a typo.
+const (
+	minSystolic  = 60
+	maxSystolic  = 260
+	minDiastolic = 30
+	maxDiastolic = 160
+	minPulse     = 25
+	maxPulse     = 250
+	maxMedicine  = 200
+	// measuredAtSkew allows for clocks of phones and cuffs running a bit ahead
+	measuredAtSkew = 5 * time.Minute
+)
+
+// ValidateBloodPressure checks a reading and returns a message per invalid field, or nil when it is valid.
+func ValidateBloodPressure(systolic, diastolic, pulse int, medicine string, measuredAt time.Time) map[string]string {
+	fields := map[string]string{}
+	if systolic < minSystolic || systolic > maxSystolic {
+		fields["systolic"] = fmt.Sprintf("must be between %d and %d", minSystolic, maxSystolic)
+	}
+	if diastolic < minDiastolic || diastolic > maxDiastolic {
+		fields["diastolic"] = fmt.Sprintf("must be between %d and %d", minDiastolic, maxDiastolic)
+	}
+	if _, ok := fields["systolic"]; !ok && fields["diastolic"] == "" && systolic <= diastolic {
+		fields["systolic"] = "must be greater than diastolic"
+	}
+	if pulse == 0 {
+		fields["pulse"] = "is required"
+	} else if pulse < minPulse || pulse > maxPulse {
+		fields["pulse"] = fmt.Sprintf("must be between %d and %d", minPulse, maxPulse)
+	}
+	if len(medicine) > maxMedicine {
+		fields["medicine"] = fmt.Sprintf("must be at most %d characters", maxMedicine)
+	}
+	if measuredAt.After(time.Now().Add(measuredAtSkew)) {
+		fields["measuredAt"] = "must not be in the future"
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
@@ -0,0 +1,269 @@
+package services
+
+import (
+	"api/database"
+	"api/dtos"
+	"api/models"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+	"gorm.io/gorm"
+)
+
+const (
+	maxCategoryTitle = 100
+	maxCategorySlug  = 100
+	maxCategoryIcon  = 64
+)
+
+var (
+	categorySlugFormat  = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	categoryColorFormat = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+	nonSlugCharacters   = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+var (
+	// ErrCategoryInUse is returned when deleting a category that still has items or subcategories
+	// without saying where they should go.
+	ErrCategoryInUse = errors.New("category has market items or subcategories")
+	// ErrInvalidReassignment is returned when the items and subcategories of a deleted category would
+	// move to a category that does not exist, or to the category itself or one below it.
+	ErrInvalidReassignment = errors.New("items and subcategories can only move to another existing category outside the deleted one")
+)
+
+// Slugify turns a title into a slug: lower case letters and digits separated by single dashes.
+// Accents are dropped, so "Möbel" becomes "mobel".
+func Slugify(title string) string {
+	var plain strings.Builder
+	for _, r := range norm.NFD.String(strings.ToLower(title)) {
+		if !unicode.Is(unicode.Mn, r) {
+			plain.WriteRune(r)
+		}
+	}
+	slug := strings.Trim(nonSlugCharacters.ReplaceAllString(plain.String(), "-"), "-")
+	// Room for a number within maxCategorySlug, see uniqueCategorySlug
+	if len(slug) > maxCategorySlug-10 {
+		slug = strings.TrimRight(slug[:maxCategorySlug-10], "-")
+	}
+	if slug == "" {
+		return "category"
+	}
+	return slug
+}
+
+// ValidateCategory checks a new or edited category and returns a message per invalid field, or nil
+// when it is valid. A category without a slug gets one made from its title.
+func ValidateCategory(category *models.Category) (map[string]string, error) {
+	fields := map[string]string{}
+	if strings.TrimSpace(category.Title) == "" {
+		fields["title"] = "is required"
+	} else if len(category.Title) > maxCategoryTitle {
+		fields["title"] = fmt.Sprintf("must be at most %d characters", maxCategoryTitle)
+	}
+	if category.Slug == "" && fields["title"] == "" {
+		slug, err := uniqueCategorySlug(Slugify(category.Title), category.ID)
+		if err != nil {
+			return nil, err
+		}
+		category.Slug = slug
+	} else if len(category.Slug) > maxCategorySlug || !categorySlugFormat.MatchString(category.Slug) {
+		fields["slug"] = fmt.Sprintf("must be at most %d lower case letters, digits and single dashes between them", maxCategorySlug)
+	} else if taken, err := categorySlugTaken(category.Slug, category.ID); err != nil {
+		return nil, err
+	} else if taken {
+		fields["slug"] = "is already taken"
+	}
+	if len(category.Icon) > maxCategoryIcon {
+		fields["icon"] = fmt.Sprintf("must be at most %d characters", maxCategoryIcon)
+	}
+	if category.Color != "" && !categoryColorFormat.MatchString(category.Color) {
+		fields["color"] = "must be a hex colour like #a0522d"
+	}
+	if category.ParentId != nil {
+		parents, err := categoryParents()
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := parents[*category.ParentId]; !exists {
+			fields["parentId"] = "does not exist"
+		} else if category.ID != 0 && isCategoryWithin(parents, *category.ParentId, uint(category.ID)) {
+			fields["parentId"] = "must not be the category itself or one below it"
+		}
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return fields, nil
+}
+
+func categorySlugTaken(slug string, exceptId int) (bool, error) {
+	var count int64
+	err := database.DB.Model(&models.Category{}).Where("slug = ? AND id <> ?", slug, exceptId).Count(&count).Error
+	return count > 0, err
+}
+
+// uniqueCategorySlug returns base, or base with the first number that makes it unique.
+func uniqueCategorySlug(base string, exceptId int) (string, error) {
+	slug := base
+	for n := 2; ; n++ {
+		taken, err := categorySlugTaken(slug, exceptId)
+		if err != nil || !taken {
+			return slug, err
+		}
+		slug = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// categoryParents maps the id of every category to the id of its parent, 0 at the top.
+func categoryParents() (map[uint]uint, error) {
+	var categories []models.Category
+	if err := database.DB.Select("id", "parent_id").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	parents := make(map[uint]uint, len(categories))
+	for _, category := range categories {
+		parents[uint(category.ID)] = 0
+		if category.ParentId != nil {
+			parents[uint(category.ID)] = *category.ParentId
+		}
+	}
+	return parents, nil
+}
+
+// isCategoryWithin reports whether id is ancestor or one of the categories below it.
+func isCategoryWithin(parents map[uint]uint, id, ancestor uint) bool {
+	seen := map[uint]bool{}
+	for id != 0 && !seen[id] {
+		if id == ancestor {
+			return true
+		}
+		seen[id] = true
+		id = parents[id]
+	}
+	return false
+}
+
+// CategoriesWithin returns ids together with the ids of all categories below them.
+func CategoriesWithin(ids []uint) ([]uint, error) {
+	parents, err := categoryParents()
+	if err != nil {
+		return nil, err
+	}
+	within := append([]uint{}, ids...)
+	for id := range parents {
+		for _, ancestor := range ids {
+			if id != ancestor && isCategoryWithin(parents, id, ancestor) {
+				within = append(within, id)
+				break
+			}
+		}
+	}
+	return within, nil
+}
+
+// CategoryUsage counts the market items in a category and the categories right below it.
+func CategoryUsage(id int) (items int64, subcategories int64, err error) {
+	if err = database.DB.Model(&models.MarketItem{}).Where("category_id = ?", id).Count(&items).Error; err != nil {
+		return
+	}
+	err = database.DB.Model(&models.Category{}).Where("parent_id = ?", id).Count(&subcategories).Error
+	return
+}
+
+// DeleteCategory moves category to the trash. A category that still has items or subcategories is
+// only deleted when reassignTo says where they go: another category, or 0 to leave the items
+// without a category and move the subcategories to the top.
+func DeleteCategory(ctx context.Context, category *models.Category, reassignTo *uint) error {
+	if reassignTo == nil {
+		items, subcategories, err := CategoryUsage(category.ID)
+		if err != nil {
+			return err
+		}
+		if items > 0 || subcategories > 0 {
+			return ErrCategoryInUse
+		}
+		return database.DB.WithContext(ctx).Delete(category).Error
+	}
+
+	// Items without a category have 0, categories at the top have no parent
+	var item, parent any = *reassignTo, *reassignTo
+	if *reassignTo == 0 {
+		parent = nil
+	} else {
+		parents, err := categoryParents()
+		if err != nil {
+			return err
+		}
+		if _, exists := parents[*reassignTo]; !exists || isCategoryWithin(parents, *reassignTo, uint(category.ID)) {
+			return ErrInvalidReassignment
+		}
+	}
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Items in the trash move too, so they don't come back into a deleted category when restored
+		err := tx.Unscoped().Model(&models.MarketItem{}).Where("category_id = ?", category.ID).Update("category_id", item).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&models.Category{}).Where("parent_id = ?", category.ID).Update("parent_id", parent).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(category).Error
+	})
+}
+
+// CategoryTree returns the categories nested under their parents, ordered by title, with the
+// active market items counted per category.
+func CategoryTree() ([]dtos.CategoryTreeNode, error) {
+	var categories []models.Category
+	if err := database.DB.Order("title, id").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	var counts []struct {
+		CategoryId uint
+		Count      int64
+	}
+	err := database.DB.Model(&models.MarketItem{}).Select("category_id, COUNT(*) AS count").
+		Where("status = ?", MarketItemActive).Group("category_id").Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+	itemCounts := make(map[uint]int64, len(counts))
+	for _, count := range counts {
+		itemCounts[count.CategoryId] = count.Count
+	}
+
+	exists := make(map[uint]bool, len(categories))
+	for _, category := range categories {
+		exists[uint(category.ID)] = true
+	}
+	children := map[uint][]models.Category{}
+	for _, category := range categories {
+		// A category whose parent is in the trash shows at the top until it is restored
+		parent := uint(0)
+		if category.ParentId != nil && exists[*category.ParentId] {
+			parent = *category.ParentId
+		}
+		children[parent] = append(children[parent], category)
+	}
+	var build func(parent uint) []dtos.CategoryTreeNode
+	build = func(parent uint) []dtos.CategoryTreeNode {
+		nodes := make([]dtos.CategoryTreeNode, 0, len(children[parent]))
+		for _, category := range children[parent] {
+			node := dtos.CategoryTreeNode{Category: category, ItemCount: itemCounts[uint(category.ID)]}
+			node.Children = build(uint(category.ID))
+			node.TotalItemCount = node.ItemCount
+			for _, child := range node.Children {
+				node.TotalItemCount += child.TotalItemCount
+			}
+			nodes = append(nodes, node)
+		}
+		return nodes
+	}
+	return build(0), nil
+}
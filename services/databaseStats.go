@@ -0,0 +1,89 @@
+package services
+
+import (
+	"api/database"
+	"api/dtos"
+	"sort"
+	"strings"
+)
+
+// TableSizes counts the rows of every table. It also reports the bytes each table takes and
+// the size of the whole database, as far as the database tells; SQLite only knows table
+// sizes when the dbstat table is compiled in.
+func TableSizes() ([]dtos.TableSize, *int64, error) {
+	tables, err := database.DB.Migrator().GetTables()
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(tables)
+
+	bytesByTable, databaseBytes := database.TableBytes()
+
+	sizes := make([]dtos.TableSize, 0, len(tables))
+	for _, table := range tables {
+		if strings.HasPrefix(table, "sqlite_") {
+			continue
+		}
+		size := dtos.TableSize{Name: table}
+		if err := database.DB.Table(table).Count(&size.Rows).Error; err != nil {
+			return nil, nil, err
+		}
+		if bytes, ok := bytesByTable[table]; ok {
+			size.Bytes = &bytes
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, databaseBytes, nil
+}
+
+// DatabaseStats reports the connection pool, the recent slow queries and, on SQLite, the
+// pages, write-ahead log and pragmas of the database file.
+func DatabaseStats() (dtos.DatabaseStatsResponse, error) {
+	sqlDB, err := database.DB.DB()
+	if err != nil {
+		return dtos.DatabaseStatsResponse{}, err
+	}
+	pool := sqlDB.Stats()
+	queries, total := database.SlowQueries()
+	stats := dtos.DatabaseStatsResponse{
+		Driver: database.ActiveDialect.Name,
+		Pool: dtos.DatabasePool{
+			MaxOpen:   pool.MaxOpenConnections,
+			Open:      pool.OpenConnections,
+			InUse:     pool.InUse,
+			Idle:      pool.Idle,
+			WaitCount: pool.WaitCount,
+			WaitMs:    pool.WaitDuration.Milliseconds(),
+		},
+		SlowQueryThresholdMs: database.ActiveConnectionConfig.SlowQuery.Milliseconds(),
+		SlowQueryCount:       total,
+		SlowQueries:          make([]dtos.SlowQuery, 0, len(queries)),
+	}
+	for _, query := range queries {
+		stats.SlowQueries = append(stats.SlowQueries, dtos.SlowQuery{
+			SQL:        query.SQL,
+			DurationMs: float64(query.Duration.Microseconds()) / 1000,
+			Rows:       query.Rows,
+			Error:      query.Error,
+			At:         query.At,
+		})
+	}
+
+	storage, err := database.Storage()
+	if err != nil {
+		return dtos.DatabaseStatsResponse{}, err
+	}
+	if storage != nil {
+		stats.Storage = &dtos.DatabaseStorage{
+			PageCount:     storage.PageCount,
+			PageSize:      storage.PageSize,
+			FreelistCount: storage.FreelistCount,
+			DatabaseBytes: storage.PageCount * storage.PageSize,
+			WalBytes:      storage.WalBytes,
+			JournalMode:   storage.JournalMode,
+			Synchronous:   storage.Synchronous,
+			BusyTimeoutMs: storage.BusyTimeout.Milliseconds(),
+		}
+	}
+	return stats, nil
+}
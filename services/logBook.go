@@ -0,0 +1,42 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// LogLevels are the accepted LogBookEntry levels, from least to most severe.
+var LogLevels = []string{"debug", "info", "warn", "error"}
+
+const (
+	maxLogMessage  = 10000
+	maxLogCategory = 100
+)
+
+// ValidateLogBookEntry returns a message per invalid field, or nil when the entry is valid.
+func ValidateLogBookEntry(message, level, category string) map[string]string {
+	fields := map[string]string{}
+	if strings.TrimSpace(message) == "" {
+		fields["message"] = "is required"
+	} else if len(message) > maxLogMessage {
+		fields["message"] = fmt.Sprintf("must be at most %d characters", maxLogMessage)
+	}
+	if !slices.Contains(LogLevels, level) {
+		fields["level"] = "must be one of " + strings.Join(LogLevels, ", ")
+	}
+	if len(category) > maxLogCategory {
+		fields["category"] = fmt.Sprintf("must be at most %d characters", maxLogCategory)
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// LikePattern turns a search term into a LIKE pattern matching it anywhere, with the
+// LIKE wildcards in the term escaped by a backslash.
+func LikePattern(term string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(term)
+	return "%" + escaped + "%"
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"api/models"
+	"reflect"
+	"slices"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// logBookSubscriberBuffer is how many entries a subscriber may fall behind before it is
+// dropped. A dropped client reconnects with Last-Event-ID and catches up from the database.
+const logBookSubscriberBuffer = 256
+
+// LogBookFilter selects the entries of a stream. Empty fields match everything.
+type LogBookFilter struct {
+	Levels     []string
+	Categories []string
+	// OwnerId limits the stream to the entries of one user and system entries, 0 for all
+	OwnerId uint
+}
+
+// Matches reports whether entry passes the filter.
+func (f LogBookFilter) Matches(entry models.LogBookEntry) bool {
+	if len(f.Levels) > 0 && !slices.Contains(f.Levels, entry.Level) {
+		return false
+	}
+	if len(f.Categories) > 0 && !slices.Contains(f.Categories, entry.Category) {
+		return false
+	}
+	return f.OwnerId == 0 || entry.UserId == 0 || entry.UserId == f.OwnerId
+}
+
+// LogBookStream fans newly created LogBookEntries out to live subscribers.
+type LogBookStream struct {
+	mu          sync.Mutex
+	subscribers map[*logBookSubscriber]struct{}
+}
+
+type logBookSubscriber struct {
+	filter  LogBookFilter
+	entries chan models.LogBookEntry
+}
+
+func NewLogBookStream() *LogBookStream {
+	return &LogBookStream{subscribers: map[*logBookSubscriber]struct{}{}}
+}
+
+// Register publishes every LogBookEntry created through db, wherever it is created.
+func (s *LogBookStream) Register(db *gorm.DB) error {
+	return db.Callback().Create().After("gorm:create").Register("publish_log_book_entries", func(db *gorm.DB) {
+		if db.Error != nil || db.Statement.Schema == nil || db.Statement.Schema.Name != "LogBookEntry" {
+			return
+		}
+		s.Publish(createdLogBookEntries(db.Statement.ReflectValue)...)
+	})
+}
+
+// createdLogBookEntries returns the entries of a Create, which may be a single entry or a batch.
+func createdLogBookEntries(value reflect.Value) []models.LogBookEntry {
+	var entries []models.LogBookEntry
+	add := func(v reflect.Value) {
+		for v.Kind() == reflect.Pointer && !v.IsNil() {
+			v = v.Elem()
+		}
+		if entry, ok := v.Interface().(models.LogBookEntry); ok {
+			entries = append(entries, entry)
+		}
+	}
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			add(value.Index(i))
+		}
+	case reflect.Struct, reflect.Pointer:
+		add(value)
+	}
+	return entries
+}
+
+// Subscribe returns a channel of new entries matching filter and a function to unsubscribe.
+// The channel is closed on unsubscribe, or when the subscriber falls too far behind.
+func (s *LogBookStream) Subscribe(filter LogBookFilter) (<-chan models.LogBookEntry, func()) {
+	subscriber := &logBookSubscriber{filter: filter, entries: make(chan models.LogBookEntry, logBookSubscriberBuffer)}
+	s.mu.Lock()
+	s.subscribers[subscriber] = struct{}{}
+	s.mu.Unlock()
+
+	return subscriber.entries, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.remove(subscriber)
+	}
+}
+
+// Publish sends entries to every subscriber whose filter they match.
+func (s *LogBookStream) Publish(entries ...models.LogBookEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for subscriber := range s.subscribers {
+	send:
+		for _, entry := range entries {
+			if !subscriber.filter.Matches(entry) {
+				continue
+			}
+			select {
+			case subscriber.entries <- entry:
+			default:
+				s.remove(subscriber)
+				break send
+			}
+		}
+	}
+}
+
+// remove must be called with mu held.
+func (s *LogBookStream) remove(subscriber *logBookSubscriber) {
+	if _, ok := s.subscribers[subscriber]; ok {
+		delete(s.subscribers, subscriber)
+		close(subscriber.entries)
+	}
+}
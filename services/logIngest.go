@@ -0,0 +1,315 @@
+package services
+
+import (
+	"api/database"
+	"api/models"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	logIngestCategory = "log_ingest"
+	// droppedReportInterval limits how often dropped messages are summarized in the log book
+	droppedReportInterval = time.Minute
+	// maxIngestBuckets caps the categories rate limited on their own. Categories beyond it,
+	// like app names made up by a misbehaving sender, share the limit of otherIngestApps.
+	maxIngestBuckets = 1000
+	otherIngestApps  = "other apps"
+)
+
+// LogIngestConfig configures where system logs are read from and how fast they may be written.
+type LogIngestConfig struct {
+	// SyslogPort is 0 to not listen for syslog
+	SyslogPort      int
+	SyslogProtocols []string
+	// TailFile is a file to follow, for example /var/log/syslog. Empty to not tail a file.
+	TailFile string
+	// Journal follows `journalctl -o json`
+	Journal       bool
+	BatchSize     int
+	FlushInterval time.Duration
+	// RatePerSecond and Burst limit the messages accepted per category (app name)
+	RatePerSecond float64
+	Burst         int
+}
+
+// DefaultLogIngestConfig reads the config from LOG_INGEST_SYSLOG_PORT, LOG_INGEST_SYSLOG_PROTOCOLS
+// (default udp,tcp), LOG_INGEST_FILE, LOG_INGEST_JOURNAL, LOG_INGEST_BATCH_SIZE (default 100),
+// LOG_INGEST_FLUSH_INTERVAL (default 2s), LOG_INGEST_RATE (default 5 per second) and
+// LOG_INGEST_BURST (default 50).
+func DefaultLogIngestConfig() LogIngestConfig {
+	config := LogIngestConfig{
+		SyslogProtocols: []string{"udp", "tcp"},
+		TailFile:        os.Getenv("LOG_INGEST_FILE"),
+		Journal:         os.Getenv("LOG_INGEST_JOURNAL") == "true",
+		BatchSize:       100,
+		FlushInterval:   2 * time.Second,
+		RatePerSecond:   5,
+		Burst:           50,
+	}
+	if port, err := strconv.Atoi(os.Getenv("LOG_INGEST_SYSLOG_PORT")); err == nil && port > 0 {
+		config.SyslogPort = port
+	}
+	if protocols := os.Getenv("LOG_INGEST_SYSLOG_PROTOCOLS"); protocols != "" {
+		config.SyslogProtocols = strings.Split(protocols, ",")
+	}
+	if size, err := strconv.Atoi(os.Getenv("LOG_INGEST_BATCH_SIZE")); err == nil && size > 0 {
+		config.BatchSize = size
+	}
+	if interval, err := time.ParseDuration(os.Getenv("LOG_INGEST_FLUSH_INTERVAL")); err == nil && interval > 0 {
+		config.FlushInterval = interval
+	}
+	if rate, err := strconv.ParseFloat(os.Getenv("LOG_INGEST_RATE"), 64); err == nil && rate > 0 {
+		config.RatePerSecond = rate
+	}
+	if burst, err := strconv.Atoi(os.Getenv("LOG_INGEST_BURST")); err == nil && burst > 0 {
+		config.Burst = burst
+	}
+	return config
+}
+
+// LogIngester turns syslog, file and journal messages into system LogBookEntries. Entries
+// are written in batches, and each category is rate limited so that a chatty service
+// cannot fill the database. Dropped messages are counted and summarized in the log book.
+type LogIngester struct {
+	config  LogIngestConfig
+	entries chan models.LogBookEntry
+
+	mu           sync.Mutex
+	buckets      map[string]*tokenBucket
+	dropped      map[string]int
+	lastReported time.Time
+}
+
+func NewLogIngester(config LogIngestConfig) *LogIngester {
+	return &LogIngester{
+		config:  config,
+		entries: make(chan models.LogBookEntry, config.BatchSize*10),
+		buckets: map[string]*tokenBucket{},
+		dropped: map[string]int{},
+	}
+}
+
+// Enabled reports whether any source is configured.
+func (i *LogIngester) Enabled() bool {
+	return i.config.SyslogPort != 0 || i.config.TailFile != "" || i.config.Journal
+}
+
+// Start opens the configured sources and writes their messages until ctx is done. When a
+// source fails to open, the ones opened before it are closed again.
+func (i *LogIngester) Start(ctx context.Context) error {
+	if !i.Enabled() {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	if err := i.open(ctx); err != nil {
+		cancel()
+		return err
+	}
+	go func() {
+		defer cancel()
+		i.run(ctx)
+	}()
+	return nil
+}
+
+func (i *LogIngester) open(ctx context.Context) error {
+	if i.config.SyslogPort != 0 {
+		addr := ":" + strconv.Itoa(i.config.SyslogPort)
+		for _, protocol := range i.config.SyslogProtocols {
+			var err error
+			switch strings.TrimSpace(protocol) {
+			case "udp":
+				err = ListenSyslogUDP(ctx, addr, i.SubmitSyslog)
+			case "tcp":
+				err = ListenSyslogTCP(ctx, addr, i.SubmitSyslog)
+			default:
+				err = fmt.Errorf("unknown syslog protocol %q", protocol)
+			}
+			if err != nil {
+				return err
+			}
+			log.Printf("Listening for syslog on %s/%s", addr, protocol)
+		}
+	}
+	if i.config.TailFile != "" {
+		category := strings.TrimSuffix(filepath.Base(i.config.TailFile), filepath.Ext(i.config.TailFile))
+		go TailFile(ctx, i.config.TailFile, func(line string) {
+			if strings.TrimSpace(line) == "" {
+				return
+			}
+			msg := ParseSyslogMessage(line, time.Now())
+			if msg.AppName == "" {
+				msg.AppName = category
+			}
+			i.SubmitSyslog(msg)
+		})
+		log.Println("Tailing", i.config.TailFile)
+	}
+	if i.config.Journal {
+		go FollowJournal(ctx, i.SubmitSyslog)
+		log.Println("Following the systemd journal")
+	}
+	return nil
+}
+
+// SubmitSyslog queues a parsed message as a system entry.
+func (i *LogIngester) SubmitSyslog(msg SyslogMessage) {
+	category := msg.AppName
+	if category == "" {
+		category = defaultSyslogApp
+	} else if len(category) > maxLogCategory {
+		category = category[:maxLogCategory]
+	}
+	message := msg.Message
+	if len(message) > maxLogMessage {
+		message = message[:maxLogMessage]
+	}
+	i.Submit(models.LogBookEntry{
+		Message:   message,
+		Level:     SyslogSeverityLevel(msg.Severity),
+		Category:  category,
+		Timestamp: msg.Timestamp,
+	})
+}
+
+// Submit queues entry for the next batch, unless its category is over the rate limit
+// or the queue is full.
+func (i *LogIngester) Submit(entry models.LogBookEntry) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := time.Now()
+	limited := i.limitedAs(entry.Category, now)
+	if !i.buckets[limited].take(now) {
+		i.dropped[limited]++
+		return
+	}
+	select {
+	case i.entries <- entry:
+	default:
+		i.dropped[limited]++
+	}
+}
+
+// limitedAs returns the bucket category is rate limited under, creating it when needed.
+// Buckets that refilled completely are forgotten, a new one behaves the same.
+func (i *LogIngester) limitedAs(category string, now time.Time) string {
+	if _, ok := i.buckets[category]; ok {
+		return category
+	}
+	if len(i.buckets) >= maxIngestBuckets {
+		for name, bucket := range i.buckets {
+			if bucket.full(now) {
+				delete(i.buckets, name)
+			}
+		}
+	}
+	if len(i.buckets) >= maxIngestBuckets {
+		category = otherIngestApps
+		if _, ok := i.buckets[category]; ok {
+			return category
+		}
+	}
+	i.buckets[category] = newTokenBucket(i.config.RatePerSecond, i.config.Burst)
+	return category
+}
+
+func (i *LogIngester) run(ctx context.Context) {
+	ticker := time.NewTicker(i.config.FlushInterval)
+	defer ticker.Stop()
+	batch := make([]models.LogBookEntry, 0, i.config.BatchSize)
+	for {
+		select {
+		case <-ctx.Done():
+			i.write(batch)
+			return
+		case entry := <-i.entries:
+			batch = append(batch, entry)
+			if len(batch) >= i.config.BatchSize {
+				i.write(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			batch = append(batch, i.droppedEntries()...)
+			i.write(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (i *LogIngester) write(batch []models.LogBookEntry) {
+	if len(batch) == 0 {
+		return
+	}
+	// System logs are not records clients sync, and would fill the change log
+	if err := database.WithoutChangeLog(database.DB).CreateInBatches(batch, i.config.BatchSize).Error; err != nil {
+		log.Printf("Failed to write %d ingested log entries: %v", len(batch), err)
+	}
+}
+
+// droppedEntries summarizes the messages dropped since the last report, at most once per droppedReportInterval.
+func (i *LogIngester) droppedEntries() []models.LogBookEntry {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := time.Now()
+	if len(i.dropped) == 0 || now.Sub(i.lastReported) < droppedReportInterval {
+		return nil
+	}
+	categories := make([]string, 0, len(i.dropped))
+	for category := range i.dropped {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	entries := make([]models.LogBookEntry, 0, len(categories))
+	for _, category := range categories {
+		entries = append(entries, models.LogBookEntry{
+			Message:   fmt.Sprintf("Dropped %d messages from %s, over the limit of %g per second", i.dropped[category], category, i.config.RatePerSecond),
+			Level:     "warn",
+			Category:  logIngestCategory,
+			Timestamp: now,
+		})
+	}
+	i.dropped = map[string]int{}
+	i.lastReported = now
+	return entries
+}
+
+// tokenBucket allows burst events at once and refills at rate per second.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+// full reports whether the bucket refilled to burst by now.
+func (b *tokenBucket) full(now time.Time) bool {
+	return b.last.IsZero() || b.tokens+now.Sub(b.last).Seconds()*b.rate >= b.burst
+}
+
+func (b *tokenBucket) take(now time.Time) bool {
+	if !b.last.IsZero() {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,321 @@
+package services
+
+import (
+	"api/database"
+	"api/dtos"
+	"api/models"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const purgeBatchSize = 1000
+
+// LogRetentionService deletes LogBookEntries that are older than their retention policy
+// allows, optionally archiving them to gzipped NDJSON files first. It also compacts the
+// change log, which otherwise grows with every write.
+type LogRetentionService struct {
+	// ArchiveDir is where archive files are written
+	ArchiveDir string
+	// ChangeLogMaxAge is how long change log entries are kept once a newer one of the same record replaces them
+	ChangeLogMaxAge time.Duration
+
+	mu          sync.Mutex
+	running     bool
+	nextPurgeAt *time.Time
+	lastPurge   *dtos.LogPurgeResult
+}
+
+// NewLogRetentionService archives to LOG_ARCHIVE_DIR, ./log_archive by default, and keeps replaced
+// change log entries for CHANGE_LOG_RETENTION_DAYS, 30 by default.
+func NewLogRetentionService() *LogRetentionService {
+	dir := os.Getenv("LOG_ARCHIVE_DIR")
+	if dir == "" {
+		dir = "./log_archive"
+	}
+	days, err := strconv.Atoi(os.Getenv("CHANGE_LOG_RETENTION_DAYS"))
+	if err != nil || days < 1 {
+		days = 30
+	}
+	return &LogRetentionService{ArchiveDir: dir, ChangeLogMaxAge: time.Duration(days) * 24 * time.Hour}
+}
+
+// ValidateLogRetentionPolicy returns a message per invalid field, or nil when the policy is valid.
+func ValidateLogRetentionPolicy(policy models.LogRetentionPolicy) map[string]string {
+	fields := map[string]string{}
+	if policy.Level != "" && !slices.Contains(LogLevels, policy.Level) {
+		fields["level"] = "must be empty (any level) or a log level"
+	}
+	if policy.MaxAgeDays < 1 {
+		fields["maxAgeDays"] = "must be at least 1"
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// Start purges once right away, as a Pi may not stay up for a whole interval, and then every interval until ctx is done.
+func (s *LogRetentionService) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			s.Purge()
+			next := time.Now().Add(interval)
+			s.mu.Lock()
+			s.nextPurgeAt = &next
+			s.mu.Unlock()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Status reports the table sizes and the next and last purge.
+func (s *LogRetentionService) Status() (dtos.LogRetentionStatusResponse, error) {
+	tables, databaseBytes, err := TableSizes()
+	if err != nil {
+		return dtos.LogRetentionStatusResponse{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return dtos.LogRetentionStatusResponse{
+		Tables:        tables,
+		DatabaseBytes: databaseBytes,
+		NextPurgeAt:   s.nextPurgeAt,
+		LastPurge:     s.lastPurge,
+	}, nil
+}
+
+// Purge enforces all retention policies. Only one purge runs at a time; a purge requested
+// while another runs returns the result of the last finished one.
+func (s *LogRetentionService) Purge() dtos.LogPurgeResult {
+	s.mu.Lock()
+	if s.running {
+		defer s.mu.Unlock()
+		if s.lastPurge != nil {
+			return *s.lastPurge
+		}
+		return dtos.LogPurgeResult{Error: "a purge is already running"}
+	}
+	s.running = true
+	s.mu.Unlock()
+
+	result := s.purge()
+	if result.Error != "" {
+		log.Println("Log retention purge failed:", result.Error)
+	} else if result.Deleted > 0 || result.ChangeLogCompacted > 0 {
+		log.Printf("Log retention purge deleted %d entries, archived %d, compacted %d change log entries", result.Deleted, result.Archived, result.ChangeLogCompacted)
+	}
+
+	s.mu.Lock()
+	s.running = false
+	s.lastPurge = &result
+	s.mu.Unlock()
+	return result
+}
+
+func (s *LogRetentionService) purge() (result dtos.LogPurgeResult) {
+	result.StartedAt = time.Now()
+	var policies []models.LogRetentionPolicy
+	if err := database.DB.Find(&policies).Error; err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	sort.SliceStable(policies, func(i, j int) bool {
+		return policySpecificity(policies[i]) > policySpecificity(policies[j])
+	})
+
+	var archive *logArchive
+	defer func() {
+		if archive != nil {
+			if err := archive.Close(); err != nil && result.Error == "" {
+				result.Error = err.Error()
+			}
+		}
+	}()
+
+	for i, policy := range policies {
+		expired := expiredEntries(policy, policies[:i], result.StartedAt)
+		for {
+			var batch []models.LogBookEntry
+			if err := database.DB.Unscoped().Scopes(expired).Order("id").Limit(purgeBatchSize).Find(&batch).Error; err != nil {
+				result.Error = err.Error()
+				return result
+			}
+			if len(batch) == 0 {
+				break
+			}
+			if policy.Archive {
+				if archive == nil {
+					var err error
+					if archive, err = newLogArchive(s.ArchiveDir, result.StartedAt); err != nil {
+						result.Error = err.Error()
+						return result
+					}
+					result.ArchiveFile = archive.path
+				}
+				// Entries are only deleted once they are safely on disk
+				if err := archive.Write(batch); err != nil {
+					result.Error = err.Error()
+					return result
+				}
+				result.Archived += int64(len(batch))
+			}
+			ids := make([]int, len(batch))
+			for j, entry := range batch {
+				ids[j] = entry.ID
+			}
+			// Expiry is housekeeping, not a change clients have to sync
+			deleted := database.WithoutChangeLog(database.DB).Unscoped().Where("id IN ?", ids).Delete(&models.LogBookEntry{})
+			if deleted.Error != nil {
+				result.Error = deleted.Error.Error()
+				return result
+			}
+			result.Deleted += deleted.RowsAffected
+			// Their history goes with them, clients never hear of the purge either
+			recordIds := make([]string, len(ids))
+			for j, id := range ids {
+				recordIds[j] = strconv.Itoa(id)
+			}
+			err := database.DB.Where("model_name = ? AND record_id IN ?", "LogBookEntry", recordIds).Delete(&models.ChangeLogEntry{}).Error
+			if err != nil {
+				result.Error = err.Error()
+				return result
+			}
+			if len(batch) < purgeBatchSize {
+				break
+			}
+		}
+	}
+
+	compacted, err := compactChangeLog(result.StartedAt.Add(-s.ChangeLogMaxAge))
+	result.ChangeLogCompacted = compacted
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// compactChangeLog deletes the change log entries from before cutoff that a newer entry of the
+// same record replaces. Pulls only return the last change of a record, so they stay the same
+// for every cursor, including 0.
+func compactChangeLog(cutoff time.Time) (int64, error) {
+	var compacted int64
+	for {
+		var ids []uint64
+		err := database.DB.Model(&models.ChangeLogEntry{}).
+			Where("timestamp < ?", cutoff).
+			Where("EXISTS (SELECT 1 FROM change_log_entries newer WHERE newer.model_name = change_log_entries.model_name AND newer.record_id = change_log_entries.record_id AND newer.id > change_log_entries.id)").
+			Order("id").Limit(purgeBatchSize).Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return compacted, err
+		}
+		deleted := database.DB.Where("id IN ?", ids).Delete(&models.ChangeLogEntry{})
+		if deleted.Error != nil {
+			return compacted, deleted.Error
+		}
+		compacted += deleted.RowsAffected
+		if len(ids) < purgeBatchSize {
+			return compacted, nil
+		}
+	}
+}
+
+// policySpecificity ranks policies: level and category 3, category 2, level 1, default 0.
+func policySpecificity(policy models.LogRetentionPolicy) int {
+	rank := 0
+	if policy.Category != "" {
+		rank += 2
+	}
+	if policy.Level != "" {
+		rank++
+	}
+	return rank
+}
+
+// expiredEntries selects the entries of policy older than its max age that none of the
+// more specific policies matches.
+func expiredEntries(policy models.LogRetentionPolicy, moreSpecific []models.LogRetentionPolicy, now time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Where("timestamp < ?", now.AddDate(0, 0, -policy.MaxAgeDays))
+		if policy.Level != "" {
+			db = db.Where("level = ?", policy.Level)
+		}
+		if policy.Category != "" {
+			db = db.Where("category = ?", policy.Category)
+		}
+		for _, other := range moreSpecific {
+			if policySpecificity(other) == policySpecificity(policy) {
+				continue
+			}
+			switch {
+			case other.Level != "" && other.Category != "":
+				db = db.Not("level = ? AND category = ?", other.Level, other.Category)
+			case other.Category != "":
+				db = db.Not("category = ?", other.Category)
+			case other.Level != "":
+				db = db.Not("level = ?", other.Level)
+			}
+		}
+		return db
+	}
+}
+
+// logArchive is a gzipped NDJSON file with one LogBookEntry per line.
+type logArchive struct {
+	path    string
+	file    *os.File
+	gzip    *gzip.Writer
+	encoder *json.Encoder
+}
+
+func newLogArchive(dir string, startedAt time.Time) (*logArchive, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("log_book-%s.ndjson.gz", startedAt.UTC().Format("20060102-150405")))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	writer := gzip.NewWriter(file)
+	return &logArchive{path: path, file: file, gzip: writer, encoder: json.NewEncoder(writer)}, nil
+}
+
+// Write appends entries and flushes them to disk.
+func (a *logArchive) Write(entries []models.LogBookEntry) error {
+	for _, entry := range entries {
+		if err := a.encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	if err := a.gzip.Flush(); err != nil {
+		return err
+	}
+	return a.file.Sync()
+}
+
+func (a *logArchive) Close() error {
+	if err := a.gzip.Close(); err != nil {
+		a.file.Close()
+		return err
+	}
+	return a.file.Close()
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	tailPollInterval   = time.Second
+	journalRestartWait = 10 * time.Second
+)
+
+// TailFile follows path like tail -F from its current end until ctx is done, calling
+// handle for every complete line. Truncation and rotation (a new file at path) restart
+// reading at the beginning of the file.
+func TailFile(ctx context.Context, path string, handle func(line string)) {
+	var file *os.File
+	var reader *bufio.Reader
+	var offset int64
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+
+	open := func(fromEnd bool) {
+		f, err := os.Open(path)
+		if err != nil {
+			return
+		}
+		offset = 0
+		if fromEnd {
+			if offset, err = f.Seek(0, io.SeekEnd); err != nil {
+				f.Close()
+				return
+			}
+		}
+		file, reader = f, bufio.NewReader(f)
+	}
+	open(true)
+
+	ticker := time.NewTicker(tailPollInterval)
+	defer ticker.Stop()
+	partial := ""
+	for {
+		if file != nil {
+			for {
+				chunk, err := reader.ReadString('\n')
+				offset += int64(len(chunk))
+				partial += chunk
+				if err != nil {
+					break
+				}
+				handle(strings.TrimRight(partial, "\r\n"))
+				partial = ""
+			}
+
+			current, statErr := os.Stat(path)
+			opened, openErr := file.Stat()
+			rotated := statErr == nil && openErr == nil && !os.SameFile(current, opened)
+			truncated := openErr == nil && opened.Size() < offset
+			if rotated || truncated {
+				file.Close()
+				file, partial = nil, ""
+			}
+		}
+		if file == nil {
+			// A file that appears after startup or after rotation is read from its beginning
+			open(false)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// journalEntry holds the fields of a `journalctl -o json` line that the log book needs.
+// MESSAGE is an array of bytes instead of a string when it is not valid UTF-8.
+type journalEntry struct {
+	Message          json.RawMessage `json:"MESSAGE"`
+	Priority         string          `json:"PRIORITY"`
+	SyslogIdentifier string          `json:"SYSLOG_IDENTIFIER"`
+	Comm             string          `json:"_COMM"`
+	RealtimeUsec     string          `json:"__REALTIME_TIMESTAMP"`
+}
+
+// ParseJournalLine converts one line of `journalctl -o json` output.
+func ParseJournalLine(line []byte) (SyslogMessage, error) {
+	var entry journalEntry
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return SyslogMessage{}, err
+	}
+	msg := SyslogMessage{Severity: 6, AppName: entry.SyslogIdentifier, Timestamp: time.Now()}
+	if msg.AppName == "" {
+		msg.AppName = entry.Comm
+	}
+	if severity, err := strconv.Atoi(entry.Priority); err == nil {
+		msg.Severity = severity
+	}
+	if usec, err := strconv.ParseInt(entry.RealtimeUsec, 10, 64); err == nil {
+		msg.Timestamp = time.UnixMicro(usec)
+	}
+	if err := json.Unmarshal(entry.Message, &msg.Message); err != nil {
+		var raw []byte
+		if err := json.Unmarshal(entry.Message, &raw); err != nil {
+			return SyslogMessage{}, errors.New("journal entry without MESSAGE")
+		}
+		msg.Message = strings.ToValidUTF8(string(raw), "\uFFFD")
+	}
+	return msg, nil
+}
+
+// FollowJournal runs `journalctl -o json -f` until ctx is done, starting with new entries,
+// and restarts it when it exits.
+func FollowJournal(ctx context.Context, handle func(SyslogMessage)) {
+	for {
+		// Cancelled to kill journalctl when its output can't be read any more
+		runCtx, cancel := context.WithCancel(ctx)
+		cmd := exec.CommandContext(runCtx, "journalctl", "-o", "json", "-f", "-n", "0")
+		stdout, err := cmd.StdoutPipe()
+		if err == nil {
+			err = cmd.Start()
+		}
+		if err == nil {
+			scanner := bufio.NewScanner(stdout)
+			scanner.Buffer(make([]byte, 64*1024), 4*maxSyslogMessage)
+			for scanner.Scan() {
+				msg, err := ParseJournalLine(scanner.Bytes())
+				if err != nil {
+					log.Println("Skipping journal entry:", err)
+					continue
+				}
+				handle(msg)
+			}
+			// On an entry too long to scan journalctl keeps running, and would block on the
+			// full pipe forever
+			if err = scanner.Err(); err != nil {
+				cancel()
+			}
+			if waitErr := cmd.Wait(); err == nil {
+				err = waitErr
+			}
+		}
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("journalctl stopped (%v), restarting in %s", err, journalRestartWait)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(journalRestartWait):
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package services
+
+import (
+	"api/database"
+	"api/models"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+	"gorm.io/gorm"
+)
+
+const (
+	// thumbnailSize is the longest side of a thumbnail in pixels
+	thumbnailSize    = 320
+	thumbnailQuality = 80
+	// maxImagePixels keeps a small file that unpacks into a huge image from using up the memory of a Pi
+	maxImagePixels = 40_000_000
+)
+
+// imageTypes are the accepted image types, as sniffed from the content, with their file extensions.
+var imageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+var (
+	// ErrUnsupportedImage is returned for uploads that are not a JPEG, PNG, GIF or WebP image.
+	ErrUnsupportedImage = errors.New("only JPEG, PNG, GIF and WebP images are supported")
+	// ErrImageTooLarge is returned for uploads above MaxBytes or with too many pixels.
+	ErrImageTooLarge = errors.New("image is too large")
+	// ErrTooManyImages is returned when an item already has MaxPerItem images.
+	ErrTooManyImages = errors.New("market item has the most images allowed")
+)
+
+// MarketImageService stores the images of market items on disk, with a JPEG thumbnail of each.
+type MarketImageService struct {
+	Dir        string
+	MaxBytes   int64
+	MaxPerItem int
+}
+
+// NewMarketImageService stores images in MARKET_IMAGE_DIR (./market_images by default). Images may
+// be up to MARKET_IMAGE_MAX_MB (default 10) and an item can have MARKET_IMAGE_MAX_PER_ITEM (default 8).
+func NewMarketImageService() *MarketImageService {
+	dir := os.Getenv("MARKET_IMAGE_DIR")
+	if dir == "" {
+		dir = "./market_images"
+	}
+	maxMegabytes, err := strconv.Atoi(os.Getenv("MARKET_IMAGE_MAX_MB"))
+	if err != nil || maxMegabytes < 1 {
+		maxMegabytes = 10
+	}
+	maxPerItem, err := strconv.Atoi(os.Getenv("MARKET_IMAGE_MAX_PER_ITEM"))
+	if err != nil || maxPerItem < 1 {
+		maxPerItem = 8
+	}
+	return &MarketImageService{Dir: dir, MaxBytes: int64(maxMegabytes) << 20, MaxPerItem: maxPerItem}
+}
+
+// Add stores upload as the last image of the item with id itemId.
+func (s *MarketImageService) Add(ctx context.Context, itemId uint, upload io.Reader) (models.MarketItemImage, error) {
+	data, err := io.ReadAll(io.LimitReader(upload, s.MaxBytes+1))
+	if err != nil {
+		return models.MarketItemImage{}, err
+	}
+	if int64(len(data)) > s.MaxBytes {
+		return models.MarketItemImage{}, fmt.Errorf("%w, the limit is %d MB", ErrImageTooLarge, s.MaxBytes>>20)
+	}
+	// The client's content type and file name are not trusted, the content decides
+	contentType := http.DetectContentType(data)
+	extension, ok := imageTypes[contentType]
+	if !ok {
+		return models.MarketItemImage{}, ErrUnsupportedImage
+	}
+	config, err := decodeImageConfig(contentType, data)
+	if err != nil {
+		return models.MarketItemImage{}, ErrUnsupportedImage
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return models.MarketItemImage{}, fmt.Errorf("%w, the limit is %d megapixels", ErrImageTooLarge, maxImagePixels/1_000_000)
+	}
+	thumbnail, err := makeThumbnail(contentType, data)
+	if err != nil {
+		return models.MarketItemImage{}, ErrUnsupportedImage
+	}
+
+	name, err := randomImageName()
+	if err != nil {
+		return models.MarketItemImage{}, err
+	}
+	marketImage := models.MarketItemImage{
+		MarketItemId: itemId,
+		FileName:     filepath.ToSlash(filepath.Join(strconv.FormatUint(uint64(itemId), 10), name+extension)),
+		ContentType:  contentType,
+		Size:         int64(len(data)),
+		Width:        config.Width,
+		Height:       config.Height,
+	}
+	if err := os.MkdirAll(filepath.Dir(s.Path(marketImage, false)), 0o755); err != nil {
+		return models.MarketItemImage{}, err
+	}
+	if err := writeFileAtomic(s.Path(marketImage, false), data); err != nil {
+		return models.MarketItemImage{}, err
+	}
+	if err := writeFileAtomic(s.Path(marketImage, true), thumbnail); err != nil {
+		s.removeFiles(marketImage)
+		return models.MarketItemImage{}, err
+	}
+
+	err = database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		var last *int
+		if err := tx.Model(&models.MarketItemImage{}).Where("market_item_id = ?", itemId).Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= int64(s.MaxPerItem) {
+			return fmt.Errorf("%w (%d)", ErrTooManyImages, s.MaxPerItem)
+		}
+		if err := tx.Model(&models.MarketItemImage{}).Where("market_item_id = ?", itemId).Select("MAX(position)").Scan(&last).Error; err != nil {
+			return err
+		}
+		if last != nil {
+			marketImage.Position = *last + 1
+		}
+		return tx.Create(&marketImage).Error
+	})
+	if err != nil {
+		s.removeFiles(marketImage)
+		return models.MarketItemImage{}, err
+	}
+	return marketImage, nil
+}
+
+// Path is the file of marketImage, or of its thumbnail.
+func (s *MarketImageService) Path(marketImage models.MarketItemImage, thumbnail bool) string {
+	path := filepath.Join(s.Dir, filepath.FromSlash(marketImage.FileName))
+	if thumbnail {
+		path = path[:len(path)-len(filepath.Ext(path))] + "_thumb.jpg"
+	}
+	return path
+}
+
+// Delete removes marketImage and its files.
+func (s *MarketImageService) Delete(ctx context.Context, marketImage models.MarketItemImage) error {
+	if err := database.DB.WithContext(ctx).Unscoped().Delete(&marketImage).Error; err != nil {
+		return err
+	}
+	s.removeFiles(marketImage)
+	return nil
+}
+
+// DeleteAll removes all images of the item with id itemId, for when the item itself is gone for good.
+func (s *MarketImageService) DeleteAll(ctx context.Context, itemId uint) error {
+	var images []models.MarketItemImage
+	if err := database.DB.Unscoped().Where("market_item_id = ?", itemId).Find(&images).Error; err != nil {
+		return err
+	}
+	for _, marketImage := range images {
+		if err := s.Delete(ctx, marketImage); err != nil {
+			return err
+		}
+	}
+	// Only succeeds when nothing else was left in the directory of the item
+	os.Remove(filepath.Join(s.Dir, strconv.FormatUint(uint64(itemId), 10)))
+	return nil
+}
+
+func (s *MarketImageService) removeFiles(marketImage models.MarketItemImage) {
+	for _, path := range []string{s.Path(marketImage, false), s.Path(marketImage, true)} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Println("Failed to remove market image:", err)
+		}
+	}
+}
+
+func randomImageName() (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(random), nil
+}
+
+// writeFileAtomic writes data to path, which only appears once it is completely on disk.
+func writeFileAtomic(path string, data []byte) error {
+	partial := path + ".partial"
+	if err := os.WriteFile(partial, data, 0o644); err != nil {
+		os.Remove(partial)
+		return err
+	}
+	return os.Rename(partial, path)
+}
+
+func decodeImageConfig(contentType string, data []byte) (image.Config, error) {
+	reader := bytes.NewReader(data)
+	switch contentType {
+	case "image/jpeg":
+		return jpeg.DecodeConfig(reader)
+	case "image/png":
+		return png.DecodeConfig(reader)
+	case "image/gif":
+		return gif.DecodeConfig(reader)
+	default:
+		return webp.DecodeConfig(reader)
+	}
+}
+
+func decodeImage(contentType string, data []byte) (image.Image, error) {
+	reader := bytes.NewReader(data)
+	switch contentType {
+	case "image/jpeg":
+		return jpeg.Decode(reader)
+	case "image/png":
+		return png.Decode(reader)
+	case "image/gif":
+		// The first frame of an animation
+		return gif.Decode(reader)
+	default:
+		return webp.Decode(reader)
+	}
+}
+
+// makeThumbnail scales the image down to fit in thumbnailSize and encodes it as JPEG.
+// Transparent parts become white.
+func makeThumbnail(contentType string, data []byte) ([]byte, error) {
+	source, err := decodeImage(contentType, data)
+	if err != nil {
+		return nil, err
+	}
+	bounds := source.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > thumbnailSize || height > thumbnailSize {
+		if width >= height {
+			width, height = thumbnailSize, max(1, height*thumbnailSize/width)
+		} else {
+			width, height = max(1, width*thumbnailSize/height), thumbnailSize
+		}
+	}
+	thumbnail := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(thumbnail, thumbnail.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.BiLinear.Scale(thumbnail, thumbnail.Bounds(), source, bounds, draw.Over, nil)
+
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, thumbnail, &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil, err
+	}
+	return encoded.Bytes(), nil
+}
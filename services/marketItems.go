@@ -0,0 +1,156 @@
+package services
+
+import (
+	"api/database"
+	"api/models"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const (
+	maxMarketItemTitle       = 200
+	maxMarketItemDescription = 5000
+)
+
+// ValidateMarketItem checks an item and returns a message per invalid field, or nil when it is valid.
+// The category is optional, but when it is set it has to exist. The seller always has to exist.
+func ValidateMarketItem(item *models.MarketItem) (map[string]string, error) {
+	fields := map[string]string{}
+	if strings.TrimSpace(item.Title) == "" {
+		fields["title"] = "is required"
+	} else if len(item.Title) > maxMarketItemTitle {
+		fields["title"] = fmt.Sprintf("must be at most %d characters", maxMarketItemTitle)
+	}
+	if len(item.Description) > maxMarketItemDescription {
+		fields["description"] = fmt.Sprintf("must be at most %d characters", maxMarketItemDescription)
+	}
+	if item.Price < 0 {
+		fields["price"] = "must not be negative"
+	}
+	if item.CategoryId != 0 {
+		exists, err := recordExists(&models.Category{}, item.CategoryId)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			fields["categoryId"] = "does not exist"
+		}
+	}
+	if item.UserId == 0 {
+		fields["userId"] = "is required"
+	} else if exists, err := recordExists(&models.User{}, item.UserId); err != nil {
+		return nil, err
+	} else if !exists {
+		fields["userId"] = "does not exist"
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return fields, nil
+}
+
+// recordExists reports whether the model has a record with id that is not in the trash.
+func recordExists(model any, id uint) (bool, error) {
+	var count int64
+	err := database.DB.Model(model).Where("id = ?", id).Count(&count).Error
+	return count > 0, err
+}
+
+// MarketItemSort is an order market items can be listed in. Ties are broken by id in the same
+// direction, so every item has a fixed place and a cursor can continue right after it.
+type MarketItemSort struct {
+	// Column is empty to order by id alone
+	Column string
+	Desc   bool
+	value  func(item models.MarketItem) any
+}
+
+// Prices go into cursors as float64, the float32 would come back from JSON as a slightly
+// different float64 and no longer equal the stored price
+func marketItemPrice(item models.MarketItem) any { return float64(item.Price) }
+func marketItemTitle(item models.MarketItem) any { return item.Title }
+
+// MarketItemSorts are the accepted values of the sort parameter.
+var MarketItemSorts = map[string]MarketItemSort{
+	"newest":     {Desc: true},
+	"oldest":     {},
+	"price_asc":  {Column: "price", value: marketItemPrice},
+	"price_desc": {Column: "price", Desc: true, value: marketItemPrice},
+	"title":      {Column: "title", value: marketItemTitle},
+}
+
+// MarketItemCursor points at the last item of a page. It is only valid for the sort it was made for.
+type MarketItemCursor struct {
+	Sort  string `json:"s"`
+	Value any    `json:"v"`
+	ID    int    `json:"id"`
+}
+
+// ErrInvalidCursor is returned for cursors that were not made by EncodeMarketItemCursor for the same sort.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Order sorts query in this order.
+func (s MarketItemSort) Order(query *gorm.DB) *gorm.DB {
+	direction := ""
+	if s.Desc {
+		direction = " DESC"
+	}
+	if s.Column != "" {
+		query = query.Order(s.Column + direction)
+	}
+	return query.Order("id" + direction)
+}
+
+// After limits query to the items that come after cursor in this order.
+func (s MarketItemSort) After(query *gorm.DB, cursor MarketItemCursor) *gorm.DB {
+	comparison := " > ?"
+	if s.Desc {
+		comparison = " < ?"
+	}
+	if s.Column == "" {
+		return query.Where("id"+comparison, cursor.ID)
+	}
+	return query.Where("("+s.Column+comparison+") OR ("+s.Column+" = ? AND id"+comparison+")", cursor.Value, cursor.Value, cursor.ID)
+}
+
+// Cursor returns the cursor pointing at item in the sort called name.
+func (s MarketItemSort) Cursor(name string, item models.MarketItem) MarketItemCursor {
+	cursor := MarketItemCursor{Sort: name, ID: item.ID}
+	if s.value != nil {
+		cursor.Value = s.value(item)
+	}
+	return cursor
+}
+
+// EncodeMarketItemCursor turns cursor into an opaque string for the client to pass back.
+func EncodeMarketItemCursor(cursor MarketItemCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeMarketItemCursor reads a cursor from EncodeMarketItemCursor and checks it was made for sort.
+func DecodeMarketItemCursor(encoded, sort string) (MarketItemCursor, error) {
+	var cursor MarketItemCursor
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || json.Unmarshal(data, &cursor) != nil || cursor.Sort != sort {
+		return cursor, ErrInvalidCursor
+	}
+	// The value has to be of the type the sort column holds, as JSON decoded it
+	column := MarketItemSorts[sort].Column
+	valid := cursor.Value == nil && column == ""
+	switch cursor.Value.(type) {
+	case float64:
+		valid = column == "price"
+	case string:
+		valid = column == "title"
+	}
+	if !valid {
+		return cursor, ErrInvalidCursor
+	}
+	return cursor, nil
+}
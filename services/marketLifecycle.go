@@ -0,0 +1,335 @@
+package services
+
+import (
+	"api/database"
+	"api/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The states of a market item. Items start as draft or active, can be reserved for a buyer while
+// active, and end up sold or withdrawn. Withdrawn items can be published again.
+const (
+	MarketItemDraft     = "draft"
+	MarketItemActive    = "active"
+	MarketItemReserved  = "reserved"
+	MarketItemSold      = "sold"
+	MarketItemWithdrawn = "withdrawn"
+)
+
+// MarketItemStatuses are all states, in lifecycle order.
+var MarketItemStatuses = []string{MarketItemDraft, MarketItemActive, MarketItemReserved, MarketItemSold, MarketItemWithdrawn}
+
+// MarketItemTransition moves an item from one of From to To.
+type MarketItemTransition struct {
+	From []string
+	To   string
+}
+
+// MarketItemTransitions are the transitions by action, as in POST /marketitem/:id/<action>.
+var MarketItemTransitions = map[string]MarketItemTransition{
+	"publish":  {From: []string{MarketItemDraft, MarketItemWithdrawn}, To: MarketItemActive},
+	"reserve":  {From: []string{MarketItemActive}, To: MarketItemReserved},
+	"release":  {From: []string{MarketItemReserved}, To: MarketItemActive},
+	"sell":     {From: []string{MarketItemActive, MarketItemReserved}, To: MarketItemSold},
+	"withdraw": {From: []string{MarketItemDraft, MarketItemActive, MarketItemReserved}, To: MarketItemWithdrawn},
+}
+
+var (
+	// ErrInvalidTransition is returned when the item is not in a state the action starts from.
+	ErrInvalidTransition = errors.New("invalid market item transition")
+	// ErrReserveOwnItem is returned when the seller is given as the buyer of a reservation.
+	ErrReserveOwnItem = errors.New("the seller can't reserve their own item")
+	// ErrUnknownBuyer is returned when the buyer of a reservation does not exist.
+	ErrUnknownBuyer = errors.New("buyer does not exist")
+)
+
+// MarketLifecycleService moves market items through their states, expires reservations, purges
+// the trash and keeps the price history.
+type MarketLifecycleService struct {
+	// ReservationTTL is how long a reservation holds before the item is active again
+	ReservationTTL time.Duration
+	// TrashRetention is how long deleted items stay in the trash before they and their images are purged
+	TrashRetention time.Duration
+}
+
+// NewMarketLifecycleService keeps reservations for MARKET_RESERVATION_TTL (Go duration, default 48h)
+// and deleted items for MARKET_TRASH_RETENTION (Go duration, default 720h).
+func NewMarketLifecycleService() *MarketLifecycleService {
+	ttl, err := time.ParseDuration(os.Getenv("MARKET_RESERVATION_TTL"))
+	if err != nil || ttl <= 0 {
+		ttl = 48 * time.Hour
+	}
+	retention, err := time.ParseDuration(os.Getenv("MARKET_TRASH_RETENTION"))
+	if err != nil || retention <= 0 {
+		retention = 30 * 24 * time.Hour
+	}
+	return &MarketLifecycleService{ReservationTTL: ttl, TrashRetention: retention}
+}
+
+// Transition applies action to item. buyerId is the user a reservation is for and is ignored by
+// the other actions. item is not updated, load it again for its new state.
+func (s *MarketLifecycleService) Transition(ctx context.Context, item *models.MarketItem, action string, buyerId uint) error {
+	transition, ok := MarketItemTransitions[action]
+	if !ok {
+		return fmt.Errorf("%w: unknown action %s", ErrInvalidTransition, action)
+	}
+	now := time.Now()
+	updates := map[string]any{"status": transition.To, "reserved_by_id": nil, "reserved_until": nil}
+	switch action {
+	case "publish":
+		// Time to sell counts from the first time the item was listed
+		if item.PublishedAt == nil {
+			updates["published_at"] = now
+		}
+	case "reserve":
+		if buyerId == item.UserId {
+			return ErrReserveOwnItem
+		}
+		exists, err := recordExists(&models.User{}, buyerId)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrUnknownBuyer
+		}
+		updates["reserved_by_id"] = buyerId
+		updates["reserved_until"] = now.Add(s.ReservationTTL)
+	case "sell":
+		updates["sold_at"] = now
+	}
+
+	// The status condition makes concurrent transitions of the same item safe, only one of them applies
+	result := database.DB.WithContext(ctx).Model(&models.MarketItem{}).
+		Where("id = ? AND status IN ?", item.ID, transition.From).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: can't %s a %s item", ErrInvalidTransition, action, item.Status)
+	}
+	return nil
+}
+
+// ExpireReservations makes the items whose reservation ran out active again.
+func (s *MarketLifecycleService) ExpireReservations() (int64, error) {
+	result := database.DB.Model(&models.MarketItem{}).
+		Where("status = ? AND reserved_until <= ?", MarketItemReserved, time.Now()).
+		Updates(map[string]any{"status": MarketItemActive, "reserved_by_id": nil, "reserved_until": nil})
+	return result.RowsAffected, result.Error
+}
+
+// PurgeTrash permanently deletes the items that have been in the trash for longer than
+// TrashRetention, with their images and price history.
+func (s *MarketLifecycleService) PurgeTrash(ctx context.Context) (int, error) {
+	model, _ := FindTrashModel("MarketItem")
+	var items []models.MarketItem
+	err := database.DB.Unscoped().Where("deleted_at < ?", time.Now().Add(-s.TrashRetention)).Find(&items).Error
+	if err != nil {
+		return 0, err
+	}
+	for i := range items {
+		if err := PurgeRecord(ctx, model, &items[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(items), nil
+}
+
+// Start expires reservations and purges the trash every interval until ctx is done.
+func (s *MarketLifecycleService) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if expired, err := s.ExpireReservations(); err != nil {
+				log.Println("Failed to expire market item reservations:", err)
+			} else if expired > 0 {
+				log.Printf("Expired %d market item reservations", expired)
+			}
+			if purged, err := s.PurgeTrash(ctx); err != nil {
+				log.Println("Failed to purge deleted market items:", err)
+			} else if purged > 0 {
+				log.Printf("Purged %d deleted market items", purged)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+const priceHistoryBeforeKey = "price_history:before"
+
+// Register records the price of every market item created with db, and every change of it after,
+// whether it comes from the API, sync or anything else.
+func (s *MarketLifecycleService) Register(db *gorm.DB) error {
+	callbacks := db.Callback()
+	err := callbacks.Create().After("gorm:create").Register("price_history:create", func(db *gorm.DB) {
+		if db.Error != nil || db.Statement.Schema == nil || db.Statement.Schema.Name != "MarketItem" {
+			return
+		}
+		var changes []models.MarketItemPriceChange
+		now := time.Now()
+		for _, item := range marketItemsOf(db.Statement.ReflectValue) {
+			changes = append(changes, models.MarketItemPriceChange{MarketItemId: uint(item.ID), Price: item.Price, ChangedAt: now})
+		}
+		if len(changes) > 0 {
+			if err := db.Session(&gorm.Session{NewDB: true}).Create(&changes).Error; err != nil {
+				log.Println("Failed to record market item prices:", err)
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	// Updates of a single item load its price before and after. Updates by condition, like
+	// expiring reservations, never change prices.
+	err = callbacks.Update().Before("gorm:update").Register("price_history:before", func(db *gorm.DB) {
+		if id := updatedMarketItemId(db); id != 0 {
+			if price, ok := currentPrice(db, id); ok {
+				db.InstanceSet(priceHistoryBeforeKey, price)
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return callbacks.Update().After("gorm:update").Register("price_history:update", func(db *gorm.DB) {
+		before, ok := db.InstanceGet(priceHistoryBeforeKey)
+		if !ok || db.Error != nil {
+			return
+		}
+		id := updatedMarketItemId(db)
+		oldPrice := before.(float32)
+		price, ok := currentPrice(db, id)
+		if !ok || price == oldPrice {
+			return
+		}
+		change := models.MarketItemPriceChange{MarketItemId: uint(id), OldPrice: &oldPrice, Price: price, ChangedAt: time.Now()}
+		if err := db.Session(&gorm.Session{NewDB: true}).Create(&change).Error; err != nil {
+			log.Println("Failed to record market item price change:", err)
+		}
+	})
+}
+
+// updatedMarketItemId is the id of the market item an update was called on, 0 for other updates.
+func updatedMarketItemId(db *gorm.DB) int {
+	if db.Error != nil || db.Statement.Schema == nil || db.Statement.Schema.Name != "MarketItem" {
+		return 0
+	}
+	items := marketItemsOf(db.Statement.ReflectValue)
+	if len(items) != 1 {
+		return 0
+	}
+	return items[0].ID
+}
+
+func currentPrice(db *gorm.DB, id int) (float32, bool) {
+	var prices []float32
+	err := db.Session(&gorm.Session{NewDB: true}).Unscoped().Model(&models.MarketItem{}).Where("id = ?", id).Pluck("price", &prices).Error
+	if err != nil || len(prices) == 0 {
+		return 0, false
+	}
+	return prices[0], true
+}
+
+// marketItemsOf returns the market items in the value of a statement, which may be a single item or a batch.
+func marketItemsOf(value reflect.Value) []models.MarketItem {
+	var items []models.MarketItem
+	add := func(v reflect.Value) {
+		for v.Kind() == reflect.Pointer && !v.IsNil() {
+			v = v.Elem()
+		}
+		if item, ok := v.Interface().(models.MarketItem); ok && item.ID != 0 {
+			items = append(items, item)
+		}
+	}
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			add(value.Index(i))
+		}
+	case reflect.Struct, reflect.Pointer:
+		add(value)
+	}
+	return items
+}
+
+// ReducedFrom returns the price before the last change of item, when that change lowered it.
+// item needs its PriceChanges, in order.
+func ReducedFrom(item models.MarketItem) *float32 {
+	if len(item.PriceChanges) == 0 {
+		return nil
+	}
+	last := item.PriceChanges[len(item.PriceChanges)-1]
+	if last.OldPrice == nil || *last.OldPrice <= last.Price || last.Price != item.Price {
+		return nil
+	}
+	return last.OldPrice
+}
+
+// MarketSalesStats summarizes how long sold items were listed.
+type MarketSalesStats struct {
+	Sold int64
+	// Average and Median are zero when nothing was sold
+	Average time.Duration
+	Median  time.Duration
+	// SoldAfterReduction counts the sold items whose price was lowered while listed
+	SoldAfterReduction int64
+}
+
+// SalesStats summarizes the items sold by the sellers in sellerIds, or by everyone when it is nil.
+func SalesStats(sellerIds []uint) (MarketSalesStats, error) {
+	var stats MarketSalesStats
+	var items []models.MarketItem
+	query := database.DB.Select("id", "published_at", "sold_at").
+		Where("status = ? AND published_at IS NOT NULL AND sold_at IS NOT NULL", MarketItemSold)
+	if sellerIds != nil {
+		query = query.Where("user_id IN ?", sellerIds)
+	}
+	if err := query.Find(&items).Error; err != nil {
+		return stats, err
+	}
+	if len(items) == 0 {
+		return stats, nil
+	}
+	durations := make([]time.Duration, 0, len(items))
+	ids := make([]int, 0, len(items))
+	var total time.Duration
+	for _, item := range items {
+		duration := max(item.SoldAt.Sub(*item.PublishedAt), 0)
+		durations = append(durations, duration)
+		total += duration
+		ids = append(ids, item.ID)
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	stats.Sold = int64(len(items))
+	stats.Average = total / time.Duration(len(durations))
+	stats.Median = durations[len(durations)/2]
+	if len(durations)%2 == 0 {
+		stats.Median = (durations[len(durations)/2-1] + durations[len(durations)/2]) / 2
+	}
+	err := database.DB.Model(&models.MarketItemPriceChange{}).
+		Where("market_item_id IN ? AND old_price IS NOT NULL AND price < old_price", ids).
+		Distinct("market_item_id").Count(&stats.SoldAfterReduction).Error
+	return stats, err
+}
+
+// ValidMarketItemStatus reports whether status is one of MarketItemStatuses.
+func ValidMarketItemStatus(status string) bool {
+	return slices.Contains(MarketItemStatuses, status)
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// Alert is what a fired rule sends to its notifier.
+type Alert struct {
+	RuleId   int       `json:"ruleId"`
+	RuleName string    `json:"ruleName"`
+	UserId   uint      `json:"userId"`
+	Level    string    `json:"level"`
+	Message  string    `json:"message"`
+	FiredAt  time.Time `json:"firedAt"`
+}
+
+// Notifier delivers alerts somewhere outside the API.
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// NewNotifier returns the notifier of the given kind sending to target.
+// An empty kind returns nil: the alert is only written to the log book.
+// Env for smtp: ALERT_SMTP_HOST, ALERT_SMTP_PORT (default 25), ALERT_SMTP_USERNAME, ALERT_SMTP_PASSWORD, ALERT_SMTP_FROM.
+// Env for ntfy: ALERT_NTFY_TOKEN (optional access token).
+func NewNotifier(kind, target string) (Notifier, error) {
+	switch kind {
+	case "":
+		return nil, nil
+	case "webhook":
+		if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+			return nil, fmt.Errorf("webhook target must be an http(s) URL")
+		}
+		return &WebhookNotifier{URL: target}, nil
+	case "ntfy":
+		if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+			return nil, fmt.Errorf("ntfy target must be the http(s) URL of a topic")
+		}
+		return &NtfyNotifier{URL: target, Token: os.Getenv("ALERT_NTFY_TOKEN")}, nil
+	case "smtp":
+		if !strings.Contains(target, "@") {
+			return nil, fmt.Errorf("smtp target must be an e-mail address")
+		}
+		port := os.Getenv("ALERT_SMTP_PORT")
+		if port == "" {
+			port = "25"
+		}
+		return &SMTPNotifier{
+			Host:     os.Getenv("ALERT_SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("ALERT_SMTP_USERNAME"),
+			Password: os.Getenv("ALERT_SMTP_PASSWORD"),
+			From:     os.Getenv("ALERT_SMTP_FROM"),
+			To:       target,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown notifier %q, use webhook, smtp or ntfy", kind)
+	}
+}
+
+var notifierClient = &http.Client{Timeout: 10 * time.Second}
+
+// WebhookNotifier POSTs the alert as JSON.
+type WebhookNotifier struct {
+	URL string
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return doNotifierRequest(req)
+}
+
+// NtfyNotifier publishes the alert to an ntfy style topic URL: the body is the message,
+// title, priority and tags go in headers.
+type NtfyNotifier struct {
+	URL   string
+	Token string
+}
+
+func (n *NtfyNotifier) Notify(ctx context.Context, alert Alert) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, strings.NewReader(alert.Message))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", alert.RuleName)
+	req.Header.Set("Tags", "warning")
+	if alert.Level == "error" {
+		req.Header.Set("Priority", "urgent")
+	} else {
+		req.Header.Set("Priority", "high")
+	}
+	if n.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.Token)
+	}
+	return doNotifierRequest(req)
+}
+
+func doNotifierRequest(req *http.Request) error {
+	resp, err := notifierClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s responded %s", req.URL.Host, resp.Status)
+	}
+	return nil
+}
+
+// SMTPNotifier sends the alert as a plain text e-mail.
+type SMTPNotifier struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	To       string
+}
+
+func (n *SMTPNotifier) Notify(ctx context.Context, alert Alert) error {
+	if n.Host == "" || n.From == "" {
+		return fmt.Errorf("ALERT_SMTP_HOST and ALERT_SMTP_FROM must be set")
+	}
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", n.From)
+	fmt.Fprintf(&message, "To: %s\r\n", n.To)
+	// Encoded, so a rule name from before names were checked can't add header lines
+	subject := fmt.Sprintf("[%s] %s", strings.ToUpper(alert.Level), alert.RuleName)
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", alert.FiredAt.Format(time.RFC1123Z))
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	message.WriteString(alert.Message + "\r\n")
+
+	var auth smtp.Auth
+	if n.Username != "" {
+		auth = smtp.PlainAuth("", n.Username, n.Password, n.Host)
+	}
+	// smtp.SendMail has no context, run it so a hanging server can't outlive ctx
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(net.JoinHostPort(n.Host, n.Port), auth, n.From, []string{n.To}, message.Bytes())
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,472 @@
+package services
+
+import (
+	"api/database"
+	"api/dtos"
+	"api/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrUnknownSyncModel is returned for a model name that does not take part in sync.
+var ErrUnknownSyncModel = errors.New("unknown sync model")
+
+// SyncModel describes how a model of database.Models takes part in sync.
+type SyncModel struct {
+	Name string
+	// Resource is the scope resource, the caller needs <resource>:read to pull and <resource>:write to push
+	Resource string
+	New      func() any
+	// Writable are the fields a client may push, none for pull-only models
+	Writable []string
+	// OwnerField holds the user a pushed record belongs to
+	OwnerField string
+	// Shared records are pulled by every user, not only by those who may read the owner's records
+	Shared bool
+	// Hidden reports whether a shared record is kept from everyone but its owner, like a draft
+	Hidden func(record any) bool
+	// Defaults fills in fields a client may leave out on create
+	Defaults func(record any)
+	Validate func(record any) map[string]string
+}
+
+// SyncModels are the models clients can sync. API keys, retention policies and the
+// tracking tables themselves stay out of sync.
+var SyncModels = []SyncModel{
+	{Name: "User", Resource: "users", New: func() any { return &models.User{} }},
+	{Name: "Category", Resource: "categories", New: func() any { return &models.Category{} }},
+	{
+		Name: "MarketItem", Resource: "marketitem", New: func() any { return &models.MarketItem{} },
+		Writable: []string{"Title", "Description", "Price", "CategoryId"}, OwnerField: "UserId",
+		// The market lists the items of every seller
+		Shared: true,
+		Hidden: func(record any) bool { return record.(*models.MarketItem).Status == MarketItemDraft },
+		// Pushed items are listed right away, like items created through the API
+		Defaults: func(record any) {
+			if item := record.(*models.MarketItem); item.Status == "" {
+				now := time.Now()
+				item.Status, item.PublishedAt = MarketItemActive, &now
+			}
+		},
+		Validate: func(record any) map[string]string {
+			fields, err := ValidateMarketItem(record.(*models.MarketItem))
+			if err != nil {
+				return map[string]string{"categoryId": "could not be checked"}
+			}
+			return fields
+		},
+	},
+	{
+		Name: "BloodPressure", Resource: "bloodpressure", New: func() any { return &models.BloodPressure{} },
+		Writable: []string{"Systolic", "Diastolic", "Pulse", "Medicine", "MeasuredAt"}, OwnerField: "UserId",
+		Defaults: func(record any) {
+			if reading := record.(*models.BloodPressure); reading.MeasuredAt.IsZero() {
+				reading.MeasuredAt = time.Now()
+			}
+		},
+		Validate: func(record any) map[string]string {
+			reading := record.(*models.BloodPressure)
+			return ValidateBloodPressure(reading.Systolic, reading.Diastolic, reading.Pulse, reading.Medicine, reading.MeasuredAt)
+		},
+	},
+	{
+		Name: "LogBookEntry", Resource: "logbook", New: func() any { return &models.LogBookEntry{} },
+		Writable: []string{"Message", "Level", "Category", "Timestamp"}, OwnerField: "UserId",
+		Defaults: func(record any) {
+			entry := record.(*models.LogBookEntry)
+			if entry.Level == "" {
+				entry.Level = "info"
+			}
+			if entry.Timestamp.IsZero() {
+				entry.Timestamp = time.Now()
+			}
+		},
+		Validate: func(record any) map[string]string {
+			entry := record.(*models.LogBookEntry)
+			return ValidateLogBookEntry(entry.Message, entry.Level, entry.Category)
+		},
+	},
+	{Name: "ChatThread", Resource: "chat", New: func() any { return &models.ChatThread{} }},
+	{Name: "ChatMessage", Resource: "chat", New: func() any { return &models.ChatMessage{} }},
+	{Name: "Caregiver", Resource: "caregivers", New: func() any { return &models.Caregiver{} }},
+	{Name: "AlertRule", Resource: "alerts", New: func() any { return &models.AlertRule{} }},
+}
+
+// Resources of the models in the change log that don't take part in sync. Changes of the
+// models in neither list, like retention policies, are only read by admins.
+var changeLogResources = map[string]string{
+	"ApiKey":          "api_keys",
+	"MarketItemImage": "marketitem",
+}
+
+// SyncCaller is who pulls or pushes. UserId is 0 when authentication is disabled, which
+// allows everything like the rest of the API does.
+type SyncCaller struct {
+	UserId   uint
+	Admin    bool
+	HasScope func(scope string) bool
+}
+
+func (caller SyncCaller) can(model SyncModel, access string) bool {
+	return caller.HasScope(model.Resource + ":" + access)
+}
+
+func (caller SyncCaller) owns(ownerId uint) bool {
+	return caller.UserId == 0 || caller.Admin || ownerId == caller.UserId
+}
+
+// hidden reports whether the caller sees record as deleted, as it is shared but hidden from them.
+func (caller SyncCaller) hidden(name string, record reflect.Value) bool {
+	model, _ := findSyncModel(name)
+	if model.Hidden == nil || !model.Hidden(record.Interface()) {
+		return false
+	}
+	return !caller.owns(uint(record.Elem().FieldByName(model.OwnerField).Uint()))
+}
+
+// ReadableChangeModels returns the names of the models whose changes the caller may read,
+// those whose <resource>:read scope it holds.
+func ReadableChangeModels(caller SyncCaller) []string {
+	var names []string
+	for _, model := range SyncModels {
+		if caller.can(model, "read") {
+			names = append(names, model.Name)
+		}
+	}
+	for name, resource := range changeLogResources {
+		if caller.HasScope(resource + ":read") {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// SharedSyncModels returns the names of the models whose records every user pulls.
+func SharedSyncModels() []string {
+	var names []string
+	for _, model := range SyncModels {
+		if model.Shared {
+			names = append(names, model.Name)
+		}
+	}
+	return names
+}
+
+func findSyncModel(name string) (SyncModel, bool) {
+	for _, model := range SyncModels {
+		if model.Name == name {
+			return model, true
+		}
+	}
+	return SyncModel{}, false
+}
+
+// syncResetModel and syncResetOperation mark the change log entry written by ResetSync.
+const (
+	syncResetModel     = "*"
+	syncResetOperation = "reset"
+)
+
+// ResetSync tells clients that the change log was replaced, e.g. by restoring a backup, so their
+// cursors no longer fit it. lastId is the last change id handed out before; new ids continue after
+// it, so no cursor a client holds points past the reset.
+func ResetSync(lastId uint64) error {
+	restoredLast, err := LastChangeId()
+	if err != nil {
+		return err
+	}
+	return database.DB.Create(&models.ChangeLogEntry{
+		ID:        max(lastId, restoredLast) + 1,
+		ModelName: syncResetModel,
+		Operation: syncResetOperation,
+		Changes:   json.RawMessage("{}"),
+		Timestamp: time.Now(),
+	}).Error
+}
+
+// LastChangeId returns the id of the newest change log entry, 0 when there is none.
+func LastChangeId() (uint64, error) {
+	var last uint64
+	err := database.DB.Model(&models.ChangeLogEntry{}).Select("COALESCE(MAX(id), 0)").Scan(&last).Error
+	return last, err
+}
+
+// PullChanges returns the current state of every record of names (all sync models when
+// empty) that was created, updated or deleted after cursor. Models the caller may not read
+// are skipped. Several changes to the same record since cursor come back as one.
+func PullChanges(caller SyncCaller, cursor uint64, names []string, limit int) (dtos.SyncPullResponse, error) {
+	response := dtos.SyncPullResponse{Changes: []dtos.SyncChange{}, Cursor: cursor}
+	var readable []string
+	if len(names) == 0 {
+		for _, model := range SyncModels {
+			if caller.can(model, "read") {
+				readable = append(readable, model.Name)
+			}
+		}
+	} else {
+		for _, name := range names {
+			model, ok := findSyncModel(name)
+			if !ok {
+				return response, fmt.Errorf("%w: %s", ErrUnknownSyncModel, name)
+			}
+			if caller.can(model, "read") {
+				readable = append(readable, model.Name)
+			}
+		}
+	}
+	if len(readable) == 0 {
+		return response, nil
+	}
+	if cursor > 0 {
+		var resets int64
+		err := database.DB.Model(&models.ChangeLogEntry{}).Where("id > ? AND model_name = ?", cursor, syncResetModel).Count(&resets).Error
+		if err != nil {
+			return response, err
+		}
+		if resets > 0 {
+			response.Reset, response.Cursor = true, 0
+			return response, nil
+		}
+	}
+
+	query := database.DB.Model(&models.ChangeLogEntry{}).Where("id > ? AND model_name IN ?", cursor, readable)
+	if caller.UserId != 0 && !caller.Admin {
+		users, err := ReadableUserIds(caller.UserId)
+		if err != nil {
+			return response, err
+		}
+		query = query.Where("owner_id IS NULL OR owner_id IN ? OR model_name IN ?", users, SharedSyncModels())
+	}
+	var entries []models.ChangeLogEntry
+	// One extra row tells whether there is another page
+	if err := query.Order("id").Limit(limit + 1).Find(&entries).Error; err != nil {
+		return response, err
+	}
+	if len(entries) > limit {
+		entries = entries[:limit]
+		response.HasMore = true
+	}
+	if len(entries) == 0 {
+		return response, nil
+	}
+	response.Cursor = entries[len(entries)-1].ID
+
+	// Keep the last change per record, in change log order
+	type recordKey struct{ model, id string }
+	last := map[recordKey]int{}
+	for i, entry := range entries {
+		last[recordKey{entry.ModelName, entry.RecordId}] = i
+	}
+	idsByModel := map[string][]string{}
+	for i, entry := range entries {
+		if last[recordKey{entry.ModelName, entry.RecordId}] == i {
+			idsByModel[entry.ModelName] = append(idsByModel[entry.ModelName], entry.RecordId)
+		}
+	}
+	records := map[recordKey]reflect.Value{}
+	for name, ids := range idsByModel {
+		model, _ := findSyncModel(name)
+		rows := reflect.New(reflect.SliceOf(reflect.TypeOf(model.New()).Elem()))
+		if err := database.DB.Unscoped().Where("id IN ?", ids).Find(rows.Interface()).Error; err != nil {
+			return response, err
+		}
+		for i := 0; i < rows.Elem().Len(); i++ {
+			row := rows.Elem().Index(i)
+			records[recordKey{name, fmt.Sprint(row.FieldByName("ID").Interface())}] = row.Addr()
+		}
+	}
+
+	for i, entry := range entries {
+		key := recordKey{entry.ModelName, entry.RecordId}
+		if last[key] != i {
+			continue
+		}
+		change := dtos.SyncChange{Model: entry.ModelName, Id: entry.RecordId, Operation: "delete"}
+		if record, ok := records[key]; ok {
+			updatedAt := record.Elem().FieldByName("UpdatedAt").Interface().(time.Time)
+			change.UpdatedAt = &updatedAt
+			if !syncRecordDeleted(record) && !caller.hidden(entry.ModelName, record) {
+				change.Operation = "upsert"
+				change.Record = record.Interface()
+			}
+		}
+		response.Changes = append(response.Changes, change)
+	}
+	return response, nil
+}
+
+// PushChanges applies each change on its own and reports the outcome per change. Updates
+// and deletes only apply when the server record is still at BaseUpdatedAt; otherwise the
+// result is a conflict carrying the server version.
+func PushChanges(ctx context.Context, caller SyncCaller, changes []dtos.SyncPushChange) dtos.SyncPushResponse {
+	response := dtos.SyncPushResponse{Results: make([]dtos.SyncPushResult, 0, len(changes))}
+	for _, change := range changes {
+		response.Results = append(response.Results, pushChange(ctx, caller, change))
+	}
+	return response
+}
+
+func pushChange(ctx context.Context, caller SyncCaller, change dtos.SyncPushChange) dtos.SyncPushResult {
+	result := dtos.SyncPushResult{Model: change.Model, Id: change.Id, ClientId: change.ClientId, Status: "rejected"}
+	model, ok := findSyncModel(change.Model)
+	if !ok {
+		result.Error = "Unknown model"
+		return result
+	}
+	if len(model.Writable) == 0 {
+		result.Error = "Model cannot be pushed"
+		return result
+	}
+	if !caller.can(model, "write") {
+		result.Error = "Missing scope " + model.Resource + ":write"
+		return result
+	}
+	db := database.DB.WithContext(ctx)
+
+	if change.Operation == "create" {
+		record, err := decodeSyncRecord(model, change.Record)
+		if err != nil {
+			result.Error = "Cannot parse record"
+			return result
+		}
+		owner := reflect.ValueOf(record).Elem().FieldByName(model.OwnerField)
+		if owner.Uint() == 0 {
+			owner.SetUint(uint64(caller.UserId))
+		} else if !caller.owns(uint(owner.Uint())) {
+			result.Error = "Cannot create records for another user"
+			return result
+		}
+		if model.Defaults != nil {
+			model.Defaults(record)
+		}
+		if result.Fields = model.Validate(record); len(result.Fields) > 0 {
+			result.Error = "Validation failed"
+			return result
+		}
+		if err := db.Omit(clause.Associations).Create(record).Error; err != nil {
+			result.Error = "Failed to create record"
+			return result
+		}
+		result.Id = syncRecordId(record)
+		result.Status = "applied"
+		result.Record = record
+		return result
+	}
+
+	if change.Operation != "update" && change.Operation != "delete" {
+		result.Error = "operation must be one of create, update, delete"
+		return result
+	}
+	if change.BaseUpdatedAt == nil {
+		result.Error = "baseUpdatedAt is required"
+		return result
+	}
+	existing := model.New()
+	if err := db.Unscoped().First(existing, change.Id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			result.Error = "Record not found"
+		} else {
+			result.Error = "Failed to load record"
+		}
+		return result
+	}
+	current := reflect.ValueOf(existing).Elem()
+	if !caller.owns(uint(current.FieldByName(model.OwnerField).Uint())) {
+		result.Error = "Record belongs to another user"
+		return result
+	}
+	deleted := syncRecordDeleted(current.Addr())
+	if change.Operation == "delete" && deleted {
+		// Deleting twice is harmless, e.g. a retry after a lost response
+		result.Status = "applied"
+		return result
+	}
+	updatedAt := current.FieldByName("UpdatedAt").Interface().(time.Time)
+	if deleted || !updatedAt.Equal(*change.BaseUpdatedAt) {
+		return syncConflict(db, model, change.Id, result)
+	}
+	// The record may change between loading and writing it, so the write only applies while it is
+	// still at the version loaded. updatedAt is compared as read, in the database's own format.
+	unchanged := db.Where("updated_at = ?", updatedAt)
+
+	if change.Operation == "delete" {
+		deleted := unchanged.Delete(existing)
+		if deleted.Error != nil {
+			result.Error = "Failed to delete record"
+			return result
+		}
+		if deleted.RowsAffected == 0 {
+			return syncConflict(db, model, change.Id, result)
+		}
+		result.Status = "applied"
+		return result
+	}
+
+	// Fields the client left out keep their server value
+	update := model.New()
+	reflect.ValueOf(update).Elem().Set(current)
+	if err := json.Unmarshal(change.Record, update); err != nil {
+		result.Error = "Cannot parse record"
+		return result
+	}
+	for _, name := range model.Writable {
+		current.FieldByName(name).Set(reflect.ValueOf(update).Elem().FieldByName(name))
+	}
+	if result.Fields = model.Validate(existing); len(result.Fields) > 0 {
+		result.Error = "Validation failed"
+		return result
+	}
+	updated := unchanged.Model(existing).Select(model.Writable).Updates(existing)
+	if updated.Error != nil {
+		result.Error = "Failed to update record"
+		return result
+	}
+	if updated.RowsAffected == 0 {
+		return syncConflict(db, model, change.Id, result)
+	}
+	result.Status = "applied"
+	result.Record = existing
+	return result
+}
+
+// syncConflict reports that the record with id changed on the server, with its current version
+// unless it was deleted.
+func syncConflict(db *gorm.DB, model SyncModel, id int, result dtos.SyncPushResult) dtos.SyncPushResult {
+	result.Status = "conflict"
+	result.Error = "Record changed on the server"
+	record := model.New()
+	if err := db.First(record, id).Error; err == nil {
+		result.Record = record
+	}
+	return result
+}
+
+// decodeSyncRecord reads the writable fields of a pushed record, everything else keeps its zero value.
+func decodeSyncRecord(model SyncModel, data json.RawMessage) (any, error) {
+	decoded := model.New()
+	if err := json.Unmarshal(data, decoded); err != nil {
+		return nil, err
+	}
+	record := model.New()
+	for _, name := range append(slices.Clone(model.Writable), model.OwnerField) {
+		reflect.ValueOf(record).Elem().FieldByName(name).Set(reflect.ValueOf(decoded).Elem().FieldByName(name))
+	}
+	return record, nil
+}
+
+func syncRecordId(record any) int {
+	return int(reflect.ValueOf(record).Elem().FieldByName("ID").Int())
+}
+
+func syncRecordDeleted(record reflect.Value) bool {
+	deletedAt, ok := record.Elem().FieldByName("DeletedAt").Interface().(gorm.DeletedAt)
+	return ok && deletedAt.Valid
+}
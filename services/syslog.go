@@ -0,0 +1,286 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxSyslogMessage = 64 * 1024
+	// maxSyslogFrameDigits is the length of maxSyslogMessage written out
+	maxSyslogFrameDigits = 5
+	defaultSyslogApp     = "syslog"
+)
+
+// SyslogMessage is the part of an RFC 5424 or RFC 3164 message that ends up in the log book.
+type SyslogMessage struct {
+	Severity  int
+	Hostname  string
+	AppName   string
+	Timestamp time.Time
+	Message   string
+}
+
+// SyslogSeverityLevel maps a syslog severity (0 emergency .. 7 debug) to a log book level.
+func SyslogSeverityLevel(severity int) string {
+	switch {
+	case severity <= 3:
+		return "error"
+	case severity == 4:
+		return "warn"
+	case severity <= 6:
+		return "info"
+	default:
+		return "debug"
+	}
+}
+
+// ParseSyslogMessage parses an RFC 5424 or RFC 3164 message. Lines without a <PRI> header,
+// as found in files like /var/log/syslog, are accepted with severity info. Parts that
+// cannot be parsed stay in Message, so a line is never lost.
+func ParseSyslogMessage(line string, now time.Time) SyslogMessage {
+	line = strings.TrimRight(line, "\r\n\x00")
+	msg := SyslogMessage{Severity: 6, Timestamp: now, Message: line}
+
+	rest := line
+	if strings.HasPrefix(rest, "<") {
+		end := strings.IndexByte(rest, '>')
+		if end > 1 && end <= 4 {
+			if pri, err := strconv.Atoi(rest[1:end]); err == nil && pri <= 191 {
+				msg.Severity = pri % 8
+				rest = rest[end+1:]
+				msg.Message = rest
+			}
+		}
+	}
+
+	if strings.HasPrefix(rest, "1 ") {
+		if parseRFC5424(rest[2:], &msg) {
+			return msg
+		}
+	}
+	parseRFC3164(rest, now, &msg)
+	return msg
+}
+
+// parseRFC5424 parses "TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD MSG" following the version.
+func parseRFC5424(rest string, msg *SyslogMessage) bool {
+	fields := strings.SplitN(rest, " ", 6)
+	if len(fields) < 6 {
+		return false
+	}
+	if fields[0] != "-" {
+		timestamp, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return false
+		}
+		msg.Timestamp = timestamp
+	}
+	msg.Hostname = nilValue(fields[1])
+	msg.AppName = nilValue(fields[2])
+
+	body, ok := skipStructuredData(fields[5])
+	if !ok {
+		return false
+	}
+	body = strings.TrimPrefix(strings.TrimPrefix(body, " "), "\uFEFF")
+	msg.Message = body
+	return true
+}
+
+// skipStructuredData returns what follows the STRUCTURED-DATA at the start of s.
+func skipStructuredData(s string) (string, bool) {
+	if strings.HasPrefix(s, "-") {
+		return s[1:], true
+	}
+	if !strings.HasPrefix(s, "[") {
+		return "", false
+	}
+	for strings.HasPrefix(s, "[") {
+		end := structuredDataEnd(s)
+		if end < 0 {
+			return "", false
+		}
+		s = s[end+1:]
+	}
+	return s, true
+}
+
+// structuredDataEnd returns the index of the "]" closing the element at the start of s.
+// Param values are quoted and may contain escaped quotes and brackets.
+func structuredDataEnd(s string) int {
+	inQuotes := false
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && inQuotes:
+			i++
+		case s[i] == '"':
+			inQuotes = !inQuotes
+		case s[i] == ']' && !inQuotes:
+			return i
+		}
+	}
+	return -1
+}
+
+// parseRFC3164 parses "Mmm dd hh:mm:ss [HOSTNAME] TAG[PID]: MSG". The year is not part of the
+// format, so a timestamp that would lie in the future belongs to last year.
+func parseRFC3164(rest string, now time.Time, msg *SyslogMessage) {
+	const stampLen = len(time.Stamp)
+	if len(rest) < stampLen+1 {
+		return
+	}
+	stamp, err := time.ParseInLocation(time.Stamp, rest[:stampLen], now.Location())
+	if err != nil || rest[stampLen] != ' ' {
+		return
+	}
+	timestamp := stamp.AddDate(now.Year(), 0, 0)
+	if timestamp.After(now.Add(24 * time.Hour)) {
+		timestamp = timestamp.AddDate(-1, 0, 0)
+	}
+	msg.Timestamp = timestamp
+	rest = rest[stampLen+1:]
+	msg.Message = rest
+
+	// Local senders leave out the hostname, so the first word may already be the tag
+	first, after, _ := strings.Cut(rest, " ")
+	if !isSyslogTag(first) {
+		msg.Hostname = first
+		first, after, _ = strings.Cut(after, " ")
+	}
+	if !isSyslogTag(first) {
+		return
+	}
+	tag := strings.TrimSuffix(first, ":")
+	if i := strings.IndexByte(tag, '['); i >= 0 {
+		tag = tag[:i]
+	}
+	msg.AppName = tag
+	msg.Message = after
+}
+
+func isSyslogTag(word string) bool {
+	return len(word) > 1 && strings.HasSuffix(word, ":")
+}
+
+func nilValue(field string) string {
+	if field == "-" {
+		return ""
+	}
+	return field
+}
+
+// ListenSyslogUDP receives one message per datagram on addr until ctx is done.
+func ListenSyslogUDP(ctx context.Context, addr string, handle func(SyslogMessage)) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	go func() {
+		buffer := make([]byte, maxSyslogMessage)
+		for {
+			n, _, err := conn.ReadFrom(buffer)
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					log.Println("Syslog UDP listener stopped:", err)
+				}
+				return
+			}
+			handle(ParseSyslogMessage(string(buffer[:n]), time.Now()))
+		}
+	}()
+	return nil
+}
+
+// ListenSyslogTCP accepts connections on addr until ctx is done. Messages are framed by
+// octet counting or by newlines (RFC 6587), whichever the sender uses.
+func ListenSyslogTCP(ctx context.Context, addr string, handle func(SyslogMessage)) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					log.Println("Syslog TCP listener stopped:", err)
+				}
+				return
+			}
+			go readSyslogStream(ctx, conn, handle)
+		}
+	}()
+	return nil
+}
+
+func readSyslogStream(ctx context.Context, conn net.Conn, handle func(SyslogMessage)) {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	scanner := bufio.NewScanner(conn)
+	// Room for the longest message and its length prefix; a longer one ends the connection
+	scanner.Buffer(make([]byte, 0, 4096), maxSyslogMessage+maxSyslogFrameDigits+1)
+	scanner.Split(splitSyslogFrames)
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.TrimSpace(line) != "" {
+			handle(ParseSyslogMessage(line, time.Now()))
+		}
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, net.ErrClosed) {
+		log.Println("Failed to read syslog message:", err)
+	}
+}
+
+// splitSyslogFrames is a bufio.SplitFunc for a syslog stream. A frame starting with a digit is
+// octet counted ("<length> <message>"), any other is a line.
+func splitSyslogFrames(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if len(data) == 0 {
+		return 0, nil, nil
+	}
+	if data[0] < '1' || data[0] > '9' {
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			return i + 1, data[:i+1], nil
+		}
+		if atEOF {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	}
+
+	space := bytes.IndexByte(data[:min(len(data), maxSyslogFrameDigits+1)], ' ')
+	if space < 0 {
+		if atEOF || len(data) > maxSyslogFrameDigits {
+			return 0, nil, errors.New("invalid syslog frame length " + strconv.Quote(string(data[:min(len(data), maxSyslogFrameDigits+1)])))
+		}
+		return 0, nil, nil
+	}
+	n, err := strconv.Atoi(string(data[:space]))
+	if err != nil || n <= 0 || n > maxSyslogMessage {
+		return 0, nil, errors.New("invalid syslog frame length " + strconv.Quote(string(data[:space])))
+	}
+	if len(data) < space+1+n {
+		if atEOF {
+			return 0, nil, io.ErrUnexpectedEOF
+		}
+		return 0, nil, nil
+	}
+	return space + 1 + n, data[space+1 : space+1+n], nil
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"api/database"
+	"api/models"
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+)
+
+// TrashModel is a model whose deleted records can be listed, restored and purged.
+type TrashModel struct {
+	Name string
+	// Resource is the scope resource, <resource>:read lists the trash and <resource>:write restores and purges
+	Resource string
+	New      func() any
+	// OwnerColumn holds the user a record belongs to. Without one only admins can use the trash of the model.
+	OwnerColumn string
+	// Purged cleans up what belongs to a purged record outside the database, nil when there is nothing
+	Purged func(ctx context.Context, record any) error
+}
+
+var TrashModels = []TrashModel{
+	{Name: "User", Resource: "users", New: func() any { return &models.User{} }},
+	{Name: "ApiKey", Resource: "api_keys", New: func() any { return &models.ApiKey{} }, OwnerColumn: "user_id"},
+	{
+		Name: "MarketItem", Resource: "marketitem", New: func() any { return &models.MarketItem{} }, OwnerColumn: "user_id",
+		// Images and price history stay while the item is in the trash, so it can be restored with them
+		Purged: func(ctx context.Context, record any) error {
+			id := record.(*models.MarketItem).ID
+			if err := database.DB.WithContext(ctx).Where("market_item_id = ?", id).Delete(&models.MarketItemPriceChange{}).Error; err != nil {
+				return err
+			}
+			return NewMarketImageService().DeleteAll(ctx, uint(id))
+		},
+	},
+	{Name: "Category", Resource: "categories", New: func() any { return &models.Category{} }},
+	{Name: "BloodPressure", Resource: "bloodpressure", New: func() any { return &models.BloodPressure{} }, OwnerColumn: "user_id"},
+	{Name: "LogBookEntry", Resource: "logbook", New: func() any { return &models.LogBookEntry{} }, OwnerColumn: "user_id"},
+	{
+		Name: "ChatThread", Resource: "chat", New: func() any { return &models.ChatThread{} },
+		// Messages are useless without their thread, including those in the trash themselves
+		Purged: func(ctx context.Context, record any) error {
+			id := record.(*models.ChatThread).ID
+			return database.DB.WithContext(ctx).Unscoped().Where("thread_id = ?", id).Delete(&models.ChatMessage{}).Error
+		},
+	},
+	{Name: "ChatMessage", Resource: "chat", New: func() any { return &models.ChatMessage{} }},
+	{Name: "Caregiver", Resource: "caregivers", New: func() any { return &models.Caregiver{} }, OwnerColumn: "patient_id"},
+	{Name: "AlertRule", Resource: "alerts", New: func() any { return &models.AlertRule{} }, OwnerColumn: "created_by_id"},
+	{Name: "LogRetentionPolicy", Resource: "admin", New: func() any { return &models.LogRetentionPolicy{} }},
+}
+
+// PurgeRecord permanently deletes record, which is in the trash, and cleans up what belongs to it.
+// A failed clean up is only logged, as the record is gone either way.
+func PurgeRecord(ctx context.Context, model TrashModel, record any) error {
+	if err := database.DB.WithContext(ctx).Unscoped().Delete(record).Error; err != nil {
+		return err
+	}
+	if model.Purged != nil {
+		if err := model.Purged(ctx, record); err != nil {
+			log.Println("Failed to clean up after purging", model.Name, fmt.Sprint(reflect.ValueOf(record).Elem().FieldByName("ID").Interface())+":", err)
+		}
+	}
+	return nil
+}
+
+func FindTrashModel(name string) (TrashModel, bool) {
+	for _, model := range TrashModels {
+		if model.Name == name {
+			return model, true
+		}
+	}
+	return TrashModel{}, false
+}